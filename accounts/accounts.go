@@ -0,0 +1,352 @@
+// Package accounts keeps optional player accounts. An account links a username
+// and password to a persistent player ID, so that a player keeps their identity
+// across browsers and devices, and holds their profile: display name, preferred
+// chip colour and settings. Guests play without one, as before.
+//
+// Accounts are stored in a JSON file. Passwords are hashed with bcrypt, and a
+// login hands out a session token of which only a hash is stored.
+package accounts
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"sequence-game/game"
+)
+
+// Limits on credentials. bcrypt ignores anything past 72 bytes of a password.
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+	maxSessions       = 10 // Sessions kept per account; the oldest is dropped first
+)
+
+// Failed logins to one username allowed per loginWindow; further attempts fail
+// until the window closes, however good the password.
+const (
+	maxLoginFailures = 5
+	loginWindow      = 15 * time.Minute
+	maxTracked       = 10000 // Usernames tracked before closed windows are pruned
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,20}$`)
+
+// Errors returned by the Store. ErrInvalid is wrapped with the reason, and
+// ErrTooManyLogins wraps ErrBadCredentials.
+var (
+	ErrInvalid        = errors.New("invalid account details")
+	ErrUsernameTaken  = errors.New("username is taken")
+	ErrBadCredentials = errors.New("wrong username or password")
+	ErrNoSession      = errors.New("not logged in")
+	ErrTooManyLogins  = fmt.Errorf("%w: too many failed attempts, try again later", ErrBadCredentials)
+)
+
+// dummyHash is compared against when a username does not exist, so that a login
+// takes as long whether or not it does.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("no such account"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// Profile is what an account remembers about its player.
+type Profile struct {
+	DisplayName string   `json:"displayName"`         // Name shown at the table
+	ChipColor   string   `json:"chipColor,omitempty"` // Preferred chip colour, one of game.ChipColors
+	Settings    Settings `json:"settings"`
+}
+
+// Settings are a player's preferences, applied by the server and the clients.
+type Settings struct {
+	ReviewMoves bool   `json:"reviewMoves"`           // Turn on move analysis in every game where hints are allowed
+	RulesPreset string `json:"rulesPreset,omitempty"` // Preset offered first when creating a game
+}
+
+// Account is a stored account.
+type Account struct {
+	Username     string    `json:"username"`
+	PlayerID     string    `json:"playerId"`
+	PasswordHash string    `json:"passwordHash"`
+	Profile      Profile   `json:"profile"`
+	Created      time.Time `json:"created"`
+	Sessions     []string  `json:"sessions,omitempty"` // SHA-256 hashes of the live session tokens
+}
+
+// Store holds the accounts, saving every change to its file.
+type Store struct {
+	mu       sync.Mutex
+	path     string
+	accounts map[string]*Account       // By lower-case username
+	failures map[string]*loginFailures // Recent failed logins, by lower-case username
+}
+
+// loginFailures counts the failed logins to a username in the window ending at until.
+type loginFailures struct {
+	count int
+	until time.Time
+}
+
+// Open loads the accounts kept at path; a missing file is an empty store. An
+// empty path keeps the accounts in memory only.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, accounts: make(map[string]*Account), failures: make(map[string]*loginFailures)}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*Account
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	for _, account := range list {
+		s.accounts[strings.ToLower(account.Username)] = account
+	}
+	return s, nil
+}
+
+// Register creates an account with a new player ID and logs it in, returning the
+// account and its session token.
+func (s *Store) Register(username, password string, profile Profile) (Account, string, error) {
+	if !usernamePattern.MatchString(username) {
+		return Account{}, "", fmt.Errorf("%w: a username is 3 to 20 letters, digits, '-' or '_'", ErrInvalid)
+	}
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return Account{}, "", fmt.Errorf("%w: a password is %d to %d characters", ErrInvalid, MinPasswordLength, MaxPasswordLength)
+	}
+	if err := profile.validate(); err != nil {
+		return Account{}, "", err
+	}
+	if profile.DisplayName == "" {
+		profile.DisplayName = username
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return Account{}, "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := strings.ToLower(username)
+	if s.accounts[key] != nil {
+		return Account{}, "", ErrUsernameTaken
+	}
+	account := &Account{
+		Username: username, PlayerID: game.GenerateID(), PasswordHash: string(hash),
+		Profile: profile, Created: time.Now().UTC(),
+	}
+	token := account.newSession()
+	s.accounts[key] = account
+	if err := s.save(); err != nil {
+		delete(s.accounts, key)
+		return Account{}, "", err
+	}
+	return account.public(), token, nil
+}
+
+// Login checks the password and starts a session, returning its token. After
+// maxLoginFailures failed attempts at a username, whether or not it exists, its
+// logins fail with ErrTooManyLogins for the rest of the loginWindow.
+func (s *Store) Login(username, password string) (Account, string, error) {
+	key := strings.ToLower(username)
+	s.mu.Lock()
+	if !s.countAttempt(key, time.Now()) {
+		s.mu.Unlock()
+		return Account{}, "", ErrTooManyLogins
+	}
+	account := s.accounts[key]
+	s.mu.Unlock()
+
+	// Hashing outside the lock keeps one slow login from holding up the others
+	hash := dummyHash()
+	if account != nil {
+		hash = []byte(account.PasswordHash)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || account == nil {
+		return Account{}, "", ErrBadCredentials
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, key)
+	token := account.newSession()
+	return account.public(), token, s.save()
+}
+
+// countAttempt counts a login attempt at key as failed until it succeeds,
+// reporting false if too many have failed already. The caller must hold s.mu.
+func (s *Store) countAttempt(key string, now time.Time) bool {
+	f := s.failures[key]
+	if f == nil || now.After(f.until) {
+		if len(s.failures) >= maxTracked {
+			for k, old := range s.failures {
+				if now.After(old.until) {
+					delete(s.failures, k)
+				}
+			}
+		}
+		f = &loginFailures{until: now.Add(loginWindow)}
+		s.failures[key] = f
+	}
+	if f.count >= maxLoginFailures {
+		return false
+	}
+	f.count++
+	return true
+}
+
+// Session returns the account a session token belongs to.
+func (s *Store) Session(token string) (Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if account := s.bySession(token); account != nil {
+		return account.public(), nil
+	}
+	return Account{}, ErrNoSession
+}
+
+// Logout ends a session.
+func (s *Store) Logout(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	account := s.bySession(token)
+	if account == nil {
+		return ErrNoSession
+	}
+	hashed := hashToken(token)
+	for i, session := range account.Sessions {
+		if session == hashed {
+			account.Sessions = append(account.Sessions[:i], account.Sessions[i+1:]...)
+			break
+		}
+	}
+	return s.save()
+}
+
+// UpdateProfile replaces the profile of the session's account.
+func (s *Store) UpdateProfile(token string, profile Profile) (Account, error) {
+	if err := profile.validate(); err != nil {
+		return Account{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	account := s.bySession(token)
+	if account == nil {
+		return Account{}, ErrNoSession
+	}
+	if profile.DisplayName == "" {
+		profile.DisplayName = account.Username
+	}
+	account.Profile = profile
+	return account.public(), s.save()
+}
+
+// OwnsPlayerID reports whether playerID belongs to an account, and so may only be
+// used by logging in.
+func (s *Store) OwnsPlayerID(playerID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, account := range s.accounts {
+		if account.PlayerID == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) bySession(token string) *Account {
+	if token == "" {
+		return nil
+	}
+	hashed := hashToken(token)
+	for _, account := range s.accounts {
+		for _, session := range account.Sessions {
+			if session == hashed {
+				return account
+			}
+		}
+	}
+	return nil
+}
+
+// save writes every account to the store's file, replacing it only once the new
+// contents are safely written.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+	list := make([]*Account, 0, len(s.accounts))
+	for _, account := range s.accounts {
+		list = append(list, account)
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// newSession adds a session to the account and returns its token.
+func (a *Account) newSession() string {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		panic(err) // crypto/rand does not fail on supported platforms
+	}
+	token := hex.EncodeToString(raw)
+	a.Sessions = append(a.Sessions, hashToken(token))
+	if len(a.Sessions) > maxSessions {
+		a.Sessions = a.Sessions[len(a.Sessions)-maxSessions:]
+	}
+	return token
+}
+
+// public returns a copy of the account without its secrets.
+func (a *Account) public() Account {
+	return Account{Username: a.Username, PlayerID: a.PlayerID, Profile: a.Profile, Created: a.Created}
+}
+
+func (p Profile) validate() error {
+	if len(p.DisplayName) > 40 {
+		return fmt.Errorf("%w: a display name is at most 40 characters", ErrInvalid)
+	}
+	if p.ChipColor == "" {
+		return nil
+	}
+	for _, color := range game.ChipColors {
+		if p.ChipColor == color {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unknown chip colour %q", ErrInvalid, p.ChipColor)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,178 @@
+package accounts
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// registered opens a store kept in a temporary file with one account, "alice",
+// whose password is "correct horse".
+func registered(t *testing.T) (*Store, Account) {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "accounts.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, _, err := s.Register("alice", "correct horse", Profile{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, account
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		wantErr  error
+	}{
+		{name: "right password", username: "alice", password: "correct horse"},
+		{name: "username in another case", username: "ALICE", password: "correct horse"},
+		{name: "wrong password", username: "alice", password: "battery staple", wantErr: ErrBadCredentials},
+		{name: "unknown username", username: "bob", password: "correct horse", wantErr: ErrBadCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, alice := registered(t)
+			account, token, err := s.Login(tt.username, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Login error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if token != "" {
+					t.Error("a failed login returned a session token")
+				}
+				return
+			}
+			if account.PlayerID != alice.PlayerID || account.PasswordHash != "" || account.Sessions != nil {
+				t.Errorf("Login returned %+v, want alice's public account", account)
+			}
+			if got, err := s.Session(token); err != nil || got.PlayerID != alice.PlayerID {
+				t.Errorf("Session(token) = %+v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	tests := []struct {
+		name     string
+		failed   string // Username the wrong passwords were tried on
+		failures int
+		expired  bool   // The window of those failures has closed
+		login    string // Username then logged in with the right password
+		wantErr  error
+	}{
+		{name: "under the limit", failed: "alice", failures: maxLoginFailures - 1, login: "alice"},
+		{name: "at the limit", failed: "alice", failures: maxLoginFailures, login: "alice", wantErr: ErrTooManyLogins},
+		{name: "window closed", failed: "alice", failures: maxLoginFailures, expired: true, login: "alice"},
+		{name: "other usernames unaffected", failed: "alice", failures: maxLoginFailures, login: "carol"},
+		{name: "unknown usernames too", failed: "bob", failures: maxLoginFailures, login: "bob", wantErr: ErrTooManyLogins},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := registered(t)
+			if _, _, err := s.Register("carol", "correct horse", Profile{}); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.failures; i++ {
+				if _, _, err := s.Login(tt.failed, "wrong password"); !errors.Is(err, ErrBadCredentials) || errors.Is(err, ErrTooManyLogins) {
+					t.Fatalf("attempt %d: Login error = %v, want %v", i+1, err, ErrBadCredentials)
+				}
+			}
+			if tt.expired {
+				s.failures[tt.failed].until = time.Now().Add(-time.Second)
+			}
+
+			if _, _, err := s.Login(tt.login, "correct horse"); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Login error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && s.failures[tt.login] != nil {
+				t.Error("a successful login kept its failures")
+			}
+		})
+	}
+}
+
+func TestSessionCap(t *testing.T) {
+	s, _ := registered(t) // Registering logs in once
+	var tokens []string
+	for i := 0; i < maxSessions+2; i++ {
+		_, token, err := s.Login("alice", "correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, token)
+	}
+	if n := len(s.accounts["alice"].Sessions); n != maxSessions {
+		t.Errorf("%d sessions kept, want %d", n, maxSessions)
+	}
+	for i, token := range tokens {
+		_, err := s.Session(token)
+		if live := i >= len(tokens)-maxSessions; live != (err == nil) {
+			t.Errorf("login %d: Session error = %v, want live %t", i+1, err, live)
+		}
+	}
+
+	last := tokens[len(tokens)-1]
+	if err := s.Logout(last); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Session(last); !errors.Is(err, ErrNoSession) {
+		t.Errorf("Session after Logout error = %v, want %v", err, ErrNoSession)
+	}
+}
+
+func TestTokensStoredHashed(t *testing.T) {
+	s, _ := registered(t)
+	_, token, err := s.Login("alice", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), token) || strings.Contains(string(data), "correct horse") {
+		t.Error("the accounts file holds a session token or password in the clear")
+	}
+	if !strings.Contains(string(data), hashToken(token)) {
+		t.Error("the accounts file lacks the session's hash")
+	}
+
+	reopened, err := Open(s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account, err := reopened.Session(token); err != nil || account.Username != "alice" {
+		t.Errorf("Session after reopening = %+v, %v", account, err)
+	}
+	if _, err := reopened.Session(hashToken(token)); !errors.Is(err, ErrNoSession) {
+		t.Errorf("the stored hash works as a token: error = %v", err)
+	}
+}
+
+func TestOwnsPlayerID(t *testing.T) {
+	s, alice := registered(t)
+	tests := []struct {
+		name     string
+		playerID string
+		want     bool
+	}{
+		{name: "account's player ID", playerID: alice.PlayerID, want: true},
+		{name: "guest player ID", playerID: "guest-1234"},
+		{name: "empty", playerID: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.OwnsPlayerID(tt.playerID); got != tt.want {
+				t.Errorf("OwnsPlayerID(%q) = %t, want %t", tt.playerID, got, tt.want)
+			}
+		})
+	}
+}
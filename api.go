@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"sequence-game/history"
+	"sequence-game/ratings"
+)
+
+// --- REST API ---
+
+// publicID is how the API names a player. A guest's player ID is all it takes to
+// sit in their seat, so guests appear under a hash of it instead; an account's ID
+// is shown as it is, since it only works with the account's session.
+func publicID(playerID string) string {
+	if playerID == "" || accountStore.OwnsPlayerID(playerID) {
+		return playerID
+	}
+	sum := sha256.Sum256([]byte("sequence-guest:" + playerID))
+	return "guest-" + hex.EncodeToString(sum[:8])
+}
+
+// publicRecord is a game summary with its players under their public IDs.
+func publicRecord(record history.Record) history.Record {
+	record.Winner = publicID(record.Winner)
+	record.Players = append([]history.Participant(nil), record.Players...)
+	for i := range record.Players {
+		record.Players[i].PlayerID = publicID(record.Players[i].PlayerID)
+	}
+	return record
+}
+
+// servePlayerStats answers GET /api/players/{id}/stats with the player's record
+// over every finished game. The stats name the player by their public ID.
+func servePlayerStats(w http.ResponseWriter, r *http.Request) {
+	stats := historyStore.Player(r.PathValue("id"))
+	stats.PlayerID = publicID(stats.PlayerID)
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// serveGameSummary answers GET /api/games/{id}/summary: the recorded summary of a
+// finished game, or the summary so far of a game being played, with the players
+// under their public IDs.
+func serveGameSummary(w http.ResponseWriter, r *http.Request) {
+	gameID := r.PathValue("id")
+	if record, ok := historyStore.Game(gameID); ok {
+		writeJSON(w, http.StatusOK, publicRecord(record))
+		return
+	}
+	gamesMu.Lock()
+	g, ok := games[gameID]
+	gamesMu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "game not found"})
+		return
+	}
+	g.Lock()
+	record := history.NewRecord(g)
+	g.Unlock()
+	writeJSON(w, http.StatusOK, publicRecord(record))
+}
+
+// leaderboardWindows are the time windows GET /api/leaderboard accepts.
+var leaderboardWindows = map[string]time.Duration{
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"year":  365 * 24 * time.Hour,
+	"all":   0,
+}
+
+// leaderboard is the response of GET /api/leaderboard.
+type leaderboard struct {
+	Mode      ratings.Mode       `json:"mode"`
+	Window    string             `json:"window"`
+	Since     *time.Time         `json:"since,omitempty"` // Start of the window; absent for "all"
+	Standings []ratings.Standing `json:"standings"`
+}
+
+// serveLeaderboard answers GET /api/leaderboard?mode=1v1|ffa|2v2|3-team&window=day|week|month|year|all
+// with the players, under their public IDs, ranked by rating. Only ranked games
+// count; mode defaults to 1v1 and window to all.
+func serveLeaderboard(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	board := leaderboard{Mode: ratings.ModeDuel, Window: "all"}
+	if name := query.Get("mode"); name != "" {
+		mode, err := ratings.ParseMode(name)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		board.Mode = mode
+	}
+	if name := query.Get("window"); name != "" {
+		board.Window = name
+	}
+	window, ok := leaderboardWindows[board.Window]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unknown window %q (use day, week, month, year or all)", board.Window)})
+		return
+	}
+	var since time.Time
+	if window > 0 {
+		since = time.Now().Add(-window)
+		board.Since = &since
+	}
+	board.Standings = ratingStore.Leaderboard(board.Mode, since)
+	for i := range board.Standings {
+		board.Standings[i].PlayerID = publicID(board.Standings[i].PlayerID)
+	}
+	writeJSON(w, http.StatusOK, board)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing API response: %v", err)
+	}
+}
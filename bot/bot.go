@@ -0,0 +1,136 @@
+// Package bot holds computer players that drive the game engine directly. They
+// are used for offline play in the desktop client and by the arena; the expert
+// (MCTS) also suggests moves as hints.
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"sequence-game/game"
+)
+
+// Move is a bot's decision for its turn: either a play, or a dead card to turn in
+// before deciding again.
+type Move struct {
+	Action   game.PlayerAction
+	DeadCard string
+}
+
+// Strategy chooses moves for a seat. Choose is only called on that seat's turn
+// and must not modify g; it reports false if it has no move at all.
+type Strategy interface {
+	Name() string
+	Choose(g *game.Game, playerID string) (Move, bool)
+}
+
+// strategies maps each strategy name to its constructor; seed makes the choices reproducible.
+var strategies = map[string]func(seed int64) Strategy{
+	"random": func(seed int64) Strategy { return &Random{rng: rand.New(rand.NewSource(seed))} },
+	"greedy": func(seed int64) Strategy { return &Greedy{rng: rand.New(rand.NewSource(seed))} },
+	"expert": func(seed int64) Strategy { return NewMCTS(seed, DefaultThinkTime) },
+}
+
+// New returns the named strategy (case-insensitive).
+func New(name string, seed int64) (Strategy, error) {
+	newStrategy, ok := strategies[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown bot strategy %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	return newStrategy(seed), nil
+}
+
+// Names lists the available strategies.
+func Names() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TakeTurn plays the player's whole turn with the strategy: any dead cards, the play
+// itself and, under the explicit-draw rule, the draw.
+func TakeTurn(g *game.Game, playerID string, s Strategy) error {
+	for {
+		move, ok := s.Choose(g, playerID)
+		if !ok {
+			return fmt.Errorf("%s bot found no move for player %s", s.Name(), playerID)
+		}
+		if move.DeadCard != "" {
+			if err := g.HandleDeadCard(playerID, move.DeadCard); err != nil {
+				return err
+			}
+			if g.CurrentPlayerID() != playerID {
+				return nil // The dead card ended the turn
+			}
+			continue
+		}
+		return play(g, playerID, move.Action)
+	}
+}
+
+// play makes the move and, under the explicit-draw rule, the draw that follows it.
+func play(g *game.Game, playerID string, action game.PlayerAction) error {
+	if err := g.PlayAction(playerID, action); err != nil {
+		return err
+	}
+	g.Lock()
+	mustDraw := g.PendingDraw == playerID && g.GamePhase == "InProgress"
+	g.Unlock()
+	if mustDraw {
+		err := g.HandleDraw(playerID)
+		if errors.Is(err, &game.ActionError{Code: game.CodeDrawPileEmpty}) {
+			return nil // Hands shrink once the pile runs out under the play-out rule
+		}
+		return err
+	}
+	return nil
+}
+
+// deadCardToTurnIn returns a dead card the player may turn in now, if any.
+func deadCardToTurnIn(g *game.Game, playerID string) (string, bool) {
+	g.Lock()
+	used := g.DeadCardUsed
+	g.Unlock()
+	if used && g.Rules.DeadCards == game.DeadCardOncePerTurn {
+		return "", false
+	}
+	dead := g.DeadCards(playerID)
+	if len(dead) == 0 {
+		return "", false
+	}
+	return dead[0], true
+}
+
+// sameSide reports whether chips of a and b count for the same side: they are the
+// same player, or teammates.
+func sameSide(g *game.Game, a, b string) bool {
+	if a == b {
+		return true
+	}
+	pa, pb := g.Players[a], g.Players[b]
+	return pa != nil && pb != nil && pa.Side() == pb.Side()
+}
+
+// Random plays a uniformly random legal move, turning in dead cards first.
+type Random struct {
+	rng *rand.Rand
+}
+
+func (r *Random) Name() string { return "random" }
+
+func (r *Random) Choose(g *game.Game, playerID string) (Move, bool) {
+	if card, ok := deadCardToTurnIn(g, playerID); ok {
+		return Move{DeadCard: card}, true
+	}
+	moves := g.LegalMoves(playerID)
+	if len(moves) == 0 {
+		return Move{}, false
+	}
+	return Move{Action: moves[r.rng.Intn(len(moves))]}, true
+}
@@ -0,0 +1,131 @@
+package bot
+
+import (
+	"math/rand"
+	"sort"
+
+	"sequence-game/game"
+)
+
+// directions are the four line directions a sequence can run in.
+var directions = []game.Position{{X: 0, Y: 1}, {X: 1, Y: 0}, {X: 1, Y: 1}, {X: 1, Y: -1}}
+
+// Jack penalties keep the greedy bot from spending Jacks on ordinary moves.
+const (
+	wildJackCost   = 15
+	removeJackCost = 20
+)
+
+// Greedy scores every legal move one ply deep: how much it extends its own lines,
+// how much it blocks opponents' lines, and what Jacks it spends. Ties are broken randomly.
+type Greedy struct {
+	rng *rand.Rand
+}
+
+func (b *Greedy) Name() string { return "greedy" }
+
+func (b *Greedy) Choose(g *game.Game, playerID string) (Move, bool) {
+	if card, ok := deadCardToTurnIn(g, playerID); ok {
+		return Move{DeadCard: card}, true
+	}
+	moves := g.LegalMoves(playerID)
+	if len(moves) == 0 {
+		return Move{}, false
+	}
+
+	best, bestScore, ties := moves[0], -1<<31, 0
+	for _, move := range moves {
+		score := b.score(g, playerID, move)
+		switch {
+		case score > bestScore:
+			best, bestScore, ties = move, score, 1
+		case score == bestScore:
+			ties++
+			if b.rng.Intn(ties) == 0 {
+				best = move
+			}
+		}
+	}
+	return Move{Action: best}, true
+}
+
+// ranked returns the best n moves by score, best first; ties fall in random order.
+func (b *Greedy) ranked(g *game.Game, playerID string, moves []game.PlayerAction, n int) []game.PlayerAction {
+	scores := make(map[actionKey]int, len(moves))
+	ranked := append([]game.PlayerAction(nil), moves...)
+	b.rng.Shuffle(len(ranked), func(i, j int) { ranked[i], ranked[j] = ranked[j], ranked[i] })
+	for _, move := range ranked {
+		scores[keyOf(move)] = b.score(g, playerID, move)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return scores[keyOf(ranked[i])] > scores[keyOf(ranked[j])] })
+	if len(ranked) > n {
+		ranked = ranked[:n]
+	}
+	return ranked
+}
+
+func (b *Greedy) score(g *game.Game, playerID string, move game.PlayerAction) int {
+	switch move.MoveKind {
+	case game.MoveRemove:
+		owner := g.Board[move.BoardPos.X][move.BoardPos.Y].OccupiedBy
+		return lineValue(g, owner, move.BoardPos) - removeJackCost
+	case game.MoveSwap:
+		owner := g.Board[move.BoardPos.X][move.BoardPos.Y].OccupiedBy
+		return lineValue(g, owner, move.BoardPos) - lineValue(g, owner, *move.TargetPos) - removeJackCost
+	}
+
+	score := 10 * lineValue(g, playerID, move.BoardPos)
+	for opponentID := range g.Players {
+		if !sameSide(g, opponentID, playerID) {
+			score += 8 * lineValue(g, opponentID, move.BoardPos)
+		}
+	}
+	if move.MoveKind == game.MoveWild {
+		score -= wildJackCost
+	}
+	return score
+}
+
+// lineValue rates pos for owner: the square of lineCount, except that a window one
+// chip short of a sequence scores far more.
+func lineValue(g *game.Game, owner string, pos game.Position) int {
+	best := lineCount(g, owner, pos)
+	if best >= g.Rules.SequenceLength-1 {
+		return 100
+	}
+	return best * best
+}
+
+// lineCount is the most of owner's and their teammates' chips (free corners
+// count), not counting pos itself, in any window of SequenceLength spaces through
+// pos that no other side's chip blocks.
+func lineCount(g *game.Game, owner string, pos game.Position) int {
+	length := g.Rules.SequenceLength
+	best := 0
+	for _, dir := range directions {
+		for start := -(length - 1); start <= 0; start++ {
+			count, blocked := 0, false
+			for i := 0; i < length && !blocked; i++ {
+				p := game.Position{X: pos.X + (start+i)*dir.X, Y: pos.Y + (start+i)*dir.Y}
+				if p.X < 0 || p.X >= game.BoardSize || p.Y < 0 || p.Y >= game.BoardSize {
+					blocked = true
+					break
+				}
+				if p == pos {
+					continue
+				}
+				space := g.Board[p.X][p.Y]
+				switch {
+				case space.IsCorner && g.Rules.CornersFree, space.OccupiedBy != "" && sameSide(g, space.OccupiedBy, owner):
+					count++
+				case space.OccupiedBy != "":
+					blocked = true
+				}
+			}
+			if !blocked && count > best {
+				best = count
+			}
+		}
+	}
+	return best
+}
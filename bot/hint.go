@@ -0,0 +1,222 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	"sequence-game/game"
+)
+
+// Suggestion is a recommended move with the reasons for it, in plain words.
+type Suggestion struct {
+	Move
+	Reasons []string
+}
+
+// Suggest returns up to n suggestions for the player's turn, best first, ranked by
+// an expert search of thinkTime. It works on a copy, so g may change meanwhile.
+func Suggest(g *game.Game, playerID string, n int, thinkTime time.Duration) []Suggestion {
+	g = g.Clone()
+	var suggestions []Suggestion
+	if card, ok := deadCardToTurnIn(g, playerID); ok {
+		suggestions = append(suggestions, Suggestion{Move: Move{DeadCard: card}, Reasons: []string{"turn in this dead card for a fresh one first"}})
+	}
+	for i, action := range NewMCTS(time.Now().UnixNano(), thinkTime).Rank(g, playerID, n) {
+		reasons := explain(g, playerID, action)
+		if len(reasons) == 0 && i == 0 {
+			reasons = []string{"leaves you best placed a few moves ahead"}
+		} else if len(reasons) == 0 {
+			reasons = []string{"a reasonable alternative"}
+		}
+		suggestions = append(suggestions, Suggestion{Move: Move{Action: action}, Reasons: reasons})
+	}
+	return suggestions
+}
+
+// explain describes what a play does: sequences it completes, lines it builds,
+// blocks or breaks, and whether it keeps a Jack in hand.
+func explain(g *game.Game, playerID string, action game.PlayerAction) []string {
+	var reasons []string
+	player := g.Players[playerID]
+	after := g.Clone()
+	completes := play(after, playerID, action) == nil && after.Players[playerID].Sequences > player.Sequences
+	switch {
+	case completes && after.Winner == playerID:
+		reasons = append(reasons, "wins the game")
+	case completes:
+		reasons = append(reasons, "completes your sequence")
+	}
+
+	pos := action.BoardPos
+	switch action.MoveKind {
+	case game.MoveRemove, game.MoveSwap:
+		owner := g.Board[pos.X][pos.Y].OccupiedBy
+		if n := lineCount(g, owner, pos) + 1; n >= 3 {
+			reasons = append(reasons, fmt.Sprintf("breaks %s's %d-in-a-row", colorOf(g, owner), n))
+		}
+	default:
+		if n := lineCount(g, playerID, pos) + 1; !completes && n >= 3 {
+			reasons = append(reasons, fmt.Sprintf("makes your %d-in-a-row", n))
+		}
+		blocked := make(map[string]bool) // Sides already named, so a team's line is named once
+		for _, opponentID := range g.PlayerOrder {
+			side := g.Players[opponentID].Side()
+			if n := lineCount(g, opponentID, pos); !sameSide(g, opponentID, playerID) && !blocked[side] && n >= 3 {
+				blocked[side] = true
+				reasons = append(reasons, fmt.Sprintf("blocks %s's %d-in-a-row", colorOf(g, opponentID), n))
+			}
+		}
+		for _, double := range g.Threats().DoubleThreats {
+			switch {
+			case double.Pos != pos:
+			case sameSide(g, double.PlayerID, playerID):
+				reasons = append(reasons, "sets up two ways to complete a sequence at once")
+			default:
+				reasons = append(reasons, fmt.Sprintf("takes the space that would give %s two threats at once", colorOf(g, double.PlayerID)))
+			}
+		}
+		if action.MoveKind == game.MovePlace && holdsJack(g, player) {
+			reasons = append(reasons, "saves your Jack for later")
+		}
+	}
+	return reasons
+}
+
+// Verdict grades a move in a MoveAnalysis.
+type Verdict string
+
+const (
+	VerdictGood       Verdict = "GOOD"
+	VerdictInaccuracy Verdict = "INACCURACY" // A Jack spent where a plain card or nothing at all would do
+	VerdictBlunder    Verdict = "BLUNDER"    // A sequence missed, or an opponent's sequence left open
+)
+
+// Analysis reviews a move after it was made.
+type Analysis struct {
+	Verdict Verdict
+	Notes   []string
+	Better  *game.PlayerAction // A move that avoids the blunder, if there was one
+	Threats game.ThreatReport  // Every player's threats on the board after the move
+}
+
+// Analyze reviews the play the player made from the position before, which must
+// be a copy taken just before the play. It flags a sequence the player could have
+// completed, an opponent's sequence left one chip short when it could have been
+// blocked, and Jacks spent for nothing.
+func Analyze(before *game.Game, playerID string, played game.PlayerAction) Analysis {
+	analysis := Analysis{Verdict: VerdictGood}
+	player := before.Players[playerID]
+	moves := before.LegalMoves(playerID)
+	after := before.Clone()
+	if play(after, playerID, played) != nil {
+		return analysis
+	}
+	analysis.Threats = after.Threats()
+	if after.Players[playerID].Sequences > player.Sequences {
+		return analysis // Completing a sequence is never a mistake
+	}
+
+	for _, move := range moves {
+		if move.MoveKind == game.MoveRemove || move.MoveKind == game.MoveSwap ||
+			lineCount(before, playerID, move.BoardPos) < before.Rules.SequenceLength-1 {
+			continue
+		}
+		trial := before.Clone()
+		if play(trial, playerID, move) == nil && trial.Players[playerID].Sequences > player.Sequences {
+			analysis.blunder(fmt.Sprintf("missed completing a sequence with %s on %s", cardName(move.CardID), spaceName(before, move.BoardPos)), move)
+			break
+		}
+	}
+
+	if open := openThreats(after, playerID); len(open) > 0 && after.GamePhase == "InProgress" {
+		fewest, blocking := len(open), -1
+		for i, move := range moves {
+			trial := before.Clone()
+			if play(trial, playerID, move) != nil {
+				continue
+			}
+			if n := len(openThreats(trial, playerID)); n < fewest {
+				fewest, blocking = n, i
+			}
+		}
+		if blocking >= 0 {
+			threat := open[0]
+			analysis.blunder(fmt.Sprintf("left %s's %d-in-a-row open on %s", colorOf(before, threat.PlayerID),
+				threat.Chips, spaceName(before, threat.Missing[0].Pos)), moves[blocking])
+		}
+	}
+	if analysis.Verdict == VerdictBlunder {
+		return analysis
+	}
+
+	card, err := game.ParseCardID(played.CardID)
+	if err != nil {
+		return analysis
+	}
+	kind, _ := before.Rules.Jacks.ResolveMove(card, played.MoveKind)
+	pos := played.BoardPos
+	switch kind {
+	case game.MoveWild:
+		if space := before.Board[pos.X][pos.Y]; space.Card != nil {
+			if _, ok := player.GetCardFromHand(space.Card.ID); ok {
+				analysis.Verdict = VerdictInaccuracy
+				analysis.Notes = append(analysis.Notes, fmt.Sprintf("used a Jack where your %s would do; save this Jack", space.Card.ToEmojiString()))
+			}
+		}
+	case game.MoveRemove:
+		owner := before.Board[pos.X][pos.Y].OccupiedBy
+		if lineCount(before, owner, pos)+1 < 3 {
+			analysis.Verdict = VerdictInaccuracy
+			analysis.Notes = append(analysis.Notes, "spent a Jack removing a chip that threatened nothing; save this Jack")
+		}
+	}
+	return analysis
+}
+
+func (a *Analysis) blunder(note string, better game.PlayerAction) {
+	if a.Better == nil {
+		a.Better = &better
+	}
+	a.Verdict = VerdictBlunder
+	a.Notes = append(a.Notes, note)
+}
+
+// openThreats lists the lines in which one of playerID's opponents is a chip away
+// from a sequence.
+func openThreats(g *game.Game, playerID string) []game.Threat {
+	var threats []game.Threat
+	for _, threat := range g.Threats().Threats {
+		if !sameSide(g, threat.PlayerID, playerID) && len(threat.Missing) == 1 {
+			threats = append(threats, threat)
+		}
+	}
+	return threats
+}
+
+func holdsJack(g *game.Game, player *game.Player) bool {
+	for i := range player.Hand {
+		if g.Rules.Jacks.HasPower(&player.Hand[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func colorOf(g *game.Game, playerID string) string {
+	if p, ok := g.Players[playerID]; ok {
+		return p.ChipColor
+	}
+	return playerID
+}
+
+func cardName(cardID string) string {
+	if card, err := game.ParseCardID(cardID); err == nil {
+		return card.ToEmojiString()
+	}
+	return cardID
+}
+
+// spaceName names a board space the way players read it: its card, row and column.
+func spaceName(g *game.Game, pos game.Position) string {
+	return fmt.Sprintf("%s (row %d, column %d)", g.Board[pos.X][pos.Y].DisplayValue, pos.X+1, pos.Y+1)
+}
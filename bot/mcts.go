@@ -0,0 +1,257 @@
+package bot
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"sequence-game/game"
+)
+
+// DefaultThinkTime is how long the expert bot searches per move unless told otherwise.
+const DefaultThinkTime = time.Second
+
+// Search tuning: the UCB exploration constant, how many of the greedy policy's
+// favourite moves the tree considers in each position, and how many greedy moves a
+// playout makes before the position is scored. Short playouts scored by a line
+// count beat long noisy ones in the arena.
+const (
+	exploration   = 0.25
+	maxCandidates = 8
+	playoutDepth  = 4
+)
+
+// Scoring of unfinished playouts: a completed sequence is worth sequenceWorth, and
+// a lead of evaluationScale makes a player roughly a 3:1 favourite.
+const (
+	sequenceWorth   = 400
+	evaluationScale = 60.0
+)
+
+// MCTS is the expert bot: Monte Carlo Tree Search over the engine's legal moves.
+// The other hands and the order of the draw pile are hidden, so each iteration
+// searches a different determinization of the game (see game.Determinize), and all
+// of them share one tree in which a move is only considered where it is legal
+// (information set MCTS). The greedy policy narrows each position down to its
+// favourite few moves and makes the moves of the playouts.
+type MCTS struct {
+	ThinkTime  time.Duration // Search time per move; DefaultThinkTime when zero
+	Iterations int           // When set, search exactly this many iterations instead, for reproducible play
+	rng        *rand.Rand
+	policy     *Greedy
+}
+
+// NewMCTS returns an expert bot that thinks for thinkTime per move.
+func NewMCTS(seed int64, thinkTime time.Duration) *MCTS {
+	rng := rand.New(rand.NewSource(seed))
+	return &MCTS{ThinkTime: thinkTime, rng: rng, policy: &Greedy{rng: rng}}
+}
+
+func (m *MCTS) Name() string { return "expert" }
+
+func (m *MCTS) Choose(g *game.Game, playerID string) (Move, bool) {
+	if card, ok := deadCardToTurnIn(g, playerID); ok {
+		return Move{DeadCard: card}, true
+	}
+	ranked := m.Rank(g, playerID, 1)
+	if len(ranked) == 0 {
+		return Move{}, false
+	}
+	return Move{Action: ranked[0]}, true
+}
+
+// Rank searches the player's turn and returns up to n of their plays, the most
+// promising first. Only moves the search got to try are ranked.
+func (m *MCTS) Rank(g *game.Game, playerID string, n int) []game.PlayerAction {
+	moves := g.LegalMoves(playerID)
+	if len(moves) <= 1 {
+		return moves
+	}
+
+	thinkTime := m.ThinkTime
+	if thinkTime <= 0 {
+		thinkTime = DefaultThinkTime
+	}
+	deadline := time.Now().Add(thinkTime)
+	root := &node{children: make(map[actionKey]*node)}
+	for i := 0; ; i++ {
+		if m.Iterations > 0 && i >= m.Iterations || m.Iterations <= 0 && i > 0 && time.Now().After(deadline) {
+			break
+		}
+		m.iterate(root, g.Determinize(playerID, m.rng))
+	}
+
+	var tried []*node
+	for _, move := range moves {
+		if child := root.children[keyOf(move)]; child != nil {
+			tried = append(tried, child)
+		}
+	}
+	sort.SliceStable(tried, func(i, j int) bool { return tried[i].visits > tried[j].visits })
+	if len(tried) > n {
+		tried = tried[:n]
+	}
+	ranked := make([]game.PlayerAction, len(tried))
+	for i, child := range tried {
+		ranked[i] = child.action
+	}
+	return ranked
+}
+
+// actionKey identifies a move in the tree; PlayerAction itself holds a pointer.
+type actionKey struct {
+	cardID    string
+	pos       game.Position
+	kind      game.MoveKind
+	target    game.Position
+	hasTarget bool
+}
+
+func keyOf(action game.PlayerAction) actionKey {
+	key := actionKey{cardID: action.CardID, pos: action.BoardPos, kind: action.MoveKind}
+	if action.TargetPos != nil {
+		key.target, key.hasTarget = *action.TargetPos, true
+	}
+	return key
+}
+
+// node is a move in the search tree. Its statistics are from the point of view
+// of the player who made the move.
+type node struct {
+	action   game.PlayerAction
+	player   string
+	parent   *node
+	children map[actionKey]*node
+	visits   int
+	avail    int // Iterations in which the move was legal, standing in for the parent's visits
+	reward   float64
+}
+
+// iterate runs one iteration on a determinized copy: it walks down the tree while
+// every legal move has been tried, adds one untried move, plays on with the greedy
+// policy and credits the result to every move on the path.
+func (m *MCTS) iterate(root *node, sim *game.Game) {
+	n := root
+	for sim.GamePhase == "InProgress" {
+		player := sim.CurrentPlayerID()
+		if card, ok := deadCardToTurnIn(sim, player); ok {
+			if sim.HandleDeadCard(player, card) != nil {
+				break
+			}
+			continue
+		}
+		moves := m.policy.ranked(sim, player, sim.LegalMoves(player), maxCandidates)
+		if len(moves) == 0 {
+			break
+		}
+
+		var untried []game.PlayerAction
+		var best *node
+		bestScore := math.Inf(-1)
+		for _, move := range moves {
+			child, ok := n.children[keyOf(move)]
+			if !ok {
+				untried = append(untried, move)
+				continue
+			}
+			child.avail++
+			score := child.reward/float64(child.visits) + exploration*math.Sqrt(math.Log(float64(child.avail))/float64(child.visits))
+			if score > bestScore {
+				best, bestScore = child, score
+			}
+		}
+		if len(untried) > 0 {
+			move := untried[m.rng.Intn(len(untried))]
+			child := &node{action: move, player: player, parent: n, children: make(map[actionKey]*node), avail: 1}
+			n.children[keyOf(move)] = child
+			n = child
+			play(sim, player, move) // Should it fail, the unchanged position is scored instead
+			break
+		}
+		n = best
+		if play(sim, player, n.action) != nil {
+			break
+		}
+	}
+
+	for depth := 0; depth < playoutDepth && sim.GamePhase == "InProgress"; depth++ {
+		if TakeTurn(sim, sim.CurrentPlayerID(), m.policy) != nil {
+			break
+		}
+	}
+
+	rewards := evaluate(sim)
+	for ; n != nil; n = n.parent {
+		n.visits++
+		n.reward += rewards[n.player]
+	}
+}
+
+// evaluate scores the position between 0 (lost) and 1 (won) for every player. An
+// unfinished game is judged on sequences and the best unblocked line each player
+// is building, relative to the strongest opponent.
+func evaluate(g *game.Game) map[string]float64 {
+	rewards := make(map[string]float64, len(g.Players))
+	if g.GamePhase != "InProgress" {
+		for id := range g.Players {
+			switch {
+			case g.Winner == "":
+				rewards[id] = 0.5
+			case sameSide(g, g.Winner, id):
+				rewards[id] = 1
+			}
+		}
+		return rewards
+	}
+
+	strength := make(map[string]float64, len(g.Players))
+	for id, p := range g.Players {
+		strength[id] = float64(p.Sequences*sequenceWorth + potential(g, id))
+	}
+	for id := range g.Players {
+		rival := math.Inf(-1)
+		for other, s := range strength {
+			if !sameSide(g, other, id) && s > rival {
+				rival = s
+			}
+		}
+		rewards[id] = 1 / (1 + math.Exp(-(strength[id]-rival)/evaluationScale))
+	}
+	return rewards
+}
+
+// potential sums, over every window of SequenceLength spaces that no other side's
+// chip blocks, a weight that quadruples with each chip of owner's side in it (free
+// corners count). Completed windows are left to the sequence count.
+func potential(g *game.Game, owner string) int {
+	length := g.Rules.SequenceLength
+	total := 0
+	for x := 0; x < game.BoardSize; x++ {
+		for y := 0; y < game.BoardSize; y++ {
+			for _, dir := range directions {
+				endX, endY := x+(length-1)*dir.X, y+(length-1)*dir.Y
+				if endX < 0 || endX >= game.BoardSize || endY < 0 || endY >= game.BoardSize {
+					continue
+				}
+				count := 0
+				for i := 0; i < length; i++ {
+					space := g.Board[x+i*dir.X][y+i*dir.Y]
+					switch {
+					case space.IsCorner && g.Rules.CornersFree, space.OccupiedBy != "" && sameSide(g, space.OccupiedBy, owner):
+						count++
+					case space.OccupiedBy != "":
+						count = -1
+					}
+					if count < 0 {
+						break
+					}
+				}
+				if count > 0 && count < length {
+					total += 1 << (2 * (count - 1))
+				}
+			}
+		}
+	}
+	return total
+}
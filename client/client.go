@@ -0,0 +1,593 @@
+// Package client is a Go SDK for the Sequence server's /ws WebSocket protocol,
+// for writing bots and tools without handling the JSON yourself.
+//
+// Dial connects and performs the playerId handshake. The typed methods send
+// requests and return the request ID that any resulting ERROR will echo. Events
+// arrive on the Client's channels: States carries the full table state after
+// every snapshot or STATE_PATCH (patches are applied, and gaps resynced, for you),
+// Hands carries your hand whenever it changes. If the connection drops the client
+// reconnects with the same player ID and rejoins its game. After Login the client
+// plays as the account's player ID, and reconnects with its session token.
+//
+//	c, err := client.Dial("ws://localhost:8008/ws", client.Options{Store: "mybot", Reconnect: 3 * time.Second, Bot: true})
+//	c.CreateGame(protocol.CreateGame{PlayerName: "Bot"})
+//	for state := range c.States {
+//		if state.CurrentTurnPlayerID == c.PlayerID() {
+//			moves := state.LegalMoves(c.PlayerID(), c.Hand())
+//			...
+//		}
+//	}
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"sequence-game/accounts"
+	"sequence-game/game"
+	"sequence-game/protocol"
+)
+
+// eventBuffer is how many undelivered events each channel holds before the oldest is dropped.
+const eventBuffer = 64
+
+// Options configures Dial.
+type Options struct {
+	PlayerID string // Player ID to connect as; empty to use the stored one, or be assigned a new one
+	// Store, if set, names the file under the user config directory where the player
+	// ID is kept between runs (see LoadPlayerID), e.g. the name of your bot.
+	Store string
+	// Reconnect is the wait between attempts to reconnect after the connection drops.
+	// Zero disables reconnecting: the channels are closed instead.
+	Reconnect time.Duration
+	// SessionToken logs back in to an account (see Login and Session); the account's
+	// player ID then replaces PlayerID.
+	SessionToken string
+	// Bot declares the player a program. Bots cannot create, join or queue for
+	// ranked games, and their games are never rated.
+	Bot bool
+}
+
+// Status reports the connection dropping (Connected false, with the error) and coming back.
+type Status struct {
+	Connected bool
+	Err       error
+}
+
+// Client is a connection to a Sequence server. Events are delivered on the exported
+// channels, each buffered; a channel nobody reads drops its oldest events rather
+// than blocking the others. The channels are closed once the client is closed or
+// gives up reconnecting.
+type Client struct {
+	States   <-chan *protocol.GameState      // Table state after every snapshot or applied patch
+	Hands    <-chan *protocol.HandUpdate     // Your hand, whenever it changes
+	Errors   <-chan *protocol.Error          // Rejected requests, matched to them by RequestID
+	Handoffs <-chan *protocol.PassDevice     // Hot-seat games: the device must be passed on
+	Hints    <-chan *protocol.Hint           // Answers to RequestHint
+	Analyses <-chan *protocol.MoveAnalysis   // Reviews of your moves, once SetAnalysis turned them on
+	Accounts <-chan *protocol.Account        // Who you play as, after every account request
+	Queue    <-chan *protocol.QueueStatus    // Matchmaking: queued, left, or matched to a game
+	Shutdown <-chan *protocol.ServerShutdown // The server is stopping; the connection drops next
+	Status   <-chan Status                   // Connection lost and regained
+
+	states   chan *protocol.GameState
+	hands    chan *protocol.HandUpdate
+	errs     chan *protocol.Error
+	handoffs chan *protocol.PassDevice
+	hints    chan *protocol.Hint
+	analyses chan *protocol.MoveAnalysis
+	accounts chan *protocol.Account
+	queue    chan *protocol.QueueStatus
+	shutdown chan *protocol.ServerShutdown
+	status   chan Status
+
+	url  string
+	opts Options
+
+	writeMu     sync.Mutex // Serializes writes to conn
+	mu          sync.Mutex // Guards the fields below
+	conn        *websocket.Conn
+	playerID    string
+	session     string // Session token of the logged-in account
+	account     *protocol.Account
+	version     int
+	gameID      string // Game to rejoin after a reconnect
+	name        string // Name used to create or join it
+	state       *protocol.GameState
+	hand        []string
+	nextRequest int
+	closed      bool
+}
+
+// Dial connects to the server's WebSocket URL, e.g. "ws://localhost:8008/ws", and
+// completes the handshake.
+func Dial(url string, opts Options) (*Client, error) {
+	c := &Client{
+		url: url, opts: opts, playerID: opts.PlayerID, session: opts.SessionToken,
+		states: make(chan *protocol.GameState, eventBuffer), hands: make(chan *protocol.HandUpdate, eventBuffer),
+		errs: make(chan *protocol.Error, eventBuffer), handoffs: make(chan *protocol.PassDevice, eventBuffer),
+		hints: make(chan *protocol.Hint, eventBuffer), analyses: make(chan *protocol.MoveAnalysis, eventBuffer),
+		accounts: make(chan *protocol.Account, eventBuffer), queue: make(chan *protocol.QueueStatus, eventBuffer),
+		shutdown: make(chan *protocol.ServerShutdown, eventBuffer), status: make(chan Status, eventBuffer),
+	}
+	c.States, c.Hands, c.Errors, c.Handoffs, c.Status = c.states, c.hands, c.errs, c.handoffs, c.status
+	c.Hints, c.Analyses, c.Accounts, c.Queue, c.Shutdown = c.hints, c.analyses, c.accounts, c.queue, c.shutdown
+	if c.playerID == "" && opts.Store != "" {
+		c.playerID = LoadPlayerID(opts.Store)
+	}
+	conn, err := c.handshake()
+	if err != nil {
+		return nil, err
+	}
+	go c.readLoop(conn)
+	return c, nil
+}
+
+// handshake dials, sends the Hello and waits for the Welcome.
+func (c *Client) handshake() (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	hello := protocol.Hello{PlayerID: c.playerID, ProtocolVersion: protocol.Version, SessionToken: c.session, Bot: c.opts.Bot}
+	c.mu.Unlock()
+	if err := conn.WriteJSON(hello); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_, raw, err := conn.ReadMessage()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	event, err := DecodeEvent(raw)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	switch event := event.(type) {
+	case *protocol.Welcome:
+		c.mu.Lock()
+		c.conn, c.playerID, c.version = conn, event.PlayerID, event.ProtocolVersion
+		if c.account = event.Account; event.Account == nil {
+			c.session = "" // Expired, or logged out elsewhere
+		}
+		c.mu.Unlock()
+		if c.opts.Store != "" {
+			SavePlayerID(c.opts.Store, event.PlayerID)
+		}
+		return conn, nil
+	case *protocol.Error:
+		conn.Close()
+		return nil, fmt.Errorf("server refused connection [%s]: %s", event.Code, event.Error)
+	default:
+		conn.Close()
+		return nil, fmt.Errorf("expected %s, got %T", protocol.EventWelcome, event)
+	}
+}
+
+// readLoop delivers events until the connection drops, then reconnects if configured to.
+func (c *Client) readLoop(conn *websocket.Conn) {
+	defer c.closeChannels()
+	for {
+		err := c.read(conn)
+		if c.isClosed() || c.opts.Reconnect <= 0 {
+			return
+		}
+		offer(c.status, Status{Connected: false, Err: err})
+		for conn = nil; conn == nil; {
+			time.Sleep(c.opts.Reconnect)
+			if c.isClosed() {
+				return
+			}
+			conn, _ = c.handshake()
+		}
+		if c.isClosed() {
+			conn.Close()
+			return
+		}
+		offer(c.status, Status{Connected: true})
+		c.mu.Lock()
+		gameID, name := c.gameID, c.name
+		c.mu.Unlock()
+		if gameID != "" {
+			c.JoinGame(gameID, name)
+		}
+	}
+}
+
+func (c *Client) read(conn *websocket.Conn) error {
+	for {
+		_, raw, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		event, err := DecodeEvent(raw)
+		if err != nil {
+			continue // An event from a newer server
+		}
+		c.handle(event)
+	}
+}
+
+func (c *Client) handle(event interface{}) {
+	switch event := event.(type) {
+	case *protocol.GameState:
+		c.mu.Lock()
+		c.state, c.gameID = event, event.GameID
+		state := *event
+		c.mu.Unlock()
+		offer(c.states, &state)
+	case *protocol.StatePatch:
+		c.mu.Lock()
+		if c.state == nil || event.Version <= c.state.Version {
+			c.mu.Unlock()
+			return // Stale, or before the first snapshot
+		}
+		if err := c.state.Apply(event); err != nil {
+			c.mu.Unlock()
+			c.Resync()
+			return
+		}
+		state := *c.state // Apply replaces maps and slices rather than changing them, so a shallow copy is safe
+		c.mu.Unlock()
+		offer(c.states, &state)
+	case *protocol.HandUpdate:
+		c.mu.Lock()
+		c.hand = event.Hand
+		c.mu.Unlock()
+		offer(c.hands, event)
+	case *protocol.Error:
+		offer(c.errs, event)
+	case *protocol.PassDevice:
+		offer(c.handoffs, event)
+	case *protocol.Hint:
+		offer(c.hints, event)
+	case *protocol.MoveAnalysis:
+		offer(c.analyses, event)
+	case *protocol.Account:
+		c.mu.Lock()
+		c.playerID, c.account = event.PlayerID, event
+		if event.SessionToken != "" || event.Username == "" {
+			c.session = event.SessionToken
+		}
+		c.mu.Unlock()
+		if c.opts.Store != "" {
+			SavePlayerID(c.opts.Store, event.PlayerID)
+		}
+		offer(c.accounts, event)
+	case *protocol.QueueStatus:
+		offer(c.queue, event)
+	case *protocol.ServerShutdown:
+		offer(c.shutdown, event)
+	}
+}
+
+// offer delivers v on ch, dropping the oldest buffered value if ch is full.
+func offer[T any](ch chan T, v T) {
+	for {
+		select {
+		case ch <- v:
+			return
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+		}
+	}
+}
+
+func (c *Client) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *Client) closeChannels() {
+	close(c.states)
+	close(c.hands)
+	close(c.errs)
+	close(c.handoffs)
+	close(c.hints)
+	close(c.analyses)
+	close(c.accounts)
+	close(c.queue)
+	close(c.shutdown)
+	close(c.status)
+}
+
+// Close disconnects for good; the channels are closed shortly after.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	conn := c.conn
+	c.mu.Unlock()
+	return conn.Close()
+}
+
+// PlayerID is the ID the server knows this client by.
+func (c *Client) PlayerID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.playerID
+}
+
+// Session returns the token of the account logged in to, to pass as
+// Options.SessionToken next time, or "" for a guest.
+func (c *Client) Session() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session
+}
+
+// Account returns the account logged in to, or nil for a guest.
+func (c *Client) Account() *protocol.Account {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.account
+}
+
+// ProtocolVersion is the protocol version negotiated with the server.
+func (c *Client) ProtocolVersion() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.version
+}
+
+// State returns the latest table state, or nil before the first one arrives.
+func (c *Client) State() *protocol.GameState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == nil {
+		return nil
+	}
+	state := *c.state
+	return &state
+}
+
+// Hand returns the card IDs last sent as your hand.
+func (c *Client) Hand() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.hand...)
+}
+
+// --- Requests ---
+
+// ErrNoGame is returned by requests that need a game before one was created or joined.
+var ErrNoGame = errors.New("not in a game")
+
+// Send wraps payload in a ClientMessage with a fresh request ID and sends it. The
+// typed methods below cover every action; Send is for anything newer.
+func (c *Client) Send(action protocol.ActionType, payload interface{}) (string, error) {
+	c.mu.Lock()
+	c.nextRequest++
+	requestID := strconv.Itoa(c.nextRequest)
+	conn := c.conn
+	c.mu.Unlock()
+
+	msg, err := protocol.NewClientMessage(action, requestID, payload)
+	if err != nil {
+		return "", err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return requestID, conn.WriteJSON(msg)
+}
+
+// currentGame returns the ID of the game created or joined last.
+func (c *Client) currentGame() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gameID == "" {
+		return "", ErrNoGame
+	}
+	return c.gameID, nil
+}
+
+// CreateGame creates a lobby with this client as host.
+func (c *Client) CreateGame(req protocol.CreateGame) (string, error) {
+	c.mu.Lock()
+	c.name = req.PlayerName
+	c.mu.Unlock()
+	return c.Send(protocol.ActionCreateGame, req)
+}
+
+// QueueForMatch asks the matchmaker for a game; its answers arrive on Queue, and
+// once matched the started game arrives on States as after JoinGame.
+func (c *Client) QueueForMatch(req protocol.QueueForMatch) (string, error) {
+	c.mu.Lock()
+	c.name = req.PlayerName
+	c.mu.Unlock()
+	return c.Send(protocol.ActionQueueForMatch, req)
+}
+
+// LeaveQueue stops waiting for a match.
+func (c *Client) LeaveQueue() (string, error) {
+	return c.Send(protocol.ActionLeaveQueue, protocol.LeaveQueue{})
+}
+
+// JoinGame joins a lobby, or rejoins a game in progress.
+func (c *Client) JoinGame(gameID, playerName string) (string, error) {
+	c.mu.Lock()
+	c.gameID, c.name = gameID, playerName
+	c.mu.Unlock()
+	return c.Send(protocol.ActionJoinGame, protocol.JoinGame{GameID: gameID, PlayerName: playerName})
+}
+
+// StartGame deals the cards; only the host may start the game.
+func (c *Client) StartGame() (string, error) {
+	gameID, err := c.currentGame()
+	if err != nil {
+		return "", err
+	}
+	return c.Send(protocol.ActionStartGame, protocol.StartGame{GameID: gameID})
+}
+
+// Play plays a card from your hand, e.g. one of State().LegalMoves(PlayerID(), Hand()).
+func (c *Client) Play(action game.PlayerAction) (string, error) {
+	gameID, err := c.currentGame()
+	if err != nil {
+		return "", err
+	}
+	return c.Send(protocol.ActionPlay, protocol.Play{GameID: gameID, PlayerAction: action})
+}
+
+// DeclareDead turns in a card whose spaces are all covered.
+func (c *Client) DeclareDead(cardID string) (string, error) {
+	gameID, err := c.currentGame()
+	if err != nil {
+		return "", err
+	}
+	return c.Send(protocol.ActionDeadCard, protocol.DeadCard{GameID: gameID, CardID: cardID})
+}
+
+// Draw draws the card owed after a play under the explicit-draw rule.
+func (c *Client) Draw() (string, error) {
+	gameID, err := c.currentGame()
+	if err != nil {
+		return "", err
+	}
+	return c.Send(protocol.ActionDrawCard, protocol.DrawCard{GameID: gameID})
+}
+
+// RevealHand confirms, in a hot-seat game, that the device is with playerID.
+func (c *Client) RevealHand(playerID string) (string, error) {
+	gameID, err := c.currentGame()
+	if err != nil {
+		return "", err
+	}
+	return c.Send(protocol.ActionRevealHand, protocol.RevealHand{GameID: gameID, PlayerID: playerID})
+}
+
+// RequestHint asks for suggested moves on your turn; the answer arrives on Hints.
+func (c *Client) RequestHint() (string, error) {
+	gameID, err := c.currentGame()
+	if err != nil {
+		return "", err
+	}
+	return c.Send(protocol.ActionRequestHint, protocol.RequestHint{GameID: gameID})
+}
+
+// SetAnalysis turns the review of your moves on or off; reviews arrive on Analyses.
+func (c *Client) SetAnalysis(enabled bool) (string, error) {
+	gameID, err := c.currentGame()
+	if err != nil {
+		return "", err
+	}
+	return c.Send(protocol.ActionSetAnalysis, protocol.SetAnalysis{GameID: gameID, Enabled: enabled})
+}
+
+// Register creates an account and logs in to it; the answer arrives on Accounts.
+// Account requests are only accepted outside a game.
+func (c *Client) Register(username, password string, profile *accounts.Profile) (string, error) {
+	return c.Send(protocol.ActionRegister, protocol.Register{Username: username, Password: password, Profile: profile})
+}
+
+// Login logs in to an account, after which the client plays as its player ID.
+func (c *Client) Login(username, password string) (string, error) {
+	return c.Send(protocol.ActionLogin, protocol.Login{Username: username, Password: password})
+}
+
+// Logout ends the session; the client carries on as a new guest.
+func (c *Client) Logout() (string, error) {
+	return c.Send(protocol.ActionLogout, protocol.Logout{})
+}
+
+// UpdateProfile replaces the logged-in account's profile.
+func (c *Client) UpdateProfile(profile accounts.Profile) (string, error) {
+	return c.Send(protocol.ActionUpdateProfile, protocol.UpdateProfile{Profile: profile})
+}
+
+// Resync asks for a full snapshot. The client does this itself when it misses a patch.
+func (c *Client) Resync() (string, error) {
+	gameID, err := c.currentGame()
+	if err != nil {
+		return "", err
+	}
+	return c.Send(protocol.ActionResync, protocol.Resync{GameID: gameID})
+}
+
+// DecodeEvent unmarshals a server message into its typed event.
+func DecodeEvent(raw []byte) (interface{}, error) {
+	var envelope struct {
+		Type protocol.EventType `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return nil, err
+	}
+	var event interface{}
+	switch envelope.Type {
+	case protocol.EventWelcome:
+		event = &protocol.Welcome{}
+	case protocol.EventGameCreated, protocol.EventPlayerJoined, protocol.EventGameStarted, protocol.EventGameUpdate:
+		event = &protocol.GameState{}
+	case protocol.EventStatePatch:
+		event = &protocol.StatePatch{}
+	case protocol.EventHandUpdate:
+		event = &protocol.HandUpdate{}
+	case protocol.EventPassDevice:
+		event = &protocol.PassDevice{}
+	case protocol.EventHint:
+		event = &protocol.Hint{}
+	case protocol.EventMoveAnalysis:
+		event = &protocol.MoveAnalysis{}
+	case protocol.EventAccount:
+		event = &protocol.Account{}
+	case protocol.EventQueueStatus:
+		event = &protocol.QueueStatus{}
+	case protocol.EventShutdown:
+		event = &protocol.ServerShutdown{}
+	case protocol.EventError:
+		event = &protocol.Error{}
+	default:
+		return nil, fmt.Errorf("unknown event %q", envelope.Type)
+	}
+	return event, json.Unmarshal(raw, event)
+}
+
+// --- Stored Player IDs ---
+
+// idFile is where a client keeps its player ID so a restart rejoins as the same player.
+func idFile(store string) string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sequence-game", store+"-player-id")
+}
+
+// LoadPlayerID returns the player ID saved under store, e.g. "tui", or "".
+func LoadPlayerID(store string) string {
+	path := idFile(store)
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// SavePlayerID remembers the player ID the server assigned, under store.
+func SavePlayerID(store, playerID string) {
+	path := idFile(store)
+	if path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+		_ = os.WriteFile(path, []byte(playerID+"\n"), 0644)
+	}
+}
@@ -0,0 +1,198 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"sequence-game/game"
+	"sequence-game/protocol"
+)
+
+func TestOffer(t *testing.T) {
+	tests := []struct {
+		name   string
+		buffer int
+		sent   []int
+		want   []int
+	}{
+		{name: "room to spare", buffer: 3, sent: []int{1, 2}, want: []int{1, 2}},
+		{name: "exactly full", buffer: 3, sent: []int{1, 2, 3}, want: []int{1, 2, 3}},
+		{name: "drops the oldest", buffer: 3, sent: []int{1, 2, 3, 4, 5}, want: []int{3, 4, 5}},
+		{name: "keeps the newest", buffer: 1, sent: []int{1, 2, 3}, want: []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := make(chan int, tt.buffer)
+			for _, v := range tt.sent {
+				offer(ch, v)
+			}
+			close(ch)
+			var got []int
+			for v := range ch {
+				got = append(got, v)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("delivered %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// tableStates plays two moves of a game and returns its state at versions 1 to 3.
+func tableStates(t *testing.T) []protocol.GameState {
+	t.Helper()
+	rules, err := game.RulesPreset("Official")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := game.NewGame("a", "A", 2, 0, rules)
+	g.Seed(1)
+	for _, id := range []string{"a", "b"} {
+		if _, err := g.AddPlayer(id, strings.ToUpper(id), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.StartGame("a"); err != nil {
+		t.Fatal(err)
+	}
+	var states []protocol.GameState
+	for version := 1; version <= 3; version++ {
+		if version > 1 {
+			player := g.CurrentPlayerID()
+			if err := g.PlayAction(player, g.LegalMoves(player)[0]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		g.Lock()
+		s := protocol.NewGameState(g, protocol.EventGameUpdate, nil)
+		g.Unlock()
+		s.Version = version
+		states = append(states, s)
+	}
+	return states
+}
+
+// dialTestServer connects a Client to a server that welcomes it and then passes
+// on every message the client sends.
+func dialTestServer(t *testing.T) (*Client, <-chan protocol.ClientMessage) {
+	t.Helper()
+	sent := make(chan protocol.ClientMessage, eventBuffer)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var hello protocol.Hello
+		if err := conn.ReadJSON(&hello); err != nil {
+			return
+		}
+		conn.WriteJSON(protocol.Welcome{Type: protocol.EventWelcome, PlayerID: "a", ProtocolVersion: protocol.Version})
+		for {
+			var msg protocol.ClientMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			sent <- msg
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := Dial("ws"+strings.TrimPrefix(srv.URL, "http"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, sent
+}
+
+func TestPatches(t *testing.T) {
+	states := tableStates(t)
+	patch := func(from, to int) *protocol.StatePatch {
+		p, ok := states[to-1].Diff(&states[from-1])
+		if !ok {
+			t.Fatalf("Diff from version %d to %d asked for a snapshot", from, to)
+		}
+		return &p
+	}
+
+	tests := []struct {
+		name       string
+		snapshot   int // Version of the snapshot received first; 0 for none
+		patch      *protocol.StatePatch
+		want       int // Version of the state delivered for the patch; 0 for none
+		wantResync bool
+	}{
+		{name: "next version", snapshot: 1, patch: patch(1, 2), want: 2},
+		{name: "several versions at once", snapshot: 1, patch: patch(1, 3), want: 3},
+		{name: "stale", snapshot: 2, patch: patch(1, 2)},
+		{name: "before the first snapshot", patch: patch(1, 2)},
+		{name: "missed a patch", snapshot: 1, patch: patch(2, 3), wantResync: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, sent := dialTestServer(t)
+			if tt.snapshot > 0 {
+				snapshot := states[tt.snapshot-1]
+				c.handle(&snapshot)
+				<-c.States
+			}
+
+			// As received from the server
+			data, err := json.Marshal(tt.patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			event, err := DecodeEvent(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.handle(event)
+
+			select {
+			case got := <-c.States:
+				if tt.want == 0 {
+					t.Fatalf("delivered version %d, want nothing", got.Version)
+				}
+				want := states[tt.want-1]
+				if !reflect.DeepEqual(got.Board, want.Board) || !reflect.DeepEqual(got.Players, want.Players) ||
+					got.Version != want.Version || got.CurrentTurnPlayerID != want.CurrentTurnPlayerID || got.MoveCount != want.MoveCount {
+					t.Errorf("delivered %+v\nwant %+v", got, want)
+				}
+				if c.State().Version != want.Version {
+					t.Errorf("State() is at version %d, want %d", c.State().Version, want.Version)
+				}
+			default:
+				if tt.want != 0 {
+					t.Fatalf("delivered nothing, want version %d", tt.want)
+				}
+			}
+
+			select {
+			case msg := <-sent:
+				if !tt.wantResync {
+					t.Fatalf("client sent %s", msg.ActionType)
+				}
+				var resync protocol.Resync
+				if err := json.Unmarshal(msg.Payload, &resync); err != nil {
+					t.Fatal(err)
+				}
+				if msg.ActionType != protocol.ActionResync || resync.GameID != states[0].GameID {
+					t.Errorf("client sent %s for game %q, want %s for %q", msg.ActionType, resync.GameID, protocol.ActionResync, states[0].GameID)
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tt.wantResync {
+					t.Error("client did not ask to resync")
+				}
+			}
+		})
+	}
+}
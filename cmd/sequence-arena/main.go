@@ -0,0 +1,246 @@
+// Command sequence-arena pits bot strategies against each other in headless games
+// run in-process on the engine, and reports how they compare.
+//
+//	sequence-arena -bots greedy,random -deals 500 -seed 1
+//
+// Every pair of strategies plays the same seeded deals, so a run is reproducible.
+// Unless -swap=false each deal is played twice with the seats swapped, which
+// cancels out both the first-move advantage and the luck of the cards.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"sequence-game/bot"
+	"sequence-game/game"
+)
+
+// job is one game: a pairing of strategies on a seeded deal.
+type job struct {
+	pair    int
+	seed    int64
+	swapped bool // The pair's second strategy takes the first seat
+}
+
+// result is how a game ended.
+type result struct {
+	job
+	winner    int // Index into the pair of the winning strategy, -1 for a draw
+	firstSeat bool
+	moves     int
+	err       error // Set when the game had to be abandoned
+}
+
+// tally accumulates the results of one pairing.
+type tally struct {
+	names          [2]string
+	wins           [2]int
+	draws          int
+	firstSeatWins  int
+	moves, squares float64 // Sums over finished games, for the mean length and its interval
+	shortest       int
+	longest        int
+	aborted        int
+	abortReason    error
+}
+
+func (t *tally) add(r result) {
+	if r.err != nil {
+		t.aborted++
+		t.abortReason = r.err
+		return
+	}
+	if r.winner >= 0 {
+		t.wins[r.winner]++
+		if r.firstSeat {
+			t.firstSeatWins++
+		}
+	} else {
+		t.draws++
+	}
+	t.moves += float64(r.moves)
+	t.squares += float64(r.moves * r.moves)
+	if t.shortest == 0 || r.moves < t.shortest {
+		t.shortest = r.moves
+	}
+	if r.moves > t.longest {
+		t.longest = r.moves
+	}
+}
+
+func main() {
+	botList := flag.String("bots", "greedy,random", "Comma-separated strategies; every pair plays ("+strings.Join(bot.Names(), ", ")+")")
+	deals := flag.Int("deals", 500, "Seeded deals played by each pair")
+	seed := flag.Int64("seed", 1, "Seed of the first deal; deal i uses seed+i")
+	swap := flag.Bool("swap", true, "Play every deal a second time with the seats swapped")
+	workers := flag.Int("workers", runtime.NumCPU(), "Games played in parallel")
+	preset := flag.String("rules", game.DefaultRulesPreset, "Rules preset ("+strings.Join(game.RulesPresetNames(), ", ")+")")
+	maxTurns := flag.Int("max-turns", 1000, "Abandon a game after this many turns")
+	thinkTime := flag.Duration("think", bot.DefaultThinkTime, "Search time per move of the expert bot")
+	iterations := flag.Int("iterations", 0, "Search iterations per move of the expert bot, instead of -think; makes its games reproducible")
+	flag.Parse()
+
+	rules, err := game.RulesPreset(*preset)
+	if err != nil {
+		log.Fatal(err)
+	}
+	names := strings.Split(*botList, ",")
+	if len(names) < 2 {
+		log.Fatal("Need at least two strategies (they may repeat, e.g. -bots greedy,greedy)")
+	}
+	for _, name := range names {
+		if _, err := bot.New(name, 0); err != nil {
+			log.Fatal(err)
+		}
+	}
+	var tallies []*tally
+	for i := range names {
+		for j := i + 1; j < len(names); j++ {
+			tallies = append(tallies, &tally{names: [2]string{names[i], names[j]}})
+		}
+	}
+
+	seatings := 1
+	if *swap {
+		seatings = 2
+	}
+	fmt.Printf("Sequence arena: %d games per pairing (%d deals x %d seatings), %s rules, seed %d, %d workers\n",
+		*deals*seatings, *deals, seatings, rules.Preset, *seed, *workers)
+	log.SetOutput(io.Discard) // The engine logs every move
+	started := time.Now()
+
+	jobs := make(chan job)
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for w := 0; w < *workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results <- playGame(rules, tallies[j.pair].names, j, *maxTurns, func(s bot.Strategy) {
+					if expert, ok := s.(*bot.MCTS); ok {
+						expert.ThinkTime, expert.Iterations = *thinkTime, *iterations
+					}
+				})
+			}
+		}()
+	}
+	go func() {
+		for pair := range tallies {
+			for d := 0; d < *deals; d++ {
+				for s := 0; s < seatings; s++ {
+					jobs <- job{pair: pair, seed: *seed + int64(d), swapped: s == 1}
+				}
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	games := 0
+	for r := range results {
+		tallies[r.pair].add(r)
+		games++
+	}
+	for _, t := range tallies {
+		report(t)
+	}
+	fmt.Printf("\nPlayed %d games in %s\n", games, time.Since(started).Round(time.Millisecond))
+}
+
+// playGame plays one two-player game between the pair's strategies, letting
+// configure adjust each strategy first.
+func playGame(rules game.Rules, names [2]string, j job, maxTurns int, configure func(bot.Strategy)) result {
+	seats := [2]int{0, 1} // Strategy index in each seat
+	if j.swapped {
+		seats = [2]int{1, 0}
+	}
+	g := game.NewGame("seat1", names[seats[0]], 2, 0, rules)
+	g.Seed(j.seed)
+	strategies := make(map[string]bot.Strategy)
+	for i, strategyIndex := range seats {
+		playerID := fmt.Sprintf("seat%d", i+1)
+		strategy, _ := bot.New(names[strategyIndex], j.seed*2+int64(i))
+		configure(strategy)
+		strategies[playerID] = strategy
+		// Names must differ, or the engine would treat the second seat as the first rejoining
+		if _, err := g.AddPlayer(playerID, fmt.Sprintf("%s (seat %d)", strategy.Name(), i+1), nil); err != nil {
+			return result{job: j, err: err}
+		}
+	}
+	if err := g.StartGame("seat1"); err != nil {
+		return result{job: j, err: err}
+	}
+
+	for turn := 0; g.GamePhase == "InProgress"; turn++ {
+		if turn >= maxTurns {
+			return result{job: j, err: fmt.Errorf("no result after %d turns", maxTurns)}
+		}
+		current := g.CurrentPlayerID()
+		if err := bot.TakeTurn(g, current, strategies[current]); err != nil {
+			return result{job: j, err: err}
+		}
+	}
+
+	r := result{job: j, winner: -1, moves: g.MoveCount}
+	switch g.Winner {
+	case "seat1":
+		r.winner, r.firstSeat = seats[0], true
+	case "seat2":
+		r.winner = seats[1]
+	}
+	return r
+}
+
+func report(t *tally) {
+	finished := t.wins[0] + t.wins[1] + t.draws
+	fmt.Printf("\n%s vs %s\n", t.names[0], t.names[1])
+	if finished == 0 {
+		fmt.Printf("  no game finished (%d abandoned: %v)\n", t.aborted, t.abortReason)
+		return
+	}
+	labels := t.names
+	if labels[0] == labels[1] {
+		labels = [2]string{labels[0] + " A", labels[1] + " B"}
+	}
+	for i, name := range labels {
+		low, high := wilson(t.wins[i], finished)
+		fmt.Printf("  %-10s %6d wins  %5.1f%%  (95%% CI %.1f%%-%.1f%%)\n",
+			name, t.wins[i], 100*float64(t.wins[i])/float64(finished), 100*low, 100*high)
+	}
+	fmt.Printf("  %-10s %6d\n", "draws", t.draws)
+
+	mean := t.moves / float64(finished)
+	margin := 0.0
+	if finished > 1 {
+		variance := (t.squares - t.moves*mean) / float64(finished-1)
+		margin = 1.96 * math.Sqrt(variance/float64(finished))
+	}
+	fmt.Printf("  length     %.1f moves on average (95%% CI %.1f-%.1f), shortest %d, longest %d\n",
+		mean, mean-margin, mean+margin, t.shortest, t.longest)
+	if decided := t.wins[0] + t.wins[1]; decided > 0 {
+		fmt.Printf("  first seat won %.1f%% of decided games\n", 100*float64(t.firstSeatWins)/float64(decided))
+	}
+	if t.aborted > 0 {
+		fmt.Printf("  abandoned  %d game(s), e.g.: %v\n", t.aborted, t.abortReason)
+	}
+}
+
+// wilson is the 95% Wilson score interval for k successes in n trials.
+func wilson(k, n int) (float64, float64) {
+	const z = 1.96
+	p, nf := float64(k)/float64(n), float64(n)
+	denom := 1 + z*z/nf
+	center := (p + z*z/(2*nf)) / denom
+	half := z * math.Sqrt(p*(1-p)/nf+z*z/(4*nf*nf)) / denom
+	return center - half, center + half
+}
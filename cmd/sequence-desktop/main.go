@@ -0,0 +1,76 @@
+// Command sequence-desktop is a native window client for Sequence, drawn with Ebiten.
+// Online it plays on a server over the same /ws protocol as the web client; offline
+// it runs the game engine in-process for hot-seat play and games against bots.
+//
+//	sequence-desktop -name Alice                                  # online, click "Create game"
+//	sequence-desktop -name Bob -join 1a2b3c4d5e6f7a8b             # join an existing game
+//	sequence-desktop -offline hotseat -humans Alice,Bob           # pass the device between players
+//	sequence-desktop -offline bot -humans Alice -bots greedy      # play against the computer
+//
+// Click a card in your hand to see where it can go, then click a space. A swap
+// Jack takes two clicks: the chip to move, then its destination.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"sequence-game/bot"
+	"sequence-game/game"
+)
+
+func main() {
+	server := flag.String("server", "ws://localhost:8008/ws", "WebSocket URL of the Sequence server")
+	name := flag.String("name", os.Getenv("USER"), "Player name")
+	join := flag.String("join", "", "Game ID to join on start")
+	maxPlayers := flag.Int("players", 2, "Maximum players when creating a game")
+	preset := flag.String("rules", game.DefaultRulesPreset, "Rules preset ("+strings.Join(game.RulesPresetNames(), ", ")+")")
+	offline := flag.String("offline", "", "Play without a server: hotseat or bot")
+	humans := flag.String("humans", "", "Comma-separated names of the offline human players (default: -name)")
+	bots := flag.String("bots", "greedy", "Comma-separated strategies of the offline bots ("+strings.Join(bot.Names(), ", ")+")")
+	flag.Parse()
+	if *name == "" {
+		*name = "Player"
+	}
+
+	var t table
+	switch *offline {
+	case "":
+		t = newRemoteTable(*server, *name, *join, *maxPlayers, *preset)
+	case "hotseat", "bot":
+		rules, err := game.RulesPreset(*preset)
+		if err != nil {
+			log.Fatal(err)
+		}
+		humanNames := []string{*name}
+		if *humans != "" {
+			humanNames = strings.Split(*humans, ",")
+		}
+		var botNames []string
+		if *offline == "bot" {
+			botNames = strings.Split(*bots, ",")
+		}
+		local, err := newLocalTable(humanNames, botNames, rules)
+		if err != nil {
+			log.Fatalf("Cannot start offline game: %v", err)
+		}
+		t = local
+	default:
+		log.Fatalf("Unknown -offline mode %q (want hotseat or bot)", *offline)
+	}
+
+	u, err := newUI(t)
+	if err != nil {
+		log.Fatalf("Cannot load font: %v", err)
+	}
+	ebiten.SetWindowSize(screenWidth, screenHeight)
+	ebiten.SetWindowTitle("Sequence")
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	if err := ebiten.RunGame(u); err != nil {
+		log.Fatal(err)
+	}
+}
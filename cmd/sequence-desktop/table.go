@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"sequence-game/bot"
+	"sequence-game/client"
+	"sequence-game/game"
+	"sequence-game/protocol"
+)
+
+// botDelay gives the chip animation of one move time to play before a bot moves again.
+const botDelay = 700 * time.Millisecond
+
+// table is the game the window shows: localTable runs the engine in-process for
+// hot-seat and vs-bot play, remoteTable mirrors a game on the server.
+type table interface {
+	State() *protocol.GameState // nil until there is a game
+	Seat() string               // Player ID of the seat whose hand is on screen
+	Hand() []string
+	HumanSeats() int // More than one means the device is passed between players
+	Create()
+	Start()
+	Play(action game.PlayerAction)
+	DeclareDead(cardID string)
+	Draw()
+	Update() []string // Advances the game (bot moves, server events) and returns log lines
+}
+
+// --- Offline ---
+
+type localTable struct {
+	g      *game.Game
+	bots   map[string]bot.Strategy
+	humans map[string]bool
+	seat   string
+	state  protocol.GameState
+	botAt  time.Time
+	moved  chan error // Set while a bot is choosing its move off the UI thread
+	log    []string
+}
+
+// newLocalTable seats the human players, then the bots, and starts the game.
+func newLocalTable(humans, bots []string, rules game.Rules) (*localTable, error) {
+	seats := len(humans) + len(bots)
+	if len(humans) == 0 || seats < 2 || seats > rules.MaxSupportedPlayers() {
+		return nil, fmt.Errorf("need at least one human and 2-%d seats, got %d", rules.MaxSupportedPlayers(), seats)
+	}
+	t := &localTable{bots: make(map[string]bot.Strategy), humans: make(map[string]bool)}
+	t.g = game.NewGame("seat1", humans[0], seats, 0, rules)
+	for i := 0; i < seats; i++ {
+		playerID := fmt.Sprintf("seat%d", i+1)
+		name := ""
+		if i < len(humans) {
+			name = humans[i]
+			t.humans[playerID] = true
+		} else {
+			strategy, err := bot.New(bots[i-len(humans)], time.Now().UnixNano()+int64(i))
+			if err != nil {
+				return nil, err
+			}
+			name = fmt.Sprintf("%s bot %d", strategy.Name(), i-len(humans)+1)
+			t.bots[playerID] = strategy
+		}
+		if _, err := t.g.AddPlayer(playerID, name, nil); err != nil {
+			return nil, err
+		}
+	}
+	if err := t.g.StartGame("seat1"); err != nil {
+		return nil, err
+	}
+	t.seat = "seat1"
+	t.refresh(protocol.EventGameStarted)
+	return t, nil
+}
+
+func (t *localTable) refresh(eventType protocol.EventType) {
+	t.g.Lock()
+	t.state = protocol.NewGameState(t.g, eventType, nil)
+	t.g.Unlock()
+	if current := t.state.CurrentTurnPlayerID; t.humans[current] {
+		t.seat = current
+	}
+}
+
+func (t *localTable) State() *protocol.GameState { return &t.state }
+func (t *localTable) Seat() string               { return t.seat }
+func (t *localTable) HumanSeats() int            { return len(t.humans) }
+func (t *localTable) Create()                    {}
+func (t *localTable) Start()                     {}
+func (t *localTable) Draw()                      {}
+
+func (t *localTable) Hand() []string {
+	t.g.Lock()
+	defer t.g.Unlock()
+	var hand []string
+	for _, card := range t.g.Players[t.seat].Hand {
+		hand = append(hand, card.ID)
+	}
+	return hand
+}
+
+func (t *localTable) Play(action game.PlayerAction) {
+	if err := t.g.PlayAction(t.seat, action); err != nil {
+		t.log = append(t.log, "Invalid move: "+err.Error())
+		return
+	}
+	if t.g.PendingDraw == t.seat && t.g.GamePhase == "InProgress" {
+		if err := t.g.HandleDraw(t.seat); err != nil {
+			t.log = append(t.log, "Could not draw: "+err.Error())
+		}
+	}
+	t.botAt = time.Now().Add(botDelay)
+	t.refresh(protocol.EventGameUpdate)
+}
+
+func (t *localTable) DeclareDead(cardID string) {
+	if err := t.g.HandleDeadCard(t.seat, cardID); err != nil {
+		t.log = append(t.log, "Not a dead card: "+err.Error())
+		return
+	}
+	t.refresh(protocol.EventGameUpdate)
+}
+
+func (t *localTable) Update() []string {
+	if t.moved != nil {
+		select {
+		case err := <-t.moved:
+			if err != nil {
+				t.log = append(t.log, err.Error())
+			}
+			t.moved = nil
+			t.botAt = time.Now().Add(botDelay)
+			t.refresh(protocol.EventGameUpdate)
+		default:
+		}
+	} else if current := t.g.CurrentPlayerID(); t.bots[current] != nil && time.Now().After(t.botAt) {
+		strategy, name := t.bots[current], t.state.Players[current].Name
+		t.moved = make(chan error, 1)
+		go func(moved chan<- error) {
+			if err := bot.TakeTurn(t.g, current, strategy); err != nil {
+				moved <- fmt.Errorf("%s: %w", name, err)
+			}
+			close(moved)
+		}(t.moved)
+	}
+	lines := t.log
+	t.log = nil
+	return lines
+}
+
+// --- Online ---
+
+type remoteTable struct {
+	server, name, join string
+	maxPlayers         int
+	preset             string
+
+	client   *client.Client
+	redialAt time.Time
+	state    *protocol.GameState
+	hand     []string
+	log      []string
+}
+
+func newRemoteTable(server, name, join string, maxPlayers int, preset string) *remoteTable {
+	return &remoteTable{server: server, name: name, join: join, maxPlayers: maxPlayers, preset: preset}
+}
+
+func (t *remoteTable) State() *protocol.GameState { return t.state }
+func (t *remoteTable) Hand() []string             { return t.hand }
+func (t *remoteTable) HumanSeats() int            { return 1 }
+
+func (t *remoteTable) Seat() string {
+	if t.client == nil {
+		return ""
+	}
+	return t.client.PlayerID()
+}
+
+// request sends a request through the client, logging why it could not be sent.
+func (t *remoteTable) request(send func(c *client.Client) (string, error)) {
+	if t.client == nil {
+		t.log = append(t.log, "Not connected.")
+		return
+	}
+	if _, err := send(t.client); err != nil {
+		t.log = append(t.log, "Send failed: "+err.Error())
+	}
+}
+
+func (t *remoteTable) Create() {
+	t.request(func(c *client.Client) (string, error) {
+		return c.CreateGame(protocol.CreateGame{PlayerName: t.name, MaxPlayers: t.maxPlayers, RulesPreset: t.preset})
+	})
+}
+
+func (t *remoteTable) Start() { t.request((*client.Client).StartGame) }
+func (t *remoteTable) Draw()  { t.request((*client.Client).Draw) }
+
+func (t *remoteTable) Play(action game.PlayerAction) {
+	t.request(func(c *client.Client) (string, error) { return c.Play(action) })
+}
+
+func (t *remoteTable) DeclareDead(cardID string) {
+	t.request(func(c *client.Client) (string, error) { return c.DeclareDead(cardID) })
+}
+
+// Update connects until the server answers (the client reconnects by itself after
+// that) and takes every event received since the last frame.
+func (t *remoteTable) Update() []string {
+	if t.client == nil && time.Now().After(t.redialAt) {
+		t.connect()
+	}
+	for t.client != nil {
+		select {
+		case state := <-t.client.States:
+			if state.Type == protocol.EventGameCreated {
+				t.log = append(t.log, "Created game "+state.GameID)
+			}
+			t.state = state
+		case hand := <-t.client.Hands:
+			t.hand = hand.Hand
+		case ev := <-t.client.Errors:
+			t.log = append(t.log, fmt.Sprintf("Error [%s]: %s", ev.Code, ev.Error))
+		case ev := <-t.client.Status:
+			if ev.Connected {
+				t.log = append(t.log, "Reconnected.")
+			} else {
+				t.log = append(t.log, "Disconnected. Reconnecting...")
+			}
+		default:
+			lines := t.log
+			t.log = nil
+			return lines
+		}
+	}
+	lines := t.log
+	t.log = nil
+	return lines
+}
+
+func (t *remoteTable) connect() {
+	c, err := client.Dial(t.server, client.Options{Store: "desktop", Reconnect: 3 * time.Second})
+	if err != nil {
+		t.redialAt = time.Now().Add(3 * time.Second)
+		t.log = append(t.log, fmt.Sprintf("Cannot connect to %s: %v", t.server, err))
+		return
+	}
+	t.client = c
+	t.log = append(t.log, "Connected to "+t.server)
+	if t.join != "" {
+		t.request(func(c *client.Client) (string, error) { return c.JoinGame(t.join, t.name) })
+	}
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/image/font/gofont/goregular"
+
+	"sequence-game/game"
+	"sequence-game/protocol"
+)
+
+// Window layout, in pixels.
+const (
+	screenWidth  = 1100
+	screenHeight = 800
+	boardLeft    = 20
+	boardTop     = 20
+	cellSize     = 64
+	chipRadius   = 22
+	panelLeft    = boardLeft + game.BoardSize*cellSize + 30
+	handTop      = boardTop + game.BoardSize*cellSize + 30
+	cardWidth    = 60
+	cardHeight   = 84
+	animFrames   = 20 // Length of the place/remove chip animation at 60 TPS
+)
+
+var (
+	colorBackground = color.RGBA{0x1f, 0x5c, 0x3a, 0xff}
+	colorCell       = color.RGBA{0xf7, 0xf3, 0xe8, 0xff}
+	colorCorner     = color.RGBA{0xe8, 0xd3, 0x8c, 0xff}
+	colorTarget     = color.RGBA{0x3c, 0xe0, 0x6a, 0xff}
+	colorRedSuit    = color.RGBA{0xc0, 0x1c, 0x28, 0xff}
+	colorBlackSuit  = color.RGBA{0x20, 0x20, 0x20, 0xff}
+	colorText       = color.White
+	colorButton     = color.RGBA{0x2d, 0x3e, 0x50, 0xff}
+
+	// chipColors matches the colour names the server assigns in AddPlayer.
+	chipColors = map[string]color.RGBA{
+		"red": {0xef, 0x44, 0x44, 0xff}, "blue": {0x3b, 0x82, 0xf6, 0xff}, "green": {0x22, 0xc5, 0x5e, 0xff},
+		"yellow": {0xfa, 0xcc, 0x15, 0xff}, "purple": {0xa8, 0x55, 0xf7, 0xff}, "orange": {0xf9, 0x73, 0x16, 0xff},
+		"pink": {0xec, 0x48, 0x99, 0xff}, "cyan": {0x06, 0xb6, 0xd4, 0xff}, "lime": {0x84, 0xcc, 0x16, 0xff},
+		"brown": {0xb4, 0x53, 0x09, 0xff}, "teal": {0x14, 0xb8, 0xa6, 0xff}, "magenta": {0xd9, 0x46, 0xef, 0xff},
+	}
+
+	suitSymbols = map[game.Suit]string{game.Hearts: "♥", game.Diamonds: "♦", game.Clubs: "♣", game.Spades: "♠"}
+)
+
+// anim is a chip appearing on, or disappearing from, a space.
+type anim struct {
+	pos     game.Position
+	color   color.RGBA
+	removed bool
+	frame   int
+}
+
+// button is a clickable label in the side panel.
+type button struct {
+	label   string
+	x, y    float32
+	w, h    float32
+	onClick func()
+}
+
+type ui struct {
+	table     table
+	face      *text.GoTextFace
+	smallFace *text.GoTextFace
+
+	selected int            // Index into the hand, -1 when no card is picked
+	swapFrom *game.Position // Chip picked up by a swap Jack
+	revealed string         // Hot-seat: the seat whose hand may be shown
+	buttons  []button
+
+	lastBoard [game.BoardSize][game.BoardSize]string // OccupiedBy as last drawn, to spot changes
+	anims     []anim
+	log       []string
+}
+
+func newUI(t table) (*ui, error) {
+	source, err := text.NewGoTextFaceSource(bytes.NewReader(goregular.TTF))
+	if err != nil {
+		return nil, err
+	}
+	return &ui{
+		table:     t,
+		face:      &text.GoTextFace{Source: source, Size: 18},
+		smallFace: &text.GoTextFace{Source: source, Size: 14},
+		selected:  -1,
+	}, nil
+}
+
+func (u *ui) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return screenWidth, screenHeight
+}
+
+// handoff reports whether the hot-seat cover is up, hiding the hand until the next player takes the device.
+func (u *ui) handoff() bool {
+	return u.table.HumanSeats() > 1 && u.table.Seat() != u.revealed
+}
+
+func (u *ui) Update() error {
+	for _, line := range u.table.Update() {
+		u.addLog(line)
+	}
+	u.trackAnimations()
+
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return nil
+	}
+	x, y := ebiten.CursorPosition()
+	if u.handoff() {
+		u.revealed = u.table.Seat()
+		u.selected, u.swapFrom = -1, nil
+		return nil
+	}
+	for _, b := range u.buttons {
+		if float32(x) >= b.x && float32(x) < b.x+b.w && float32(y) >= b.y && float32(y) < b.y+b.h {
+			b.onClick()
+			return nil
+		}
+	}
+	if i, ok := handCardAt(x, y, len(u.table.Hand())); ok {
+		if u.selected == i {
+			u.selected = -1
+		} else {
+			u.selected = i
+		}
+		u.swapFrom = nil
+		return nil
+	}
+	if pos, ok := cellAt(x, y); ok {
+		u.clickCell(pos)
+	}
+	return nil
+}
+
+func (u *ui) addLog(line string) {
+	u.log = append(u.log, line)
+	if len(u.log) > 8 {
+		u.log = u.log[len(u.log)-8:]
+	}
+}
+
+// trackAnimations starts an animation for every chip placed or removed since the last frame.
+func (u *ui) trackAnimations() {
+	live := u.anims[:0]
+	for _, a := range u.anims {
+		if a.frame++; a.frame < animFrames {
+			live = append(live, a)
+		}
+	}
+	u.anims = live
+
+	state := u.table.State()
+	if state == nil {
+		return
+	}
+	for x := range state.Board {
+		for y, space := range state.Board[x] {
+			before := u.lastBoard[x][y]
+			if before == space.OccupiedBy {
+				continue
+			}
+			pos := game.Position{X: x, Y: y}
+			if before != "" {
+				u.anims = append(u.anims, anim{pos: pos, color: u.chipColor(before), removed: true})
+			}
+			if space.OccupiedBy != "" {
+				u.anims = append(u.anims, anim{pos: pos, color: u.chipColor(space.OccupiedBy)})
+			}
+			u.lastBoard[x][y] = space.OccupiedBy
+		}
+	}
+}
+
+func (u *ui) chipColor(playerID string) color.RGBA {
+	if state := u.table.State(); state != nil {
+		if c, ok := chipColors[state.Players[playerID].ChipColor]; ok {
+			return c
+		}
+	}
+	return color.RGBA{0x80, 0x80, 0x80, 0xff}
+}
+
+// targets maps each space the selected card can go to the move it makes there.
+func (u *ui) targets() map[game.Position]game.MoveKind {
+	targets := make(map[game.Position]game.MoveKind)
+	hand := u.table.Hand()
+	state := u.table.State()
+	if state == nil || u.selected < 0 || u.selected >= len(hand) || state.CurrentTurnPlayerID != u.table.Seat() {
+		return targets
+	}
+	for _, move := range state.LegalMoves(u.table.Seat(), hand[u.selected:u.selected+1]) {
+		switch {
+		case u.swapFrom != nil:
+			if move.MoveKind == game.MoveSwap && move.BoardPos == *u.swapFrom {
+				targets[*move.TargetPos] = game.MoveSwap
+			}
+		default:
+			if _, taken := targets[move.BoardPos]; !taken {
+				targets[move.BoardPos] = move.MoveKind
+			}
+		}
+	}
+	return targets
+}
+
+func (u *ui) clickCell(pos game.Position) {
+	move, ok := u.targets()[pos]
+	if !ok {
+		return
+	}
+	hand := u.table.Hand()
+	action := game.PlayerAction{CardID: hand[u.selected], BoardPos: pos, MoveKind: move}
+	if move == game.MoveSwap {
+		if u.swapFrom == nil {
+			from := pos
+			u.swapFrom = &from
+			return
+		}
+		action.BoardPos, action.TargetPos = *u.swapFrom, &pos
+	}
+	u.table.Play(action)
+	u.selected, u.swapFrom = -1, nil
+}
+
+// --- Drawing ---
+
+func cellAt(x, y int) (game.Position, bool) {
+	row, col := (y-boardTop)/cellSize, (x-boardLeft)/cellSize
+	if x < boardLeft || y < boardTop || row >= game.BoardSize || col >= game.BoardSize {
+		return game.Position{}, false
+	}
+	return game.Position{X: row, Y: col}, true
+}
+
+func cellCenter(pos game.Position) (float32, float32) {
+	return boardLeft + float32(pos.Y)*cellSize + cellSize/2, boardTop + float32(pos.X)*cellSize + cellSize/2
+}
+
+func handCardAt(x, y, handSize int) (int, bool) {
+	i := (x - boardLeft) / (cardWidth + 10)
+	if x < boardLeft || y < handTop-12 || y > handTop+cardHeight || i >= handSize {
+		return 0, false
+	}
+	return i, true
+}
+
+func cardLabel(cardID string) (string, color.Color) {
+	card, err := game.ParseCardID(cardID)
+	if err != nil {
+		return cardID, colorBlackSuit
+	}
+	if card.Suit == game.Hearts || card.Suit == game.Diamonds {
+		return card.Rank.String() + suitSymbols[card.Suit], colorRedSuit
+	}
+	return card.Rank.String() + suitSymbols[card.Suit], colorBlackSuit
+}
+
+func (u *ui) text(dst *ebiten.Image, s string, face *text.GoTextFace, x, y float64, clr color.Color, centered bool) {
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(x, y)
+	op.ColorScale.ScaleWithColor(clr)
+	if centered {
+		op.PrimaryAlign, op.SecondaryAlign = text.AlignCenter, text.AlignCenter
+	}
+	text.Draw(dst, s, face, op)
+}
+
+func (u *ui) Draw(screen *ebiten.Image) {
+	screen.Fill(colorBackground)
+	u.buttons = u.buttons[:0]
+	state := u.table.State()
+	if state == nil {
+		u.text(screen, "Connecting...", u.face, boardLeft, boardTop, colorText, false)
+		u.addButton(screen, "Create game", u.table.Create)
+		u.drawLog(screen, boardTop+80)
+		return
+	}
+
+	u.drawBoard(screen, state)
+	u.drawPanel(screen, state)
+	if u.handoff() {
+		u.drawHandoff(screen, state)
+		return
+	}
+	u.drawHand(screen)
+}
+
+func (u *ui) drawBoard(screen *ebiten.Image, state *protocol.GameState) {
+	targets := u.targets()
+	animating := make(map[game.Position]bool)
+	for _, a := range u.anims {
+		animating[a.pos] = true
+	}
+
+	for x := range state.Board {
+		for y, space := range state.Board[x] {
+			pos := game.Position{X: x, Y: y}
+			left, top := float32(boardLeft+y*cellSize), float32(boardTop+x*cellSize)
+			cx, cy := cellCenter(pos)
+
+			fill := colorCell
+			if space.IsCorner {
+				fill = colorCorner
+			}
+			vector.DrawFilledRect(screen, left+2, top+2, cellSize-4, cellSize-4, fill, false)
+			if space.Card != nil {
+				label, clr := cardLabel(space.Card.ID)
+				u.text(screen, label, u.smallFace, float64(left+6), float64(top+4), clr, false)
+			} else {
+				u.text(screen, "FREE", u.smallFace, float64(cx), float64(cy), colorBlackSuit, true)
+			}
+			if _, ok := targets[pos]; ok {
+				vector.StrokeRect(screen, left+3, top+3, cellSize-6, cellSize-6, 3, colorTarget, false)
+			}
+
+			if space.OccupiedBy != "" && !animating[pos] {
+				vector.DrawFilledCircle(screen, cx, cy+4, chipRadius, u.chipColor(space.OccupiedBy), true)
+				if space.IsLocked {
+					vector.StrokeCircle(screen, cx, cy+4, chipRadius-5, 2, color.White, true)
+				}
+			}
+			if u.swapFrom != nil && *u.swapFrom == pos {
+				vector.StrokeCircle(screen, cx, cy+4, chipRadius+3, 3, colorTarget, true)
+			}
+		}
+	}
+
+	for _, a := range u.anims {
+		cx, cy := cellCenter(a.pos)
+		progress := float32(a.frame) / animFrames
+		clr := a.color
+		radius := chipRadius * progress
+		if a.removed {
+			radius = chipRadius * (1 + progress/2)
+			clr.A = uint8(255 * (1 - progress))
+			clr.R, clr.G, clr.B = uint8(float32(clr.R)*(1-progress)), uint8(float32(clr.G)*(1-progress)), uint8(float32(clr.B)*(1-progress))
+		}
+		vector.DrawFilledCircle(screen, cx, cy+4, radius, clr, true)
+	}
+
+	for _, line := range sequenceLines(state) {
+		x0, y0 := cellCenter(line[0])
+		x1, y1 := cellCenter(line[1])
+		vector.StrokeLine(screen, x0, y0+4, x1, y1+4, 6, color.RGBA{0x11, 0x11, 0x11, 0xc0}, true)
+	}
+}
+
+// sequenceLines finds the runs of locked chips (and free corners) that make up
+// completed sequences, as the first and last space of each run. Teammates' chips
+// run together.
+func sequenceLines(state *protocol.GameState) [][2]game.Position {
+	var lines [][2]game.Position
+	side := func(playerID string) string {
+		if p, ok := state.Players[playerID]; ok && p.Team != "" {
+			return p.Team
+		}
+		return playerID
+	}
+	counts := func(pos game.Position, owner string) bool {
+		if pos.X < 0 || pos.X >= game.BoardSize || pos.Y < 0 || pos.Y >= game.BoardSize {
+			return false
+		}
+		space := state.Board[pos.X][pos.Y]
+		return (space.IsLocked && side(space.OccupiedBy) == side(owner)) || (space.IsCorner && state.Rules.CornersFree)
+	}
+	for owner := range state.Players {
+		for x := range state.Board {
+			for y := range state.Board[x] {
+				for _, dir := range []game.Position{{X: 0, Y: 1}, {X: 1, Y: 0}, {X: 1, Y: 1}, {X: 1, Y: -1}} {
+					start := game.Position{X: x, Y: y}
+					if !counts(start, owner) || counts(game.Position{X: x - dir.X, Y: y - dir.Y}, owner) {
+						continue // Not the first space of a run
+					}
+					end, length := start, 1
+					for counts(game.Position{X: end.X + dir.X, Y: end.Y + dir.Y}, owner) {
+						end = game.Position{X: end.X + dir.X, Y: end.Y + dir.Y}
+						length++
+					}
+					if length >= state.Rules.SequenceLength {
+						lines = append(lines, [2]game.Position{start, end})
+					}
+				}
+			}
+		}
+	}
+	return lines
+}
+
+func (u *ui) addButton(screen *ebiten.Image, label string, onClick func()) {
+	y := float32(screenHeight - 60 - 50*len(u.buttons))
+	b := button{label: label, x: panelLeft, y: y, w: 200, h: 40, onClick: onClick}
+	vector.DrawFilledRect(screen, b.x, b.y, b.w, b.h, colorButton, false)
+	u.text(screen, label, u.face, float64(b.x+b.w/2), float64(b.y+b.h/2), colorText, true)
+	u.buttons = append(u.buttons, b)
+}
+
+func (u *ui) drawPanel(screen *ebiten.Image, state *protocol.GameState) {
+	y := float64(boardTop)
+	u.text(screen, fmt.Sprintf("Game %s - %s", state.GameID, state.GamePhase), u.face, panelLeft, y, colorText, false)
+	y += 30
+	for _, pid := range state.PlayerOrder {
+		player := state.Players[pid]
+		marker := "  "
+		if pid == state.CurrentTurnPlayerID {
+			marker = "> "
+		}
+		vector.DrawFilledCircle(screen, panelLeft+28, float32(y)+10, 8, u.chipColor(pid), true)
+		line := fmt.Sprintf("%s     %s  cards %d  seq %d", marker, player.Name, player.HandCount, player.Sequences)
+		if pid == u.table.Seat() {
+			line += " (you)"
+		}
+		u.text(screen, line, u.smallFace, panelLeft, y, colorText, false)
+		y += 24
+	}
+	y += 10
+	u.text(screen, fmt.Sprintf("%s rules, %d x %d-in-a-row to win", state.Rules.Preset, state.NumSequencesToWin, state.Rules.SequenceLength), u.smallFace, panelLeft, y, colorText, false)
+	y += 22
+	u.text(screen, fmt.Sprintf("Draw pile: %d", state.DrawPileCount), u.smallFace, panelLeft, y, colorText, false)
+	y += 30
+
+	switch {
+	case state.GamePhase == "Finished":
+		result := "The game ended in a draw."
+		if winner, ok := state.Players[state.Winner]; ok {
+			result = winner.Name + " has won the game!"
+		}
+		u.text(screen, result, u.face, panelLeft, y, colorTarget, false)
+	case state.GamePhase == "Lobby":
+		u.text(screen, "Waiting for players. Share the game ID.", u.smallFace, panelLeft, y, colorText, false)
+		if state.HostID == u.table.Seat() {
+			u.addButton(screen, "Start game", u.table.Start)
+		}
+	case state.CurrentTurnPlayerID == u.table.Seat():
+		u.text(screen, "Your turn: pick a card, then a space.", u.smallFace, panelLeft, y, colorTarget, false)
+	}
+	u.drawLog(screen, y+40)
+
+	if state.GamePhase == "InProgress" && state.CurrentTurnPlayerID == u.table.Seat() && !u.handoff() {
+		if state.PendingDraw == u.table.Seat() {
+			u.addButton(screen, "Draw card", u.table.Draw)
+		}
+		if u.selected >= 0 {
+			u.addButton(screen, "Declare dead", func() {
+				hand := u.table.Hand()
+				if u.selected < len(hand) {
+					u.table.DeclareDead(hand[u.selected])
+					u.selected = -1
+				}
+			})
+		}
+	}
+}
+
+func (u *ui) drawLog(screen *ebiten.Image, y float64) {
+	for _, line := range u.log {
+		u.text(screen, line, u.smallFace, panelLeft, y, color.RGBA{0xd0, 0xd0, 0xd0, 0xff}, false)
+		y += 20
+	}
+}
+
+func (u *ui) drawHand(screen *ebiten.Image) {
+	for i, cardID := range u.table.Hand() {
+		x, y := float32(boardLeft+i*(cardWidth+10)), float32(handTop)
+		if i == u.selected {
+			y -= 12
+		}
+		vector.DrawFilledRect(screen, x, y, cardWidth, cardHeight, color.White, false)
+		if i == u.selected {
+			vector.StrokeRect(screen, x, y, cardWidth, cardHeight, 3, colorTarget, false)
+		}
+		label, clr := cardLabel(cardID)
+		u.text(screen, label, u.face, float64(x+cardWidth/2), float64(y+cardHeight/2), clr, true)
+	}
+}
+
+func (u *ui) drawHandoff(screen *ebiten.Image, state *protocol.GameState) {
+	vector.DrawFilledRect(screen, 0, 0, screenWidth, screenHeight, color.RGBA{0, 0, 0, 0xe0}, false)
+	name := state.Players[u.table.Seat()].Name
+	u.text(screen, "Pass the device to "+name, u.face, screenWidth/2, screenHeight/2-20, colorText, true)
+	u.text(screen, "Click when "+name+" is ready to see their hand.", u.smallFace, screenWidth/2, screenHeight/2+20, colorText, true)
+}
@@ -0,0 +1,28 @@
+// Command sequence-schema writes the JSON Schema of the WebSocket protocol,
+// generated from the Go types in package protocol, for client authors.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"sequence-game/protocol"
+)
+
+func main() {
+	out := flag.String("o", "", "write the schema to this file instead of stdout")
+	flag.Parse()
+
+	schema, err := protocol.SchemaJSON()
+	if err != nil {
+		log.Fatalf("Failed to build protocol schema: %v", err)
+	}
+	if *out == "" {
+		os.Stdout.Write(schema)
+		return
+	}
+	if err := os.WriteFile(*out, schema, 0o644); err != nil {
+		log.Fatalf("Failed to write %s: %v", *out, err)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gdamore/tcell/v2"
+
+	"sequence-game/game"
+	"sequence-game/protocol"
+)
+
+// Screen layout, in terminal cells.
+const (
+	boardLeft = 2
+	boardTop  = 2
+	cellWidth = 5
+	handTop   = boardTop + game.BoardSize + 1
+	handLeft  = boardLeft + 6 // After "Hand: "
+	cardWidth = 6
+	panelLeft = boardLeft + game.BoardSize*cellWidth + 3
+)
+
+var suitSymbols = map[game.Suit]string{game.Hearts: "♥", game.Diamonds: "♦", game.Clubs: "♣", game.Spades: "♠"}
+
+// cardLabel is a card ID drawn with a suit symbol, e.g. "10♥"; the emoji forms
+// used by the web client have unpredictable widths in terminals.
+func cardLabel(cardID string) string {
+	card, err := game.ParseCardID(cardID)
+	if err != nil {
+		return cardID
+	}
+	return card.Rank.String() + suitSymbols[card.Suit]
+}
+
+func suitStyle(cardID string) tcell.Style {
+	card, err := game.ParseCardID(cardID)
+	if err == nil && (card.Suit == game.Hearts || card.Suit == game.Diamonds) {
+		return tcell.StyleDefault.Foreground(tcell.ColorRed)
+	}
+	return tcell.StyleDefault
+}
+
+// cellAt maps a screen position to a board space.
+func cellAt(x, y int) (game.Position, bool) {
+	row, col := y-boardTop, (x-boardLeft)/cellWidth
+	if row < 0 || row >= game.BoardSize || x < boardLeft || col >= game.BoardSize {
+		return game.Position{}, false
+	}
+	return game.Position{X: row, Y: col}, true
+}
+
+// handCardAt maps a screen position to an index into the hand.
+func handCardAt(x, y, handSize int) (int, bool) {
+	i := (x - handLeft) / cardWidth
+	if y != handTop || x < handLeft || i >= handSize {
+		return 0, false
+	}
+	return i, true
+}
+
+func (a *app) puts(x, y int, style tcell.Style, text string) int {
+	for _, r := range text {
+		a.screen.SetContent(x, y, r, nil, style)
+		x++
+	}
+	return x
+}
+
+func (a *app) draw() {
+	a.screen.Clear()
+	title := "Sequence"
+	if a.state != nil {
+		title += fmt.Sprintf(" - game %s (%s)", a.state.GameID, a.state.GamePhase)
+	}
+	a.puts(boardLeft, 0, tcell.StyleDefault.Bold(true), title+" - playing as "+a.name)
+
+	if a.state != nil {
+		a.drawBoard()
+		a.drawPlayers()
+	} else {
+		a.puts(boardLeft, boardTop, tcell.StyleDefault, "Press c to create a game, j to join one or m to find a match.")
+	}
+	a.drawHand()
+
+	help := "arrows/hjkl move  1-9/Tab pick card  Enter/Space play  d dead card  g draw  ? hint  Esc deselect  q quit"
+	if a.state == nil || a.state.GamePhase == "Lobby" {
+		help = "c create game  j join game  m find match  s start (host)  q quit"
+	}
+	a.puts(boardLeft, handTop+2, tcell.StyleDefault.Dim(true), help)
+	if a.prompt != nil {
+		a.puts(boardLeft, handTop+3, tcell.StyleDefault.Bold(true), "Game ID to join: "+*a.prompt+"_")
+	} else {
+		a.puts(boardLeft, handTop+3, tcell.StyleDefault.Bold(true), a.status)
+	}
+
+	_, height := a.screen.Size()
+	logTop := handTop + 5
+	lines := a.logLines
+	if room := height - logTop; room < len(lines) {
+		if room < 0 {
+			room = 0
+		}
+		lines = lines[len(lines)-room:]
+	}
+	for i, line := range lines {
+		a.puts(boardLeft, logTop+i, tcell.StyleDefault.Dim(true), line)
+	}
+	a.screen.Show()
+}
+
+func (a *app) drawBoard() {
+	targets := a.targets()
+	for x := 0; x < game.BoardSize; x++ {
+		for y := 0; y < game.BoardSize; y++ {
+			space := a.state.Board[x][y]
+			pos := game.Position{X: x, Y: y}
+
+			label := "FREE"
+			style := tcell.StyleDefault.Foreground(tcell.ColorGray)
+			if space.Card != nil {
+				label = cardLabel(space.Card.ID)
+				style = suitStyle(space.Card.ID)
+			}
+			if player, ok := a.state.Players[space.OccupiedBy]; ok {
+				style = tcell.StyleDefault.Background(tcell.GetColor(player.ChipColor)).Foreground(tcell.ColorBlack)
+			}
+			if space.IsLocked {
+				style = style.Bold(true)
+			}
+			if _, ok := targets[pos]; ok {
+				if space.OccupiedBy == "" {
+					style = style.Background(tcell.ColorDarkGreen)
+				} else {
+					style = style.Underline(true)
+				}
+			}
+			if a.swapFrom != nil && *a.swapFrom == pos {
+				style = style.Reverse(true)
+			}
+
+			left, right := " ", " "
+			switch {
+			case a.cursor == pos:
+				left, right = "[", "]"
+			case space.IsLocked:
+				left = "*" // Part of a completed sequence
+			}
+			a.puts(boardLeft+y*cellWidth, boardTop+x, style, fmt.Sprintf("%s%-3s%s", left, label, right))
+		}
+	}
+}
+
+func (a *app) drawPlayers() {
+	s := a.state
+	row := boardTop
+	for _, pid := range s.PlayerOrder {
+		player := s.Players[pid]
+		x := panelLeft
+		marker := "  "
+		if pid == s.CurrentTurnPlayerID {
+			marker = "> "
+		}
+		x = a.puts(x, row, tcell.StyleDefault.Bold(true), marker)
+		x = a.puts(x, row, tcell.StyleDefault.Foreground(tcell.GetColor(player.ChipColor)), "██ ")
+		line := fmt.Sprintf("%s  cards %d  seq %d", player.Name, player.HandCount, player.Sequences)
+		if pid == a.playerID {
+			line += "  (you)"
+		}
+		if !player.IsConnected {
+			line += "  offline"
+		}
+		a.puts(x, row, tcell.StyleDefault, line)
+		row++
+	}
+
+	row++
+	info := []string{
+		fmt.Sprintf("Rules: %s, %d sequence(s) of %d to win", s.Rules.Preset, s.NumSequencesToWin, s.Rules.SequenceLength),
+		fmt.Sprintf("Draw pile: %d  Moves: %d", s.DrawPileCount, s.MoveCount),
+	}
+	if s.PendingDraw == a.playerID {
+		info = append(info, "You owe a draw: press g")
+	}
+	if s.DeadCardUsed && s.Rules.DeadCards == game.DeadCardOncePerTurn {
+		info = append(info, "Dead card already turned in this turn")
+	}
+	if s.GamePhase == "Finished" {
+		info = append(info, finishedLine(s))
+	}
+	for _, line := range info {
+		a.puts(panelLeft, row, tcell.StyleDefault, line)
+		row++
+	}
+}
+
+func finishedLine(s *protocol.GameState) string {
+	if winner, ok := s.Players[s.Winner]; ok {
+		return "Winner: " + winner.Name
+	}
+	names := make([]string, 0, len(s.Players))
+	for _, p := range s.Players {
+		names = append(names, p.Name)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("Draw between %v", names)
+}
+
+func (a *app) drawHand() {
+	a.puts(boardLeft, handTop, tcell.StyleDefault.Bold(true), "Hand:")
+	for i, cardID := range a.hand {
+		style := suitStyle(cardID)
+		if i == a.selected {
+			style = style.Reverse(true)
+		}
+		a.puts(handLeft+i*cardWidth, handTop, style, fmt.Sprintf("%d:%-4s", i+1, cardLabel(cardID)))
+	}
+}
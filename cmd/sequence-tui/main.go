@@ -0,0 +1,425 @@
+// Command sequence-tui is a terminal client for the Sequence server. It speaks
+// the same /ws protocol as the web client and works over SSH.
+//
+//	sequence-tui -server ws://localhost:8008/ws -name Alice        # then press c to create a game
+//	sequence-tui -name Bob -join 1a2b3c4d5e6f7a8b                  # join an existing game
+//	sequence-tui -name Carol                                       # then press m to find a match
+//
+// Move the cursor with the arrow keys (or h/j/k/l), pick a card with 1-9 or Tab,
+// and press Enter or Space to play it on the highlighted space. The mouse works
+// too: click a card, then a space. Press ? for a hint where the rules allow it.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"sequence-game/client"
+	"sequence-game/game"
+	"sequence-game/protocol"
+)
+
+type app struct {
+	screen     tcell.Screen
+	server     string
+	name       string
+	maxPlayers int
+	preset     string
+	ranked     bool
+	queued     bool // Waiting for the matchmaker
+
+	client   *client.Client
+	playerID string
+	redial   chan struct{}
+	join     string // Game to join once connected
+
+	state    *protocol.GameState
+	hand     []string
+	cursor   game.Position
+	selected int            // Index into hand, -1 when no card is picked
+	swapFrom *game.Position // Chip picked up by a swap Jack, waiting for its destination
+	prompt   *string        // Game ID being typed after pressing j, nil when not prompting
+	status   string
+	logLines []string
+	quit     bool
+}
+
+func main() {
+	server := flag.String("server", "ws://localhost:8008/ws", "WebSocket URL of the Sequence server")
+	name := flag.String("name", os.Getenv("USER"), "Player name")
+	join := flag.String("join", "", "Game ID to join on start")
+	maxPlayers := flag.Int("players", 2, "Maximum players when creating a game; with 3, m looks for a free-for-all match")
+	preset := flag.String("rules", "", "Rules preset when creating a game ("+strings.Join(game.RulesPresetNames(), ", ")+"); empty for the server's default")
+	ranked := flag.Bool("ranked", false, "Create ranked games, which count towards ratings and allow no hints")
+	flag.Parse()
+	if *name == "" {
+		*name = "Player"
+	}
+
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		log.Fatalf("Cannot open terminal: %v", err)
+	}
+	if err := screen.Init(); err != nil {
+		log.Fatalf("Cannot open terminal: %v", err)
+	}
+	defer screen.Fini()
+	screen.EnableMouse(tcell.MouseButtonEvents)
+
+	a := &app{
+		screen: screen, server: *server, name: *name, maxPlayers: *maxPlayers, preset: *preset, ranked: *ranked,
+		redial: make(chan struct{}, 1), join: *join, selected: -1,
+	}
+	a.connect()
+	a.run()
+}
+
+// run is the main loop; all app state is owned by this goroutine.
+func (a *app) run() {
+	screenEvents := make(chan tcell.Event)
+	go func() {
+		for {
+			ev := a.screen.PollEvent()
+			if ev == nil {
+				return
+			}
+			screenEvents <- ev
+		}
+	}()
+
+	var lastButtons tcell.ButtonMask
+	for !a.quit {
+		a.draw()
+		var states <-chan *protocol.GameState
+		var hands <-chan *protocol.HandUpdate
+		var errs <-chan *protocol.Error
+		var status <-chan client.Status
+		var hints <-chan *protocol.Hint
+		var queue <-chan *protocol.QueueStatus
+		var shutdown <-chan *protocol.ServerShutdown
+		if a.client != nil {
+			states, hands, errs, status = a.client.States, a.client.Hands, a.client.Errors, a.client.Status
+			hints, queue, shutdown = a.client.Hints, a.client.Queue, a.client.Shutdown
+		}
+		select {
+		case ev := <-screenEvents:
+			switch ev := ev.(type) {
+			case *tcell.EventKey:
+				a.handleKey(ev)
+			case *tcell.EventMouse:
+				buttons := ev.Buttons()
+				if buttons&tcell.Button1 != 0 && lastButtons&tcell.Button1 == 0 {
+					a.handleClick(ev.Position())
+				}
+				lastButtons = buttons
+			case *tcell.EventResize:
+				a.screen.Sync()
+			}
+		case state := <-states:
+			a.state = state
+			a.afterUpdate()
+		case hand := <-hands:
+			a.hand = hand.Hand
+			if a.selected >= len(a.hand) {
+				a.selected = -1
+			}
+		case hint := <-hints:
+			a.showHint(hint)
+		case ev := <-queue:
+			a.showQueue(ev)
+		case ev := <-shutdown:
+			a.setStatus(ev.Message + " Reconnecting once it is back...")
+		case ev := <-errs:
+			a.setStatus(fmt.Sprintf("Error [%s]: %s", ev.Code, ev.Error))
+		case ev := <-status:
+			if ev.Connected {
+				a.setStatus("Reconnected to " + a.server)
+			} else {
+				a.setStatus("Disconnected. Reconnecting...")
+			}
+		case <-a.redial:
+			a.connect()
+		}
+	}
+	if a.client != nil {
+		a.client.Close()
+	}
+}
+
+// showQueue reports where the player stands with the matchmaker.
+func (a *app) showQueue(ev *protocol.QueueStatus) {
+	a.queued = ev.Status == protocol.QueueWaiting
+	switch ev.Status {
+	case protocol.QueueWaiting:
+		a.setStatus(fmt.Sprintf("Looking for a %s match (%d waiting). Press m to stop.", ev.Mode, ev.Waiting))
+	case protocol.QueueLeft:
+		a.setStatus("Stopped looking for a match.")
+	case protocol.QueueMatched:
+		a.setStatus("Match found!")
+	}
+}
+
+// connect dials until the server answers; after that the client reconnects by itself.
+func (a *app) connect() {
+	c, err := client.Dial(a.server, client.Options{Store: "tui", Reconnect: 3 * time.Second})
+	if err != nil {
+		a.setStatus(fmt.Sprintf("Cannot connect to %s: %v. Retrying...", a.server, err))
+		time.AfterFunc(3*time.Second, func() { a.redial <- struct{}{} })
+		return
+	}
+	a.client, a.playerID = c, c.PlayerID()
+	a.setStatus("Connected to " + a.server)
+	if a.join != "" {
+		a.request(func(c *client.Client) (string, error) { return c.JoinGame(a.join, a.name) })
+	}
+}
+
+// request sends a request through the client, reporting why it could not be sent.
+func (a *app) request(send func(c *client.Client) (string, error)) {
+	if a.client == nil {
+		a.setStatus("Not connected.")
+		return
+	}
+	if _, err := send(a.client); err != nil {
+		a.setStatus(fmt.Sprintf("Send failed: %v", err))
+	}
+}
+
+func (a *app) setStatus(msg string) {
+	a.status = msg
+	a.logLines = append(a.logLines, time.Now().Format("15:04:05")+" "+msg)
+	if len(a.logLines) > 50 {
+		a.logLines = a.logLines[len(a.logLines)-50:]
+	}
+}
+
+// --- Server Events ---
+
+// afterUpdate reports what just happened and clears a selection that no longer applies.
+func (a *app) afterUpdate() {
+	s := a.state
+	a.swapFrom = nil
+	switch s.Type {
+	case protocol.EventGameCreated:
+		a.setStatus("Created game " + s.GameID + ". Share this ID; press s to start.")
+	case protocol.EventPlayerJoined, protocol.EventGameStarted:
+		a.setStatus(fmt.Sprintf("%s: %d player(s) in game %s", s.Type, len(s.Players), s.GameID))
+	}
+	if s.GamePhase == "Finished" {
+		if winner, ok := s.Players[s.Winner]; ok {
+			a.setStatus(winner.Name + " has won the game!")
+		} else {
+			a.setStatus("The game ended in a draw.")
+		}
+	} else if s.GamePhase == "InProgress" && s.CurrentTurnPlayerID == a.playerID {
+		a.status = "Your turn."
+	} else if s.GamePhase == "InProgress" {
+		a.status = "Waiting for " + s.Players[s.CurrentTurnPlayerID].Name + "."
+	}
+}
+
+// --- Input ---
+
+func (a *app) handleKey(ev *tcell.EventKey) {
+	if a.prompt != nil {
+		a.handlePromptKey(ev)
+		return
+	}
+	switch ev.Key() {
+	case tcell.KeyCtrlC, tcell.KeyEscape:
+		if ev.Key() == tcell.KeyEscape && (a.selected >= 0 || a.swapFrom != nil) {
+			a.selected, a.swapFrom = -1, nil
+			return
+		}
+		a.quit = true
+	case tcell.KeyUp:
+		a.moveCursor(-1, 0)
+	case tcell.KeyDown:
+		a.moveCursor(1, 0)
+	case tcell.KeyLeft:
+		a.moveCursor(0, -1)
+	case tcell.KeyRight:
+		a.moveCursor(0, 1)
+	case tcell.KeyTab:
+		if len(a.hand) > 0 {
+			a.selectCard((a.selected + 1) % len(a.hand))
+		}
+	case tcell.KeyEnter:
+		a.playAtCursor()
+	case tcell.KeyRune:
+		a.handleRune(ev.Rune())
+	}
+}
+
+func (a *app) handleRune(r rune) {
+	switch {
+	case r >= '1' && r <= '9':
+		a.selectCard(int(r - '1'))
+	case r == 'k':
+		a.moveCursor(-1, 0)
+	case r == 'j' && a.state != nil && a.state.GamePhase == "InProgress":
+		a.moveCursor(1, 0)
+	case r == 'h':
+		a.moveCursor(0, -1)
+	case r == 'l':
+		a.moveCursor(0, 1)
+	case r == ' ':
+		a.playAtCursor()
+	case r == 'q':
+		a.quit = true
+	case r == 'c':
+		a.request(func(c *client.Client) (string, error) {
+			return c.CreateGame(protocol.CreateGame{PlayerName: a.name, MaxPlayers: a.maxPlayers, RulesPreset: a.preset, Ranked: a.ranked})
+		})
+	case r == 'm' && a.queued:
+		a.request((*client.Client).LeaveQueue)
+	case r == 'm':
+		mode := protocol.MatchDuel
+		if a.maxPlayers == 3 {
+			mode = protocol.MatchFreeForAll
+		}
+		a.request(func(c *client.Client) (string, error) {
+			return c.QueueForMatch(protocol.QueueForMatch{PlayerName: a.name, Mode: mode, Ranked: a.ranked})
+		})
+	case r == 'j':
+		empty := ""
+		a.prompt = &empty
+	case r == 's':
+		a.request((*client.Client).StartGame)
+	case r == 'd':
+		if a.state != nil && a.selected >= 0 {
+			cardID := a.hand[a.selected]
+			a.request(func(c *client.Client) (string, error) { return c.DeclareDead(cardID) })
+			a.selected = -1
+		} else {
+			a.setStatus("Pick the dead card first (1-9).")
+		}
+	case r == 'g':
+		a.request((*client.Client).Draw)
+	case r == '?':
+		a.request((*client.Client).RequestHint)
+		a.setStatus("Thinking about a hint...")
+	}
+}
+
+// showHint puts the cursor on the best suggested play and shows why it is good.
+func (a *app) showHint(hint *protocol.Hint) {
+	if len(hint.Suggestions) == 0 {
+		a.setStatus("No hint: there is nothing to play.")
+		return
+	}
+	best := hint.Suggestions[0]
+	if best.DeadCard != "" {
+		a.setStatus("Hint: turn in your dead card " + best.DeadCard)
+		return
+	}
+	a.cursor = best.Action.BoardPos
+	a.setStatus(fmt.Sprintf("Hint: %s here: %s", best.Action.CardID, strings.Join(best.Reasons, "; ")))
+}
+
+func (a *app) handlePromptKey(ev *tcell.EventKey) {
+	switch ev.Key() {
+	case tcell.KeyEscape:
+		a.prompt = nil
+	case tcell.KeyEnter:
+		gameID := strings.TrimSpace(*a.prompt)
+		a.prompt = nil
+		if gameID != "" {
+			a.request(func(c *client.Client) (string, error) { return c.JoinGame(gameID, a.name) })
+		}
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if n := len(*a.prompt); n > 0 {
+			*a.prompt = (*a.prompt)[:n-1]
+		}
+	case tcell.KeyRune:
+		*a.prompt += string(ev.Rune())
+	}
+}
+
+func (a *app) handleClick(x, y int) {
+	if pos, ok := cellAt(x, y); ok {
+		a.cursor = pos
+		a.playAtCursor()
+		return
+	}
+	if i, ok := handCardAt(x, y, len(a.hand)); ok {
+		a.selectCard(i)
+	}
+}
+
+func (a *app) moveCursor(dx, dy int) {
+	a.cursor.X = (a.cursor.X + dx + game.BoardSize) % game.BoardSize
+	a.cursor.Y = (a.cursor.Y + dy + game.BoardSize) % game.BoardSize
+}
+
+func (a *app) selectCard(i int) {
+	if i < 0 || i >= len(a.hand) {
+		return
+	}
+	if a.selected == i {
+		a.selected = -1
+	} else {
+		a.selected = i
+	}
+	a.swapFrom = nil
+}
+
+// --- Moves ---
+
+// targets returns the spaces where the selected card can go and the move it would make
+// on each, so the board can highlight them. After a swap Jack picks up a chip, they are
+// the spaces the chip may move to.
+func (a *app) targets() map[game.Position]game.MoveKind {
+	targets := make(map[game.Position]game.MoveKind)
+	if a.state == nil || a.selected < 0 {
+		return targets
+	}
+	for _, move := range a.state.LegalMoves(a.playerID, a.hand[a.selected:a.selected+1]) {
+		switch {
+		case a.swapFrom != nil:
+			if move.MoveKind == game.MoveSwap && move.BoardPos == *a.swapFrom {
+				targets[*move.TargetPos] = game.MoveSwap
+			}
+		default:
+			if _, taken := targets[move.BoardPos]; !taken {
+				targets[move.BoardPos] = move.MoveKind
+			}
+		}
+	}
+	return targets
+}
+
+// playAtCursor plays the selected card on the cursor space; a swap Jack takes two steps.
+func (a *app) playAtCursor() {
+	if a.state == nil || a.state.GamePhase != "InProgress" {
+		return
+	}
+	if a.selected < 0 {
+		a.setStatus("Pick a card first (1-9 or Tab).")
+		return
+	}
+	move, ok := a.targets()[a.cursor]
+	if !ok {
+		a.setStatus("That card cannot be played there.")
+		return
+	}
+	action := game.PlayerAction{CardID: a.hand[a.selected], BoardPos: a.cursor, MoveKind: move}
+	if move == game.MoveSwap {
+		if a.swapFrom == nil {
+			from := a.cursor
+			a.swapFrom = &from
+			a.setStatus("Now pick the space the chip moves to.")
+			return
+		}
+		target := a.cursor
+		action.BoardPos, action.TargetPos = *a.swapFrom, &target
+	}
+	a.request(func(c *client.Client) (string, error) { return c.Play(action) })
+	a.selected, a.swapFrom = -1, nil
+}
@@ -0,0 +1,103 @@
+// Command sequence-vendor downloads the third-party scripts the web client uses
+// (Alpine.js and Tailwind) into static/vendor, so they are embedded in the server
+// and the client works without internet access. The files and their pinned
+// versions are listed in static/vendor/assets.json with their SHA-256 checksums,
+// and a download that does not match is refused. An entry without a checksum is
+// refused too, unless -pin records the checksum of what is downloaded, so only
+// pin a version after checking it. Files already vendored with the pinned
+// checksum are not downloaded again.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// asset is an entry of assets.json.
+type asset struct {
+	File    string `json:"file"`
+	Version string `json:"version"`
+	URL     string `json:"url"`
+	SHA256  string `json:"sha256,omitempty"`
+}
+
+func main() {
+	dir := flag.String("dir", "static/vendor", "directory holding assets.json, where the files are written")
+	pin := flag.Bool("pin", false, "record the checksum of entries that have none, instead of refusing them")
+	flag.Parse()
+
+	manifest := filepath.Join(*dir, "assets.json")
+	data, err := os.ReadFile(manifest)
+	if err != nil {
+		log.Fatalf("Failed to read the asset list: %v", err)
+	}
+	var list []asset
+	if err := json.Unmarshal(data, &list); err != nil {
+		log.Fatalf("Failed to read %s: %v", manifest, err)
+	}
+
+	client := &http.Client{Timeout: time.Minute}
+	pinned := false
+	for i, a := range list {
+		if a.SHA256 == "" && !*pin {
+			log.Fatalf("%s %s has no checksum in %s; check the download and run again with -pin to record it", a.File, a.Version, manifest)
+		}
+		file := filepath.Join(*dir, a.File)
+		if have, err := os.ReadFile(file); err == nil && a.SHA256 != "" {
+			if sum := sha256.Sum256(have); hex.EncodeToString(sum[:]) == a.SHA256 {
+				fmt.Printf("%s %s: already vendored\n", a.File, a.Version)
+				continue
+			}
+		}
+		body, err := download(client, a.URL)
+		if err != nil {
+			log.Fatalf("Failed to download %s %s: %v", a.File, a.Version, err)
+		}
+		sum := sha256.Sum256(body)
+		hash := hex.EncodeToString(sum[:])
+		switch {
+		case a.SHA256 == "":
+			list[i].SHA256 = hash
+			pinned = true
+		case a.SHA256 != hash:
+			log.Fatalf("%s %s from %s has SHA-256 %s, expected %s", a.File, a.Version, a.URL, hash, a.SHA256)
+		}
+		if err := os.WriteFile(file, body, 0o644); err != nil {
+			log.Fatalf("Failed to write %s: %v", a.File, err)
+		}
+		fmt.Printf("%s %s: %d bytes, sha256 %s\n", a.File, a.Version, len(body), hash)
+	}
+
+	if pinned {
+		data, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(manifest, append(data, '\n'), 0o644); err != nil {
+			log.Fatalf("Failed to write %s: %v", manifest, err)
+		}
+		fmt.Printf("Recorded new checksums in %s.\n", manifest)
+	}
+}
+
+// download fetches url, which must answer 200 OK.
+func download(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s answered %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"sequence-game/game"
+)
+
+// --- Server Configuration ---
+
+// Storage backends.
+const (
+	StorageFile   = "file"   // Accounts, history, ratings and tournaments are kept under DataDir
+	StorageMemory = "memory" // Nothing outlives the server
+)
+
+// Config is the server's configuration. It starts from defaultConfig, then takes
+// the config file, then SEQUENCE_* environment variables, then command-line flags,
+// each overriding the one before.
+type Config struct {
+	Addr            string   `json:"addr"`              // Address to listen on, e.g. ":8008"
+	TLSCert         string   `json:"tlsCert,omitempty"` // With TLSKey, serves HTTPS and WSS
+	TLSKey          string   `json:"tlsKey,omitempty"`
+	AllowedOrigins  []string `json:"allowedOrigins"`  // Origins besides the server's own allowed to open /ws; "*" allows any
+	StaticDir       string   `json:"staticDir"`       // Serves the web client from disk; empty serves the copy embedded in the binary
+	RequireVendored bool     `json:"requireVendored"` // Refuses to start until the vendored scripts are downloaded and pinned
+	LogsDir         string   `json:"logsDir"`         // Per-game logs; empty disables them
+	Storage         string   `json:"storage"`         // StorageFile or StorageMemory
+	DataDir         string   `json:"dataDir"`
+	MaxGames        int      `json:"maxGames"`        // Games open at once; zero is unlimited
+	IdleGameTimeout Duration `json:"idleGameTimeout"` // Games quiet for this long are closed; zero never closes them
+	DefaultRules    string   `json:"defaultRules"`    // Rules preset for games that do not name one
+	Decks           int      `json:"decks"`           // Decks in games with the default rules; zero keeps the preset's
+	ShutdownDrain   Duration `json:"shutdownDrain"`   // How long /readyz answers 503 before the server stops listening
+	ShutdownTimeout Duration `json:"shutdownTimeout"` // Deadline for notifying and closing connections when stopping
+	RestartIn       Duration `json:"restartIn"`       // Announced to players as the expected downtime; zero announces none
+	AdminToken      string   `json:"adminToken,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "30m" in the config file.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30m\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	*d = Duration(parsed)
+	return err
+}
+
+// cfg is the configuration the server runs with.
+var cfg = defaultConfig()
+
+func defaultConfig() Config {
+	return Config{
+		Addr:            ":8008",
+		AllowedOrigins:  []string{},
+		LogsDir:         "./logs",
+		Storage:         StorageFile,
+		DataDir:         "./data",
+		IdleGameTimeout: Duration(time.Hour),
+		DefaultRules:    game.DefaultRulesPreset,
+		ShutdownDrain:   Duration(5 * time.Second),
+		ShutdownTimeout: Duration(10 * time.Second),
+	}
+}
+
+// flagSet defines the command-line flags, each bound to a field of c. Every flag
+// can also be given as an environment variable named after it, e.g. -max-games
+// as SEQUENCE_MAX_GAMES.
+func (c *Config) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("sequence-game", flag.ContinueOnError)
+	fs.StringVar(&c.Addr, "addr", c.Addr, "Address to listen on")
+	fs.StringVar(&c.TLSCert, "tls-cert", c.TLSCert, "TLS certificate file; with -tls-key, serves HTTPS and WSS")
+	fs.StringVar(&c.TLSKey, "tls-key", c.TLSKey, "TLS private key file")
+	fs.Func("allowed-origins", "Comma-separated origins besides the server's own allowed to open WebSockets; * allows any", func(v string) error {
+		c.AllowedOrigins = []string{}
+		for _, origin := range strings.Split(v, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				c.AllowedOrigins = append(c.AllowedOrigins, origin)
+			}
+		}
+		return nil
+	})
+	fs.StringVar(&c.StaticDir, "static-dir", c.StaticDir, "Serve the web client from this directory, e.g. ./static while working on it; empty serves the embedded copy")
+	fs.BoolVar(&c.RequireVendored, "require-vendored", c.RequireVendored, "Refuse to start until the vendored scripts are downloaded and their checksums pinned, rather than have browsers load them from their CDN")
+	fs.StringVar(&c.LogsDir, "logs-dir", c.LogsDir, "Directory for game logs; empty disables them")
+	fs.StringVar(&c.Storage, "storage", c.Storage, "Storage backend: file (under -data-dir) or memory")
+	fs.StringVar(&c.DataDir, "data-dir", c.DataDir, "Directory for accounts, history, ratings and tournaments")
+	fs.IntVar(&c.MaxGames, "max-games", c.MaxGames, "Maximum games open at once; 0 is unlimited")
+	fs.DurationVar((*time.Duration)(&c.IdleGameTimeout), "idle-game-timeout", time.Duration(c.IdleGameTimeout), "Close games without activity for this long; 0 never closes them")
+	fs.StringVar(&c.DefaultRules, "default-rules", c.DefaultRules, "Rules preset for games that do not name one ("+strings.Join(game.RulesPresetNames(), ", ")+")")
+	fs.IntVar(&c.Decks, "decks", c.Decks, "Decks in games with the default rules; 0 keeps the preset's")
+	fs.DurationVar((*time.Duration)(&c.ShutdownDrain), "shutdown-drain", time.Duration(c.ShutdownDrain), "On SIGINT or SIGTERM, how long to keep serving while /readyz answers 503, so load balancers stop sending players; 0 stops at once")
+	fs.DurationVar((*time.Duration)(&c.ShutdownTimeout), "shutdown-timeout", time.Duration(c.ShutdownTimeout), "On SIGINT or SIGTERM, how long to take telling players and closing connections")
+	fs.DurationVar((*time.Duration)(&c.RestartIn), "restart-in", time.Duration(c.RestartIn), "Expected downtime, announced to players on shutdown; 0 announces none")
+	fs.StringVar(&c.AdminToken, "admin-token", c.AdminToken, "Bearer token for the tournament admin endpoints; empty disables them")
+	return fs
+}
+
+// envName is the environment variable of a flag.
+func envName(flagName string) string {
+	return "SEQUENCE_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// loadConfig builds the configuration from the config file, the environment and
+// args, and validates it. It also reports whether -print-config was given.
+func loadConfig(args []string) (Config, bool, error) {
+	// A first pass finds the config file, which the flags then override
+	c := defaultConfig()
+	fs := c.flagSet()
+	configFile := fs.String("config", os.Getenv("SEQUENCE_CONFIG"), "JSON config file (env SEQUENCE_CONFIG); flags and SEQUENCE_* variables override it")
+	printConfig := fs.Bool("print-config", false, "Print the effective configuration as JSON and exit")
+	if err := fs.Parse(args); err != nil {
+		return c, false, err
+	}
+
+	c = defaultConfig()
+	if *configFile != "" {
+		if err := c.loadFile(*configFile); err != nil {
+			return c, false, err
+		}
+	}
+	fs = c.flagSet()
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		if v, ok := os.LookupEnv(envName(f.Name)); ok {
+			if err := fs.Set(f.Name, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s=%q: %w", envName(f.Name), v, err))
+			}
+		}
+	})
+	if len(errs) > 0 {
+		return c, false, errors.Join(errs...)
+	}
+	fs.String("config", "", "")
+	fs.Bool("print-config", false, "")
+	if err := fs.Parse(args); err != nil {
+		return c, false, err
+	}
+	return c, *printConfig, c.validate()
+}
+
+// loadFile reads a JSON config file over c. Unknown settings are an error, so a
+// misspelt one is not silently ignored.
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("reading config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// validate reports every problem with the configuration at once.
+func (c *Config) validate() error {
+	var errs []error
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("addr: %w", err))
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		errs = append(errs, errors.New("tls-cert and tls-key must be given together"))
+	}
+	for _, file := range []string{c.TLSCert, c.TLSKey} {
+		if file != "" {
+			if _, err := os.Stat(file); err != nil {
+				errs = append(errs, fmt.Errorf("tls: %w", err))
+			}
+		}
+	}
+	for _, origin := range c.AllowedOrigins {
+		if u, err := url.Parse(origin); origin != "*" && (err != nil || u.Scheme == "" || u.Host == "") {
+			errs = append(errs, fmt.Errorf("allowed-origins: %q is not an origin such as https://example.com", origin))
+		}
+	}
+	switch c.Storage {
+	case StorageFile:
+		if c.DataDir == "" {
+			errs = append(errs, errors.New("data-dir must not be empty with file storage"))
+		}
+	case StorageMemory:
+	default:
+		errs = append(errs, fmt.Errorf("storage: unknown backend %q (available: %s, %s)", c.Storage, StorageFile, StorageMemory))
+	}
+	if c.MaxGames < 0 {
+		errs = append(errs, fmt.Errorf("max-games must not be negative, got %d", c.MaxGames))
+	}
+	if c.IdleGameTimeout < 0 {
+		errs = append(errs, fmt.Errorf("idle-game-timeout must not be negative, got %s", time.Duration(c.IdleGameTimeout)))
+	}
+	if c.ShutdownDrain < 0 {
+		errs = append(errs, fmt.Errorf("shutdown-drain must not be negative, got %s", time.Duration(c.ShutdownDrain)))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdown-timeout must be positive, got %s", time.Duration(c.ShutdownTimeout)))
+	}
+	if c.RestartIn < 0 {
+		errs = append(errs, fmt.Errorf("restart-in must not be negative, got %s", time.Duration(c.RestartIn)))
+	}
+	if _, err := c.defaultRules(); err != nil {
+		errs = append(errs, fmt.Errorf("default rules: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// defaultRules returns the rules of games that do not name a preset.
+func (c *Config) defaultRules() (game.Rules, error) {
+	rules, err := game.RulesPreset(c.DefaultRules)
+	if err != nil {
+		return rules, err
+	}
+	if c.Decks != 0 {
+		rules.NumDecks = c.Decks
+	}
+	return rules, rules.Validate()
+}
+
+// dataFile is the path of a store's file, or "" to keep the store in memory.
+func (c *Config) dataFile(name string) string {
+	if c.Storage == StorageMemory {
+		return ""
+	}
+	return filepath.Join(c.DataDir, name)
+}
+
+// checkOrigin lets WebSockets be opened from the server's own pages, by clients
+// that send no Origin (such as the SDK), and from the allowed origins.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || slices.Contains(cfg.AllowedOrigins, "*") || slices.Contains(cfg.AllowedOrigins, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// printConfig writes the configuration as JSON, in the config file's format. The
+// admin token is left out.
+func printConfig(c Config) error {
+	c.AdminToken = ""
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Println(string(data))
+	return err
+}
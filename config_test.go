@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	tests := []struct {
+		name         string
+		file         string // Config file contents; empty for none
+		env          map[string]string
+		args         []string
+		wantAddr     string
+		wantMaxGames int
+		wantIdle     time.Duration
+	}{
+		{name: "defaults", wantAddr: ":8008", wantIdle: time.Hour},
+		{name: "file", file: `{"addr": ":9000", "maxGames": 5, "idleGameTimeout": "30m"}`, wantAddr: ":9000", wantMaxGames: 5, wantIdle: 30 * time.Minute},
+		{
+			name: "environment over the file",
+			file: `{"addr": ":9000", "maxGames": 5}`, env: map[string]string{"SEQUENCE_MAX_GAMES": "7", "SEQUENCE_IDLE_GAME_TIMEOUT": "2m"},
+			wantAddr: ":9000", wantMaxGames: 7, wantIdle: 2 * time.Minute,
+		},
+		{
+			name: "flags over the environment",
+			file: `{"addr": ":9000", "maxGames": 5}`, env: map[string]string{"SEQUENCE_MAX_GAMES": "7", "SEQUENCE_ADDR": ":9100"},
+			args:     []string{"-max-games", "9"},
+			wantAddr: ":9100", wantMaxGames: 9, wantIdle: time.Hour,
+		},
+		{name: "config file from the environment", file: `{"maxGames": 3}`, env: map[string]string{"SEQUENCE_CONFIG": "<file>"}, wantAddr: ":8008", wantMaxGames: 3, wantIdle: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			var path string
+			if tt.file != "" {
+				path = filepath.Join(t.TempDir(), "config.json")
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				if tt.env["SEQUENCE_CONFIG"] == "" {
+					args = append([]string{"-config", path}, args...)
+				}
+			}
+			for k, v := range tt.env {
+				if v == "<file>" {
+					v = path
+				}
+				t.Setenv(k, v)
+			}
+
+			c, _, err := loadConfig(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Addr != tt.wantAddr || c.MaxGames != tt.wantMaxGames || time.Duration(c.IdleGameTimeout) != tt.wantIdle {
+				t.Errorf("addr %q, max games %d, idle timeout %s; want %q, %d, %s",
+					c.Addr, c.MaxGames, time.Duration(c.IdleGameTimeout), tt.wantAddr, tt.wantMaxGames, tt.wantIdle)
+			}
+		})
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want []string // Substrings of the error
+	}{
+		{name: "unknown setting in the file", file: `{"maxGame": 5}`, want: []string{`unknown field "maxGame"`}},
+		{name: "bad environment variable", env: map[string]string{"SEQUENCE_MAX_GAMES": "many"}, want: []string{"SEQUENCE_MAX_GAMES"}},
+		{
+			name: "every invalid setting",
+			args: []string{"-addr", "nowhere", "-tls-cert", "cert.pem", "-storage", "cloud", "-max-games", "-1", "-shutdown-timeout", "0", "-default-rules", "Chess"},
+			want: []string{"addr:", "tls-cert and tls-key", "storage: unknown backend", "max-games", "shutdown-timeout", "default rules"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "config.json")
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				args = append([]string{"-config", path}, args...)
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, _, err := loadConfig(args)
+			if err == nil {
+				t.Fatal("loadConfig succeeded")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	c := defaultConfig()
+	c.AdminToken = "secret-token"
+	c.MaxGames = 4
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	err = printConfig(c)
+	os.Stdout = stdout
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(out), "secret-token") || strings.Contains(string(out), "adminToken") {
+		t.Errorf("printed the admin token:\n%s", out)
+	}
+	// The output is a config file that loads back to the same settings
+	var printed Config
+	if err := json.Unmarshal(out, &printed); err != nil {
+		t.Fatal(err)
+	}
+	if printed.MaxGames != 4 || printed.IdleGameTimeout != c.IdleGameTimeout || printed.Addr != c.Addr {
+		t.Errorf("printed %+v, want %+v without the token", printed, c)
+	}
+}
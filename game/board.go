@@ -0,0 +1,177 @@
+package game
+
+import (
+	"fmt"
+	"log"
+)
+
+// BoardSpace represents a single space on the game board
+type BoardSpace struct {
+	Card         *Card  `json:"card,omitempty"` // The card printed on this space (nil for corners)
+	OccupiedBy   string `json:"occupiedBy"`     // PlayerID of the chip on this space, or "" if empty
+	IsCorner     bool   `json:"isCorner"`       // To mark the free corner spaces
+	IsLocked     bool   `json:"isLocked"`       // If part of a completed sequence
+	DisplayValue string `json:"displayValue"`   // e.g. "A♠️", "7♦️", "FREE"
+}
+
+// Position represents a coordinate on the board
+type Position struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// --- Board Initialization ---
+
+// Standard Sequence Game Board Layout.
+// Each of the 48 non-Jack cards (A, K, Q, 10, 9, 8, 7, 6, 5, 4, 3, 2 for each suit) appears twice.
+// "FREE" denotes a corner space. Jacks are not on the board.
+// Card IDs are "RankSuit", e.g., "AS", "10D".
+// Suffixes like "_alt" are used in this array literal for the second instance of a card
+// to make the string unique if needed for visual clarity or specific tools;
+// ParseCardID function will strip these suffixes to get the canonical card ID.
+var boardCardDistribution = [BoardSize][BoardSize]string{
+	{"FREE", "2S", "3S", "4S", "5S", "6S", "7S", "8S", "9S", "FREE"},
+	{"6C", "7C", "8C", "9C", "10C", "QC", "KC", "AC", "AD", "10S"},
+	{"5C", "4C", "3C", "2C", "AH", "KH", "QH", "10H", "KD", "AS"},
+	{"4D", "5D", "6D", "7D", "8D", "9D", "10D", "QD", "KS", "2D"},
+	{"3D", "2D_alt", "AS_alt", "KS_alt", "QS", "10S_alt", "9S_alt", "8S_alt", "QS_alt", "2H"},
+	{"4H", "5H", "6H", "7H", "8H", "9H", "10H_alt", "QH_alt", "KH_alt", "3H"},
+	{"3S_alt", "2H_alt", "AH_alt", "AC_alt", "KC_alt", "QC_alt", "10C_alt", "9C_alt", "8C_alt", "4S_alt"},
+	{"2S_alt", "3H_alt", "4H_alt", "5H_alt", "6H_alt", "7H_alt", "8H_alt", "9H_alt", "7C_alt", "5S_alt"},
+	{"AD_alt", "KD_alt", "QD_alt", "10D_alt", "9D_alt", "8D_alt", "7D_alt", "6D_alt", "6C_alt", "6S_alt"},
+	{"FREE", "5C_alt", "4C_alt", "3C_alt", "2C_alt", "4D_alt", "5D_alt", "7S_alt", "9S_another", "FREE"}, // Corrected last row for standard layout
+}
+
+func (g *Game) initializeBoardLayout() {
+	for r := 0; r < BoardSize; r++ {
+		for c := 0; c < BoardSize; c++ {
+			idOnBoard := boardCardDistribution[r][c]
+			if g.Rules.Layout != nil {
+				idOnBoard = g.Rules.Layout[r][c]
+			}
+			if idOnBoard == "FREE" {
+				g.Board[r][c] = BoardSpace{IsCorner: true, OccupiedBy: "CORNER", DisplayValue: "FREE"}
+			} else if idOnBoard == "EMPTY" || idOnBoard == "" { // Should not happen with complete board
+				g.Board[r][c] = BoardSpace{DisplayValue: " "}
+			} else {
+				card, err := ParseCardID(idOnBoard)
+				if err != nil {
+					log.Printf("Error parsing card ID '%s' for board at [%d][%d]: %v. Setting as 'ERR'.", idOnBoard, r, c, err)
+					g.Board[r][c] = BoardSpace{DisplayValue: "ERR"}
+					continue
+				}
+				g.Board[r][c] = BoardSpace{Card: card, DisplayValue: card.ToEmojiString()}
+			}
+		}
+	}
+	if g.Rules.Layout != nil {
+		log.Println("Board initialized with a custom layout.")
+		return
+	}
+	log.Println("Board initialized with a standard Sequence board layout.")
+}
+
+// onBoard reports whether a position is on the board
+func onBoard(pos Position) bool {
+	return pos.X >= 0 && pos.X < BoardSize && pos.Y >= 0 && pos.Y < BoardSize
+}
+
+// countsFor reports whether a board space counts towards a sequence for the player:
+// a free corner, or a chip of theirs or a teammate's.
+func (g *Game) countsFor(space BoardSpace, playerID string) bool {
+	if space.IsCorner {
+		return g.Rules.CornersFree
+	}
+	return space.OccupiedBy != "" && g.sameSide(space.OccupiedBy, playerID)
+}
+
+// --- Sequence Detection ---
+
+// checkForSequencesAfterPlay
+func (g *Game) checkForSequencesAfterPlay(playerID string, x, y int) int {
+	sequencesFound := 0
+	dirs := [][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
+
+	for _, dir := range dirs {
+		count := 1
+		chipsInSequence := []Position{{X: x, Y: y}}
+
+		for i := 1; i < g.Rules.SequenceLength; i++ {
+			nx, ny := x+dir[0]*i, y+dir[1]*i
+			if nx < 0 || nx >= BoardSize || ny < 0 || ny >= BoardSize {
+				break
+			}
+			if g.countsFor(g.Board[nx][ny], playerID) {
+				count++
+				chipsInSequence = append(chipsInSequence, Position{X: nx, Y: ny})
+			} else {
+				break
+			}
+		}
+		for i := 1; i < g.Rules.SequenceLength; i++ {
+			nx, ny := x-dir[0]*i, y-dir[1]*i
+			if nx < 0 || nx >= BoardSize || ny < 0 || ny >= BoardSize {
+				break
+			}
+			if g.countsFor(g.Board[nx][ny], playerID) {
+				count++
+				chipsInSequence = append(chipsInSequence, Position{X: nx, Y: ny})
+			} else {
+				break
+			}
+		}
+
+		if count >= g.Rules.SequenceLength {
+			isNewSequence := false
+			for _, pos := range chipsInSequence {
+				boardChip := g.Board[pos.X][pos.Y]
+				if !boardChip.IsLocked || boardChip.IsCorner || (pos.X == x && pos.Y == y) {
+					isNewSequence = true
+					break
+				}
+			}
+			if isNewSequence {
+				sequencesFound++
+				g.logf("Sequence of %d found for player %s at (%d,%d) in dir (%d,%d)", count, playerID, x, y, dir[0], dir[1])
+				for _, pos := range chipsInSequence {
+					if !g.Board[pos.X][pos.Y].IsCorner {
+						g.Board[pos.X][pos.Y].IsLocked = true
+					}
+				}
+			}
+		}
+	}
+	return sequencesFound
+}
+
+// --- Helper: Count unique sequences only ---
+func (g *Game) countUniqueSequences(playerID string) int {
+	locked := make(map[string]bool)
+	unique := 0
+	dirs := [][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
+	for x := 0; x < BoardSize; x++ {
+		for y := 0; y < BoardSize; y++ {
+			for _, dir := range dirs {
+				seq := make([][2]int, 0, g.Rules.SequenceLength)
+				ok := true
+				for i := 0; i < g.Rules.SequenceLength; i++ {
+					nx, ny := x+dir[0]*i, y+dir[1]*i
+					if nx < 0 || nx >= BoardSize || ny < 0 || ny >= BoardSize {
+						ok = false
+						break
+					}
+					if !g.countsFor(g.Board[nx][ny], playerID) {
+						ok = false
+						break
+					}
+					seq = append(seq, [2]int{nx, ny})
+				}
+				if ok && !locked[fmt.Sprint(seq)] {
+					unique++
+					locked[fmt.Sprint(seq)] = true
+				}
+			}
+		}
+	}
+	return unique
+}
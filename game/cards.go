@@ -0,0 +1,223 @@
+package game
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big" // For crypto/rand
+	mrand "math/rand"
+	"strconv"
+	"strings"
+)
+
+// --- Enums for Cards ---
+type Suit int
+
+const (
+	Hearts Suit = iota
+	Diamonds
+	Clubs
+	Spades
+	NoSuit // For Jokers or special cards if extended
+)
+
+// String for internal ID construction (e.g., "H", "S")
+func (s Suit) String() string {
+	return []string{"H", "D", "C", "S", "X"}[s]
+}
+
+// MarshalText encodes a suit as its letter, e.g. in rules JSON
+func (s Suit) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText parses a suit letter ("H", "D", "C", "S")
+func (s *Suit) UnmarshalText(text []byte) error {
+	for candidate := Hearts; candidate < NoSuit; candidate++ {
+		if candidate.String() == string(text) {
+			*s = candidate
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown suit %q", text)
+}
+
+// ToEmoji for display
+func (s Suit) ToEmoji() string {
+	return map[Suit]string{Hearts: "♥️", Diamonds: "♦️", Clubs: "♣️", Spades: "♠️", NoSuit: ""}[s]
+}
+
+type Rank int
+
+const (
+	Ace Rank = iota + 1 // Ace as 1 for simplicity in loops, can map to 'A'
+	Two
+	Three
+	Four
+	Five
+	Six
+	Seven
+	Eight
+	Nine
+	Ten   // Numeric 10
+	Jack  // J
+	Queen // Q
+	King  // K
+	NoRank
+)
+
+// String for internal ID construction (e.g., "A", "10", "K")
+func (r Rank) String() string {
+	if r >= Two && r <= Ten {
+		return strconv.Itoa(int(r)) // "2", "3", ..., "10"
+	}
+	return map[Rank]string{
+		Ace:   "A",
+		Jack:  "J",
+		Queen: "Q",
+		King:  "K",
+	}[r]
+}
+
+// ToUnicode for display part of emoji string
+func (r Rank) ToUnicode() string {
+	// Same as String() for ranks, but explicit for display purposes
+	if r >= Two && r <= Ten {
+		return strconv.Itoa(int(r))
+	}
+	return map[Rank]string{Ace: "A", Jack: "J", Queen: "Q", King: "K"}[r]
+}
+
+// --- Core Data Structures ---
+
+// Card represents a playing card
+type Card struct {
+	Rank Rank
+	Suit Suit
+	ID   string // e.g., "KH" for King of Hearts, "10S" for 10 of Spades
+}
+
+// ToEmojiString creates a display string like "A♠️"
+func (c Card) ToEmojiString() string {
+	if c.Rank == NoRank || c.Suit == NoSuit {
+		return c.ID // Fallback for special cases or if ID is already display-ready
+	}
+	return c.Rank.ToUnicode() + c.Suit.ToEmoji()
+}
+
+// --- Card and Deck Logic ---
+
+// newDeck creates a specified number of standard 52-card decks
+func newDeck(numDecks int) []Card {
+	var deck []Card
+	suits := []Suit{Hearts, Diamonds, Clubs, Spades}
+	ranks := []Rank{Ace, Two, Three, Four, Five, Six, Seven, Eight, Nine, Ten, Jack, Queen, King}
+
+	for i := 0; i < numDecks; i++ {
+		for _, suit := range suits {
+			for _, rank := range ranks {
+				// Card ID is canonical, e.g., "AS", "10H", "KD"
+				cardID := rank.String() + suit.String()
+				deck = append(deck, Card{Rank: rank, Suit: suit, ID: cardID})
+			}
+		}
+	}
+	return deck
+}
+
+// shuffleDeck shuffles a slice of cards, from rng if it is set (reproducible deals) or crypto/rand otherwise
+func shuffleDeck(deck []Card, rng *mrand.Rand) {
+	n := len(deck)
+	for i := n - 1; i > 0; i-- {
+		var j int64
+		if rng != nil {
+			j = rng.Int63n(int64(i + 1))
+		} else {
+			jBig, _ := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+			j = jBig.Int64()
+		}
+		deck[i], deck[j] = deck[j], deck[i]
+	}
+}
+
+// ParseCardID parses a string like "AS" or "10D_alt" into a Card struct.
+// It expects canonical IDs (e.g., "10S" not "TS").
+func ParseCardID(idStr string) (*Card, error) {
+	if len(idStr) < 2 {
+		return nil, fmt.Errorf("card ID too short: %s", idStr)
+	}
+
+	// Normalize by removing potential suffixes like "_alt", "_another", etc.
+	normalizedIDStr := idStr
+	if strings.Contains(idStr, "_") {
+		parts := strings.Split(idStr, "_")
+		normalizedIDStr = parts[0] // Take the part before the first underscore
+	}
+
+	rankStr := ""
+	suitChar := ""
+
+	// Handle "10" rank
+	if strings.HasPrefix(normalizedIDStr, "10") {
+		if len(normalizedIDStr) != 3 {
+			return nil, fmt.Errorf("invalid card ID for 10: %s (from %s)", normalizedIDStr, idStr)
+		}
+		rankStr = "10"
+		suitChar = string(normalizedIDStr[2])
+	} else {
+		if len(normalizedIDStr) != 2 {
+			return nil, fmt.Errorf("invalid card ID format: %s (from %s)", normalizedIDStr, idStr)
+		}
+		rankStr = string(normalizedIDStr[0])
+		suitChar = string(normalizedIDStr[1])
+	}
+
+	var rank Rank
+	switch rankStr {
+	case "A":
+		rank = Ace
+	case "2":
+		rank = Two
+	case "3":
+		rank = Three
+	case "4":
+		rank = Four
+	case "5":
+		rank = Five
+	case "6":
+		rank = Six
+	case "7":
+		rank = Seven
+	case "8":
+		rank = Eight
+	case "9":
+		rank = Nine
+	case "10":
+		rank = Ten
+	case "J": // Only on custom layouts with the "Jacks on the board" rule
+		rank = Jack
+	case "Q":
+		rank = Queen
+	case "K":
+		rank = King
+	default:
+		return nil, fmt.Errorf("unknown rank string: '%s' in ID '%s' (from %s)", rankStr, normalizedIDStr, idStr)
+	}
+
+	var suit Suit
+	switch suitChar {
+	case "S":
+		suit = Spades
+	case "H":
+		suit = Hearts
+	case "D":
+		suit = Diamonds
+	case "C":
+		suit = Clubs
+	default:
+		return nil, fmt.Errorf("unknown suit char: '%s' in ID '%s' (from %s)", suitChar, normalizedIDStr, idStr)
+	}
+
+	// Construct canonical ID to ensure consistency
+	canonicalID := rank.String() + suit.String()
+	return &Card{Rank: rank, Suit: suit, ID: canonicalID}, nil
+}
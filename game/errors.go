@@ -0,0 +1,73 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorCode is the machine-readable reason an action was rejected, so clients
+// and bots can branch on it instead of parsing the message.
+type ErrorCode string
+
+const (
+	CodeGameNotJoinable   ErrorCode = "GAME_NOT_JOINABLE"
+	CodeGameFull          ErrorCode = "GAME_FULL"
+	CodeNotHost           ErrorCode = "NOT_HOST"
+	CodeNotInLobby        ErrorCode = "NOT_IN_LOBBY"
+	CodeNotEnoughPlayers  ErrorCode = "NOT_ENOUGH_PLAYERS"
+	CodeGameNotInProgress ErrorCode = "GAME_NOT_IN_PROGRESS"
+	CodeNotYourTurn       ErrorCode = "NOT_YOUR_TURN"
+	CodePlayerNotFound    ErrorCode = "PLAYER_NOT_FOUND"
+	CodeCardNotInHand     ErrorCode = "CARD_NOT_IN_HAND"
+	CodeInvalidPosition   ErrorCode = "INVALID_POSITION"
+	CodeInvalidMove       ErrorCode = "INVALID_MOVE"  // The card cannot be played as the requested move kind
+	CodeCardMismatch      ErrorCode = "CARD_MISMATCH" // The space shows a different card
+	CodeSpaceOccupied     ErrorCode = "SPACE_OCCUPIED"
+	CodeCornerSpace       ErrorCode = "CORNER_SPACE"
+	CodeEmptySpace        ErrorCode = "EMPTY_SPACE" // Nothing to remove or swap
+	CodeOwnChip           ErrorCode = "OWN_CHIP"
+	CodeLockedChip        ErrorCode = "LOCKED_CHIP"
+	CodeInvalidSwap       ErrorCode = "INVALID_SWAP"
+	CodeDeadCardUsed      ErrorCode = "DEAD_CARD_USED" // Already turned in a dead card this turn
+	CodeCardNotDead       ErrorCode = "CARD_NOT_DEAD"
+	CodeNoDrawPending     ErrorCode = "NO_DRAW_PENDING"
+	CodeDrawPileEmpty     ErrorCode = "DRAW_PILE_EMPTY"
+	CodeUnevenTeams       ErrorCode = "UNEVEN_TEAMS" // A team game cannot start until every team has as many players
+)
+
+// ErrorCodes lists every engine error code.
+var ErrorCodes = []ErrorCode{
+	CodeGameNotJoinable, CodeGameFull, CodeNotHost, CodeNotInLobby, CodeNotEnoughPlayers,
+	CodeGameNotInProgress, CodeNotYourTurn, CodePlayerNotFound, CodeCardNotInHand, CodeInvalidPosition,
+	CodeInvalidMove, CodeCardMismatch, CodeSpaceOccupied, CodeCornerSpace, CodeEmptySpace, CodeOwnChip,
+	CodeLockedChip, CodeInvalidSwap, CodeDeadCardUsed, CodeCardNotDead, CodeNoDrawPending, CodeDrawPileEmpty,
+	CodeUnevenTeams,
+}
+
+// ActionError is a rejected action. errors.Is matches any ActionError with the
+// same code, e.g. errors.Is(err, &ActionError{Code: CodeNotYourTurn}).
+type ActionError struct {
+	Code    ErrorCode
+	Message string
+}
+
+func (e *ActionError) Error() string { return e.Message }
+
+func (e *ActionError) Is(target error) bool {
+	t, ok := target.(*ActionError)
+	return ok && t.Code == e.Code
+}
+
+// reject builds an ActionError with a formatted message.
+func reject(code ErrorCode, format string, args ...interface{}) error {
+	return &ActionError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// CodeOf returns the code of the ActionError in err's chain, or "" if there is none.
+func CodeOf(err error) ErrorCode {
+	var actionErr *ActionError
+	if errors.As(err, &actionErr) {
+		return actionErr.Code
+	}
+	return ""
+}
@@ -0,0 +1,737 @@
+package game
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	mrand "math/rand"
+	"sync"
+	"time"
+)
+
+// --- Constants & Configuration ---
+const (
+	BoardSize             = 10
+	NumDecks              = 2
+	DefaultSequencesToWin = 2
+	MaxSequencesToWin     = 4 // The most sequences rules may ask for
+	DefaultMaxPlayers     = 4 // Lobby size when CREATE_GAME does not set one
+	MaxTeams              = 3 // Team games have two or three teams
+)
+
+// --- Core Data Structures ---
+
+// Conn is where a player's updates are written, e.g. a *websocket.Conn
+type Conn interface {
+	WriteJSON(v interface{}) error
+}
+
+// Player represents a player in the game
+type Player struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Hand        []Card   `json:"-"`                     // Hide hand from other players in general broadcasts
+	VisibleHand []string `json:"visibleHand,omitempty"` // For the player themselves (contains Card.ID)
+	ChipColor   string   `json:"chipColor"`
+	Sequences   int      `json:"sequences"`
+	Conn        Conn     `json:"-"` // WebSocket connection
+	IsConnected bool     `json:"isConnected"`
+	HandLimit   int      `json:"handLimit"`      // Cards dealt, minus any draws lost under the explicit-draw rule
+	JacksUsed   int      `json:"jacksUsed"`      // Jacks played for their power: wild, remove or swap
+	DeadCards   int      `json:"deadCards"`      // Dead cards turned in
+	Bot         bool     `json:"bot,omitempty"`  // Played by a program, which keeps the game off the ratings
+	Team        string   `json:"team,omitempty"` // In a team game; teammates share a chip colour and their sequences
+}
+
+// Side is who the player's chips count for: their team, or the player themselves
+// when they play alone.
+func (p *Player) Side() string {
+	if p.Team != "" {
+		return p.Team
+	}
+	return p.ID
+}
+
+// Game represents the entire game state
+type Game struct {
+	ID                string                           `json:"id"`
+	Board             [BoardSize][BoardSize]BoardSpace `json:"board"`
+	Players           map[string]*Player               `json:"players"`     // Map PlayerID to Player struct
+	PlayerOrder       []string                         `json:"playerOrder"` // To maintain turn order
+	CurrentTurnIndex  int                              `json:"currentTurnIndex"`
+	DrawPile          []Card                           `json:"-"` // Not usually sent to client
+	DrawPileCount     int                              `json:"drawPileCount"`
+	DiscardPile       []Card                           `json:"-"`
+	GamePhase         string                           `json:"gamePhase"`        // e.g., "Lobby", "InProgress", "Finished"
+	Winner            string                           `json:"winner,omitempty"` // PlayerID; in a team game their whole team wins
+	NumSequencesToWin int                              `json:"numSequencesToWin"`
+	MaxPlayers        int                              `json:"maxPlayers"`
+	HostID            string                           `json:"hostId"`
+	HotSeat           bool                             `json:"hotSeat,omitempty"` // Every seat is played from the host's connection
+	Ranked            bool                             `json:"ranked,omitempty"`  // Counts towards ratings; played without hints
+	Teams             int                              `json:"teams,omitempty"`   // Number of teams; 0 when everyone plays for themselves
+	Rules             Rules                            `json:"rules"`
+	DeadCardUsed      bool                             `json:"deadCardUsed"`          // Current player already turned in a dead card this turn
+	PendingDraw       string                           `json:"pendingDraw,omitempty"` // PlayerID that still owes a DRAW_CARD
+	MoveCount         int                              `json:"moveCount"`
+	StartedAt         time.Time                        `json:"startedAt"`
+	FinishedAt        time.Time                        `json:"finishedAt"`
+
+	rng      *mrand.Rand       // Set by Seed for reproducible shuffles; nil uses crypto/rand
+	quiet    bool              // Copies made for look-ahead do not log their moves
+	reserved map[string]string // Team each player joins, by player ID; see ReserveTeam
+
+	sync.Mutex `json:"-"` // Guards the game state; the server holds it while broadcasting
+}
+
+// PlayerAction is a single move: the card played and where it goes
+type PlayerAction struct {
+	CardID    string    `json:"cardId"`
+	BoardPos  Position  `json:"boardPos"`
+	MoveKind  MoveKind  `json:"moveKind,omitempty"`  // PLACE, WILD, REMOVE or SWAP; inferred from the card when empty
+	TargetPos *Position `json:"targetPos,omitempty"` // Destination of a SWAP
+}
+
+// ChipColors are the chip colours, handed out in this order.
+var ChipColors = []string{"red", "blue", "green", "yellow", "purple", "orange", "pink", "cyan", "lime", "brown", "teal", "magenta"}
+
+// GenerateID creates a unique random ID
+func GenerateID() string {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return "errorID" // Fallback
+	}
+	return hex.EncodeToString(bytes)
+}
+
+// --- Card Handling ---
+
+// dealCards deals cards to players based on game rules
+func (g *Game) dealCards() {
+	numPlayers := len(g.Players)
+	if numPlayers > g.Rules.MaxSupportedPlayers() {
+		g.logf("Warning: Too many players (%d) for the %s hand size table.", numPlayers, g.Rules.Preset)
+	}
+	cardsPerPlayer := g.Rules.HandSizeFor(numPlayers)
+	for _, player := range g.Players {
+		player.HandLimit = cardsPerPlayer
+	}
+
+	for i := 0; i < cardsPerPlayer; i++ {
+		for _, playerID := range g.PlayerOrder {
+			player := g.Players[playerID]
+			if len(g.DrawPile) > 0 {
+				card := g.DrawPile[0]
+				g.DrawPile = g.DrawPile[1:]
+				player.Hand = append(player.Hand, card)
+			}
+		}
+	}
+	g.DrawPileCount = len(g.DrawPile)
+}
+
+// drawCard allows a player to draw a card
+func (g *Game) drawCard(playerID string) (*Card, error) {
+	player, ok := g.Players[playerID]
+	if !ok {
+		return nil, reject(CodePlayerNotFound, "player %s not found", playerID)
+	}
+	if len(g.DrawPile) == 0 && g.Rules.DrawPileEmpty == ExhaustionReshuffle && len(g.DiscardPile) > 0 {
+		g.logf("Draw pile exhausted in game %s, reshuffling %d discarded cards", g.ID, len(g.DiscardPile))
+		g.DrawPile = g.DiscardPile
+		g.DiscardPile = nil
+		shuffleDeck(g.DrawPile, g.rng)
+	}
+	if len(g.DrawPile) == 0 {
+		return nil, reject(CodeDrawPileEmpty, "draw pile is empty")
+	}
+
+	card := g.DrawPile[0]
+	g.DrawPile = g.DrawPile[1:]
+	g.DrawPileCount = len(g.DrawPile)
+	player.Hand = append(player.Hand, card)
+	return &card, nil
+}
+
+// removeCardFromHand removes a specific card (by Card.ID) from a player's hand
+func (p *Player) removeCardFromHand(cardID string) bool {
+	for i, cardInHand := range p.Hand {
+		if cardInHand.ID == cardID {
+			p.Hand = append(p.Hand[:i], p.Hand[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// GetCardFromHand retrieves a card from hand by Card.ID
+func (p *Player) GetCardFromHand(cardID string) (*Card, bool) {
+	for i := range p.Hand {
+		if p.Hand[i].ID == cardID {
+			return &p.Hand[i], true
+		}
+	}
+	return nil, false
+}
+
+// --- Game Actions & Logic ---
+
+// NewGame creates a new game instance. The rules must already be validated; a
+// sequencesToWin override is kept within what Validate allows, and maxPlayers
+// within the players the hand size table covers.
+func NewGame(hostID, hostName string, maxPlayers, sequencesToWin int, rules Rules) *Game {
+	gameID := GenerateID()
+	if sequencesToWin > 0 {
+		rules.SequencesToWin = min(sequencesToWin, MaxSequencesToWin)
+	}
+	if maxPlayers <= 0 {
+		maxPlayers = DefaultMaxPlayers
+	}
+	maxPlayers = min(maxPlayers, rules.MaxSupportedPlayers())
+
+	g := &Game{
+		ID: gameID, Players: make(map[string]*Player), DrawPile: newDeck(rules.NumDecks),
+		GamePhase: "Lobby", NumSequencesToWin: rules.SequencesToWin, MaxPlayers: maxPlayers,
+		HostID: hostID, CurrentTurnIndex: 0, Rules: rules,
+	}
+	g.initializeBoardLayout()
+	shuffleDeck(g.DrawPile, nil)
+	g.DrawPileCount = len(g.DrawPile)
+	log.Printf("New game created: %s by %s with %s rules", gameID, hostName, rules.Preset)
+	return g
+}
+
+// AddPlayer adds a player to the lobby, or reconnects them if PlayerID matches.
+// Only a seat's own player ID takes it back; once the game has started nobody new
+// can join.
+func (g *Game) AddPlayer(playerID, playerName string, conn Conn) (*Player, error) {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.GamePhase != "Lobby" && g.GamePhase != "InProgress" {
+		return nil, reject(CodeGameNotJoinable, "game %s is not joinable", g.ID)
+	}
+	if len(g.Players) >= g.MaxPlayers && g.Players[playerID] == nil {
+		return nil, reject(CodeGameFull, "game %s is full", g.ID)
+	}
+
+	// Rejoin by PlayerID
+	if existingPlayer, exists := g.Players[playerID]; exists {
+		existingPlayer.Conn = conn
+		existingPlayer.IsConnected = true
+		g.logf("Player %s (%s) rejoined game %s", existingPlayer.Name, playerID, g.ID)
+		return existingPlayer, nil
+	}
+	if g.GamePhase != "Lobby" {
+		return nil, reject(CodeGameNotJoinable, "game %s has started; only its players can rejoin", g.ID)
+	}
+
+	// New player: the first colour nobody has, as players may have picked theirs,
+	// unless they join a team that already has its colour
+	team, ok := g.reserved[playerID]
+	if !ok {
+		team = g.nextTeam()
+	}
+	chipColor := ChipColors[len(g.Players)%len(ChipColors)]
+	for _, color := range ChipColors {
+		if !g.colorTaken(color, "") {
+			chipColor = color
+			break
+		}
+	}
+	for _, p := range g.Players {
+		if team != "" && p.Team == team {
+			chipColor = p.ChipColor
+		}
+	}
+
+	player := &Player{
+		ID: playerID, Name: playerName, ChipColor: chipColor, Conn: conn,
+		IsConnected: true, Hand: make([]Card, 0), Team: team,
+	}
+	g.Players[playerID] = player
+	g.PlayerOrder = append(g.PlayerOrder, playerID)
+	if team != "" {
+		g.logf("Player %s (%s) added to game %s on %s with color %s", playerName, playerID, g.ID, team, chipColor)
+	} else {
+		g.logf("Player %s (%s) added to game %s with color %s", playerName, playerID, g.ID, chipColor)
+	}
+	return player, nil
+}
+
+// SetTeams makes a game in the lobby a team game of teams teams, or, with 0, one
+// where everyone plays for themselves. Players are seated on the team with the
+// fewest players as they join, unless ReserveTeam says otherwise, and StartGame
+// orders the turns to alternate between the teams.
+// It must be called before anyone joins.
+func (g *Game) SetTeams(teams int) error {
+	g.Lock()
+	defer g.Unlock()
+	if g.GamePhase != "Lobby" || len(g.Players) > 0 {
+		return reject(CodeNotInLobby, "teams are set before anyone joins")
+	}
+	if teams != 0 && (teams < 2 || teams > MaxTeams) {
+		return fmt.Errorf("a team game has 2 to %d teams, not %d", MaxTeams, teams)
+	}
+	if teams > 0 && (g.MaxPlayers < 2*teams || g.MaxPlayers%teams != 0) {
+		return fmt.Errorf("%d players cannot be split into %d teams of two or more", g.MaxPlayers, teams)
+	}
+	g.Teams = teams
+	return nil
+}
+
+// ReserveTeam seats a player on the given team when they join a team game in the
+// lobby, rather than on the team with the fewest players; e.g. so that the members
+// of a tournament team play together.
+func (g *Game) ReserveTeam(playerID, team string) error {
+	g.Lock()
+	defer g.Unlock()
+	if g.GamePhase != "Lobby" {
+		return reject(CodeNotInLobby, "teams are reserved in the lobby")
+	}
+	known := false
+	for n := 1; n <= g.Teams; n++ {
+		known = known || team == TeamName(n)
+	}
+	if !known {
+		return fmt.Errorf("game %s has no team %q", g.ID, team)
+	}
+	if g.reserved == nil {
+		g.reserved = make(map[string]string)
+	}
+	g.reserved[playerID] = team
+	return nil
+}
+
+// alternateTeams orders the turns so that they go round the teams, each team's
+// players taking their turns in the order they joined. The caller must hold the
+// game lock.
+func (g *Game) alternateTeams() {
+	byTeam := make(map[string][]string)
+	for _, id := range g.PlayerOrder {
+		team := g.Players[id].Team
+		byTeam[team] = append(byTeam[team], id)
+	}
+	order := make([]string, 0, len(g.PlayerOrder))
+	for i := 0; len(order) < len(g.PlayerOrder); i++ {
+		for n := 1; n <= g.Teams; n++ {
+			order = append(order, byTeam[TeamName(n)][i])
+		}
+	}
+	g.PlayerOrder = order
+}
+
+// TeamName is the ID of the nth team, from 1.
+func TeamName(n int) string {
+	return fmt.Sprintf("team%d", n)
+}
+
+// nextTeam is the team a new player joins: the one with the fewest players, the
+// first of them on a tie. It is "" outside of team games.
+func (g *Game) nextTeam() string {
+	if g.Teams == 0 {
+		return ""
+	}
+	sizes := g.teamSizes()
+	best := 1
+	for n := 2; n <= g.Teams; n++ {
+		if sizes[TeamName(n)] < sizes[TeamName(best)] {
+			best = n
+		}
+	}
+	return TeamName(best)
+}
+
+// teamSizes counts the players on each team.
+func (g *Game) teamSizes() map[string]int {
+	sizes := make(map[string]int)
+	for _, p := range g.Players {
+		sizes[p.Team]++
+	}
+	return sizes
+}
+
+// sameSide reports whether chips placed by a and b count for the same side: they
+// are the same player, or teammates.
+func (g *Game) sameSide(a, b string) bool {
+	if a == b {
+		return true
+	}
+	pa, pb := g.Players[a], g.Players[b]
+	return pa != nil && pb != nil && pa.Side() == pb.Side()
+}
+
+// PreferChipColor gives a player in the lobby the chip colour they prefer, unless
+// another side has it already; in a team game the whole team takes the colour.
+// It reports whether the player has it now.
+func (g *Game) PreferChipColor(playerID, color string) bool {
+	g.Lock()
+	defer g.Unlock()
+	player, ok := g.Players[playerID]
+	if !ok || g.GamePhase != "Lobby" {
+		return false
+	}
+	if player.ChipColor != color && !g.colorTaken(color, player.Side()) {
+		for _, p := range g.Players {
+			if p.Side() == player.Side() {
+				p.ChipColor = color
+			}
+		}
+	}
+	return player.ChipColor == color
+}
+
+// colorTaken reports whether a player of a side other than except has the colour.
+func (g *Game) colorTaken(color, except string) bool {
+	for _, p := range g.Players {
+		if p.ChipColor == color && p.Side() != except {
+			return true
+		}
+	}
+	return false
+}
+
+// Seed makes the deal, and any later reshuffle, reproducible by reshuffling the
+// draw pile from seed. Call it in the lobby, before StartGame.
+func (g *Game) Seed(seed int64) {
+	g.Lock()
+	defer g.Unlock()
+	g.rng = mrand.New(mrand.NewSource(seed))
+	g.DrawPile = newDeck(g.Rules.NumDecks)
+	shuffleDeck(g.DrawPile, g.rng)
+	g.DrawPileCount = len(g.DrawPile)
+}
+
+// StartGame transitions the game from Lobby to InProgress
+func (g *Game) StartGame(playerID string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.HostID != playerID {
+		return reject(CodeNotHost, "only the host can start the game")
+	}
+	if g.GamePhase != "Lobby" {
+		return reject(CodeNotInLobby, "game %s is not in lobby phase", g.ID)
+	}
+	if len(g.Players) < 2 {
+		return reject(CodeNotEnoughPlayers, "not enough players to start. Need at least 2, have %d", len(g.Players))
+	}
+	if g.Teams > 0 {
+		sizes := g.teamSizes()
+		for n := 1; n <= g.Teams; n++ {
+			if size := sizes[TeamName(n)]; size == 0 || size != sizes[TeamName(1)] {
+				return reject(CodeUnevenTeams, "every one of the %d teams needs the same number of players, at least one", g.Teams)
+			}
+		}
+		g.alternateTeams()
+	}
+
+	g.dealCards()
+	g.GamePhase = "InProgress"
+	g.StartedAt = time.Now()
+	g.CurrentTurnIndex = 0
+	g.logf("Game %s started by %s", g.ID, playerID)
+	return nil
+}
+
+// checkRemovable validates that the chip at pos may be taken away by a Jack played by playerID.
+func (g *Game) checkRemovable(playerID string, pos Position) error {
+	space := g.Board[pos.X][pos.Y]
+	if space.OccupiedBy == "" || space.IsCorner {
+		return reject(CodeEmptySpace, "cannot remove chip from empty or corner space")
+	}
+	if g.sameSide(space.OccupiedBy, playerID) {
+		return reject(CodeOwnChip, "cannot remove your own or a teammate's chip with a Jack")
+	}
+	// Locking covers every chip of a completed sequence, whatever the sequence length.
+	if space.IsLocked {
+		return reject(CodeLockedChip, "cannot remove chip from a locked sequence")
+	}
+	return nil
+}
+
+// checkOpen validates that a chip may be placed on the space at pos.
+func (g *Game) checkOpen(pos Position) error {
+	space := g.Board[pos.X][pos.Y]
+	if space.IsCorner {
+		return reject(CodeCornerSpace, "cannot place a chip on corner (%d,%d)", pos.X, pos.Y)
+	}
+	if space.OccupiedBy != "" {
+		return reject(CodeSpaceOccupied, "space (%d,%d) is already occupied by %s", pos.X, pos.Y, space.OccupiedBy)
+	}
+	return nil
+}
+
+// advanceTurn passes the turn to the next player holding cards, ending the game
+// as a draw when the draw pile exhaustion policy says so.
+func (g *Game) advanceTurn() {
+	if g.GamePhase != "InProgress" {
+		return
+	}
+	if len(g.DrawPile) == 0 && g.Rules.DrawPileEmpty == ExhaustionEndGame {
+		g.finish("")
+		g.logf("Game %s ended in a draw: draw pile exhausted", g.ID)
+		return
+	}
+	for i := 1; i <= len(g.PlayerOrder); i++ {
+		next := (g.CurrentTurnIndex + i) % len(g.PlayerOrder)
+		if len(g.Players[g.PlayerOrder[next]].Hand) > 0 {
+			g.CurrentTurnIndex = next
+			g.DeadCardUsed = false
+			return
+		}
+	}
+	g.finish("")
+	g.logf("Game %s ended in a draw: no player has cards left", g.ID)
+}
+
+// finish ends the game, won by winner or drawn when winner is "".
+func (g *Game) finish(winner string) {
+	g.GamePhase = "Finished"
+	g.Winner = winner
+	g.FinishedAt = time.Now()
+}
+
+// PlayAction handles a player's move
+func (g *Game) PlayAction(playerID string, action PlayerAction) error {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.GamePhase != "InProgress" {
+		return reject(CodeGameNotInProgress, "game is not in progress")
+	}
+	if len(g.PlayerOrder) == 0 || g.CurrentTurnIndex >= len(g.PlayerOrder) || g.PlayerOrder[g.CurrentTurnIndex] != playerID {
+		return reject(CodeNotYourTurn, "it's not player %s's turn", playerID)
+	}
+
+	player, ok := g.Players[playerID]
+	if !ok {
+		return reject(CodePlayerNotFound, "player %s not found", playerID)
+	}
+
+	playedCard, hasCard := player.GetCardFromHand(action.CardID)
+	if !hasCard {
+		return reject(CodeCardNotInHand, "player %s does not have card %s", playerID, action.CardID)
+	}
+
+	if !onBoard(action.BoardPos) {
+		return reject(CodeInvalidPosition, "invalid board position")
+	}
+	targetSpace := &g.Board[action.BoardPos.X][action.BoardPos.Y]
+
+	moveKind, err := g.Rules.Jacks.ResolveMove(playedCard, action.MoveKind)
+	if err != nil {
+		return err
+	}
+
+	// sequenceCheck is where a chip landed and whose chip it is, if any.
+	var sequenceCheck *Position
+	sequenceOwner := playerID
+
+	switch moveKind {
+	case MoveRemove:
+		if err := g.checkRemovable(playerID, action.BoardPos); err != nil {
+			return err
+		}
+		g.logf("Player %s uses Jack %s to remove chip at (%d,%d) by %s", player.Name, playedCard.ToEmojiString(), action.BoardPos.X, action.BoardPos.Y, targetSpace.OccupiedBy)
+		targetSpace.OccupiedBy = ""
+
+	case MoveSwap:
+		if action.TargetPos == nil || !onBoard(*action.TargetPos) {
+			return reject(CodeInvalidSwap, "a swap needs a valid target position")
+		}
+		if err := g.checkRemovable(playerID, action.BoardPos); err != nil {
+			return err
+		}
+		if err := g.checkOpen(*action.TargetPos); err != nil {
+			return err
+		}
+		destSpace := &g.Board[action.TargetPos.X][action.TargetPos.Y]
+		if targetSpace.Card == nil || destSpace.Card == nil || targetSpace.Card.ID != destSpace.Card.ID {
+			return reject(CodeInvalidSwap, "a swapped chip must move to another space showing the same card")
+		}
+		g.logf("Player %s uses Jack %s to move chip by %s from (%d,%d) to (%d,%d)", player.Name, playedCard.ToEmojiString(), targetSpace.OccupiedBy,
+			action.BoardPos.X, action.BoardPos.Y, action.TargetPos.X, action.TargetPos.Y)
+		sequenceOwner = targetSpace.OccupiedBy
+		destSpace.OccupiedBy = targetSpace.OccupiedBy
+		targetSpace.OccupiedBy = ""
+		sequenceCheck = action.TargetPos
+
+	default: // MovePlace, MoveWild
+		if err := g.checkOpen(action.BoardPos); err != nil {
+			return err
+		}
+		if moveKind == MovePlace {
+			if targetSpace.Card == nil {
+				return reject(CodeCardMismatch, "board space (%d,%d) has no card defined, cannot play %s", action.BoardPos.X, action.BoardPos.Y, playedCard.ToEmojiString())
+			}
+			if targetSpace.Card.ID != playedCard.ID {
+				return reject(CodeCardMismatch, "card %s (%s) does not match board space (%d,%d) which is %s (expected card ID: %s)",
+					playedCard.ToEmojiString(), playedCard.ID, action.BoardPos.X, action.BoardPos.Y, targetSpace.DisplayValue, targetSpace.Card.ID)
+			}
+		}
+		g.logf("Player %s plays %s to place chip at (%d,%d)", player.Name, playedCard.ToEmojiString(), action.BoardPos.X, action.BoardPos.Y)
+		targetSpace.OccupiedBy = playerID
+		sequenceCheck = &action.BoardPos
+	}
+
+	if g.PendingDraw != "" && g.PendingDraw != playerID {
+		g.forfeitPendingDraw()
+	}
+	g.MoveCount++
+	if moveKind != MovePlace {
+		player.JacksUsed++
+	}
+	g.DiscardPile = append(g.DiscardPile, *playedCard)
+	player.removeCardFromHand(playedCard.ID)
+	if g.Rules.ExplicitDraw {
+		g.PendingDraw = playerID
+	} else if _, err := g.drawCard(playerID); err != nil {
+		g.logf("Player %s could not draw card: %v", playerID, err)
+	}
+
+	if sequenceCheck != nil {
+		owner := g.Players[sequenceOwner]
+		newSequencesFormed := g.checkForSequencesAfterPlay(sequenceOwner, sequenceCheck.X, sequenceCheck.Y)
+		if owner != nil && newSequencesFormed > 0 {
+			sequences := g.countUniqueSequences(sequenceOwner)
+			for _, p := range g.Players {
+				if p.Side() == owner.Side() {
+					p.Sequences = sequences // Teammates share their sequences
+				}
+			}
+			g.logf("Player %s formed %d new sequence(s)! Total sequences: %d", owner.Name, newSequencesFormed, owner.Sequences)
+			if owner.Sequences >= g.NumSequencesToWin {
+				g.finish(sequenceOwner)
+				if owner.Team != "" {
+					g.logf("Game Over! Player %s wins for %s!", owner.Name, owner.Team)
+				} else {
+					g.logf("Game Over! Player %s wins!", owner.Name)
+				}
+			}
+		}
+	}
+
+	g.advanceTurn()
+	return nil
+}
+
+// forfeitPendingDraw applies the loss-of-draw rule to a player who did not draw in time.
+func (g *Game) forfeitPendingDraw() {
+	if p, ok := g.Players[g.PendingDraw]; ok {
+		p.HandLimit--
+		g.logf("Player %s did not draw before the next play and keeps a %d-card hand", p.Name, p.HandLimit)
+	}
+	g.PendingDraw = ""
+}
+
+// HandleDraw handles an explicit DRAW_CARD by the player who just played.
+func (g *Game) HandleDraw(playerID string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.GamePhase != "InProgress" {
+		return reject(CodeGameNotInProgress, "game is not in progress")
+	}
+	if !g.Rules.ExplicitDraw {
+		return reject(CodeNoDrawPending, "cards are drawn automatically in this game")
+	}
+	if g.PendingDraw != playerID {
+		return reject(CodeNoDrawPending, "player %s has no card to draw", playerID)
+	}
+
+	g.PendingDraw = ""
+	if _, err := g.drawCard(playerID); err != nil {
+		return fmt.Errorf("could not draw: %w", err)
+	}
+	return nil
+}
+
+// AutoDraw draws on behalf of a player who still owes a draw after moveCount moves,
+// reporting whether a draw happened. Timers call it once the draw timeout expires.
+func (g *Game) AutoDraw(playerID string, moveCount int) bool {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.GamePhase != "InProgress" || g.PendingDraw != playerID || g.MoveCount != moveCount {
+		return false
+	}
+	g.PendingDraw = ""
+	if _, err := g.drawCard(playerID); err != nil {
+		g.logf("Auto-draw for player %s failed: %v", playerID, err)
+	}
+	return true
+}
+
+// HandleDeadCard allows a player to discard a dead card and draw a new one.
+func (g *Game) HandleDeadCard(playerID string, cardID string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.GamePhase != "InProgress" {
+		return reject(CodeGameNotInProgress, "game is not in progress")
+	}
+	if len(g.PlayerOrder) == 0 || g.CurrentTurnIndex >= len(g.PlayerOrder) || g.PlayerOrder[g.CurrentTurnIndex] != playerID {
+		return reject(CodeNotYourTurn, "it's not player %s's turn", playerID)
+	}
+
+	player, ok := g.Players[playerID]
+	if !ok {
+		return reject(CodePlayerNotFound, "player %s not found", playerID)
+	}
+	if g.DeadCardUsed && g.Rules.DeadCards == DeadCardOncePerTurn {
+		return reject(CodeDeadCardUsed, "only one dead card may be turned in per turn")
+	}
+
+	deadCardInHand, hasCard := player.GetCardFromHand(cardID)
+	if !hasCard {
+		return reject(CodeCardNotInHand, "player %s does not have card %s", playerID, cardID)
+	}
+	if g.Rules.Jacks.HasPower(deadCardInHand) {
+		return reject(CodeCardNotDead, "jacks cannot be dead cards")
+	}
+
+	isActuallyDead := true
+	spotsForThisCard := 0
+	for r := 0; r < BoardSize; r++ {
+		for c := 0; c < BoardSize; c++ {
+			space := g.Board[r][c]
+			if space.Card != nil && space.Card.ID == deadCardInHand.ID {
+				spotsForThisCard++
+				if space.OccupiedBy == "" || space.OccupiedBy == "CORNER" {
+					isActuallyDead = false
+					break
+				}
+			}
+		}
+		if !isActuallyDead {
+			break
+		}
+	}
+
+	if spotsForThisCard == 0 && deadCardInHand.Rank != Jack {
+		g.logf("Error: Card %s (%s) declared dead by %s, but no spots found on board for this card ID. Check board layout.",
+			deadCardInHand.ToEmojiString(), deadCardInHand.ID, player.Name)
+		return reject(CodeCardNotDead, "card %s not found on board layout, cannot be dead", deadCardInHand.ToEmojiString())
+	}
+	if !isActuallyDead {
+		return reject(CodeCardNotDead, "card %s (%s) is not dead, an available spot exists", deadCardInHand.ToEmojiString(), deadCardInHand.ID)
+	}
+
+	g.logf("Player %s declares %s (%s) as a dead card.", player.Name, deadCardInHand.ToEmojiString(), deadCardInHand.ID)
+	g.DiscardPile = append(g.DiscardPile, *deadCardInHand)
+	player.removeCardFromHand(deadCardInHand.ID)
+	player.DeadCards++
+
+	if _, err := g.drawCard(playerID); err != nil {
+		g.logf("Player %s could not draw replacement card: %v", playerID, err)
+	}
+
+	// Official rules: the player still plays a card after turning in a dead one.
+	g.DeadCardUsed = true
+	if g.Rules.DeadCardEndsTurn || len(player.Hand) == 0 {
+		g.advanceTurn()
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package game
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNewGameLimits(t *testing.T) {
+	twoPlayers, err := RulesPreset("Official")
+	if err != nil {
+		t.Fatal(err)
+	}
+	twoPlayers.HandSizes = []HandSize{{MaxPlayers: 2, Cards: 7}}
+
+	tests := []struct {
+		name           string
+		rules          string
+		custom         *Rules
+		maxPlayers     int
+		sequencesToWin int
+		wantPlayers    int
+		wantSequences  int
+	}{
+		{name: "defaults", rules: "Official", wantPlayers: DefaultMaxPlayers, wantSequences: DefaultSequencesToWin},
+		{name: "override within range", rules: "Official", maxPlayers: 6, sequencesToWin: 3, wantPlayers: 6, wantSequences: 3},
+		{name: "override clamped", rules: "Official", sequencesToWin: 99, wantPlayers: DefaultMaxPlayers, wantSequences: MaxSequencesToWin},
+		{name: "too many players", rules: "Official", maxPlayers: 50, wantPlayers: 12, wantSequences: DefaultSequencesToWin},
+		{name: "default above the hand size table", custom: &twoPlayers, wantPlayers: 2, wantSequences: DefaultSequencesToWin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := twoPlayers
+			if tt.custom == nil {
+				var err error
+				if rules, err = RulesPreset(tt.rules); err != nil {
+					t.Fatal(err)
+				}
+			}
+			g := NewGame("host", "Host", tt.maxPlayers, tt.sequencesToWin, rules)
+			if g.MaxPlayers != tt.wantPlayers {
+				t.Errorf("MaxPlayers = %d, want %d", g.MaxPlayers, tt.wantPlayers)
+			}
+			if g.NumSequencesToWin != tt.wantSequences || g.Rules.SequencesToWin != tt.wantSequences {
+				t.Errorf("sequences to win = %d (rules %d), want %d", g.NumSequencesToWin, g.Rules.SequencesToWin, tt.wantSequences)
+			}
+			if err := g.Rules.Validate(); err != nil {
+				t.Errorf("game rules do not validate: %v", err)
+			}
+		})
+	}
+}
+
+func TestAddPlayerRejoinsOnlyByID(t *testing.T) {
+	rules, err := RulesPreset("Official")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGame("a", "Alice", 3, 0, rules)
+	g.quiet = true
+	alice, err := g.AddPlayer("a", "Alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	impostor, err := g.AddPlayer("x", "Alice", nil)
+	if err != nil {
+		t.Fatalf("a namesake could not join the lobby: %v", err)
+	}
+	if impostor == alice || len(g.Players) != 2 {
+		t.Fatal("a namesake took over a seat in the lobby")
+	}
+	if err := g.StartGame("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	alice.IsConnected = false
+	if _, err := g.AddPlayer("y", "Alice", nil); CodeOf(err) != CodeGameNotJoinable {
+		t.Errorf("a namesake joining a started game: error %v, want %s", err, CodeGameNotJoinable)
+	}
+	if alice.IsConnected || len(g.Players) != 2 {
+		t.Error("a namesake took over a seat in a started game")
+	}
+	back, err := g.AddPlayer("a", "", nil)
+	if err != nil || back != alice || !alice.IsConnected {
+		t.Errorf("rejoining by player ID = %v, %v; want the original seat", back, err)
+	}
+}
+
+// teamGame seats a, b, c and d in a game of two teams, in that order.
+func teamGame(t *testing.T) *Game {
+	t.Helper()
+	rules, err := RulesPreset("Official")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGame("a", "A", 4, 0, rules)
+	g.quiet = true
+	if err := g.SetTeams(2); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c", "d"} {
+		if _, err := g.AddPlayer(id, id, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return g
+}
+
+func TestTeamSeating(t *testing.T) {
+	g := teamGame(t)
+	for id, want := range map[string]string{"a": "team1", "b": "team2", "c": "team1", "d": "team2"} {
+		if got := g.Players[id].Team; got != want {
+			t.Errorf("player %s is on %q, want %q", id, got, want)
+		}
+	}
+	a, b, c, d := g.Players["a"], g.Players["b"], g.Players["c"], g.Players["d"]
+	if a.ChipColor != c.ChipColor || b.ChipColor != d.ChipColor || a.ChipColor == b.ChipColor {
+		t.Errorf("chip colours %s, %s, %s, %s: want one per team", a.ChipColor, b.ChipColor, c.ChipColor, d.ChipColor)
+	}
+	if !g.PreferChipColor("c", "purple") || a.ChipColor != "purple" {
+		t.Errorf("a team's colour change left a teammate on %s", a.ChipColor)
+	}
+	if g.PreferChipColor("b", "purple") {
+		t.Error("a team took another team's colour")
+	}
+	if err := g.SetTeams(3); err == nil {
+		t.Error("teams changed after players joined")
+	}
+
+	rules, _ := RulesPreset("Official")
+	uneven := NewGame("a", "A", 4, 0, rules)
+	uneven.quiet = true
+	if err := uneven.SetTeams(2); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := uneven.AddPlayer(id, id, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := uneven.StartGame("a"); CodeOf(err) != CodeUnevenTeams {
+		t.Errorf("starting with teams of 2 and 1: error %v, want %s", err, CodeUnevenTeams)
+	}
+	for _, tt := range []struct{ maxPlayers, teams int }{{4, 4}, {4, 1}, {3, 2}, {4, 3}} {
+		if err := NewGame("a", "A", tt.maxPlayers, 0, rules).SetTeams(tt.teams); err == nil {
+			t.Errorf("%d teams of %d players accepted", tt.teams, tt.maxPlayers)
+		}
+	}
+}
+
+func TestReserveTeam(t *testing.T) {
+	rules, err := RulesPreset("Official")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGame("a", "A", 4, 0, rules)
+	g.quiet = true
+	if err := g.SetTeams(2); err != nil {
+		t.Fatal(err)
+	}
+	for id, team := range map[string]string{"a": "team1", "b": "team1", "d": "team2"} {
+		if err := g.ReserveTeam(id, team); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.ReserveTeam("e", "team3"); err == nil {
+		t.Error("reserved a seat on a team the game does not have")
+	}
+	for _, id := range []string{"a", "b", "c", "d"} {
+		if _, err := g.AddPlayer(id, id, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for id, want := range map[string]string{"a": "team1", "b": "team1", "c": "team2", "d": "team2"} {
+		if got := g.Players[id].Team; got != want {
+			t.Errorf("player %s is on %q, want %q", id, got, want)
+		}
+	}
+	if err := g.StartGame("a"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "c", "b", "d"}; !slices.Equal(g.PlayerOrder, want) {
+		t.Errorf("turn order %v, want %v, alternating between the teams", g.PlayerOrder, want)
+	}
+	if err := g.ReserveTeam("c", "team1"); CodeOf(err) != CodeNotInLobby {
+		t.Errorf("reserving a team after the start: error %v, want %s", err, CodeNotInLobby)
+	}
+}
+
+func TestTeamSequence(t *testing.T) {
+	g := teamGame(t)
+	if err := g.StartGame("a"); err != nil {
+		t.Fatal(err)
+	}
+	g.NumSequencesToWin = 1
+	// a and c fill the top row after the free corner but one space; b holds the row below
+	g.Board[0][1].OccupiedBy = "a"
+	g.Board[0][2].OccupiedBy = "c"
+	g.Board[0][3].OccupiedBy = "c"
+	g.Board[1][4].OccupiedBy = "b"
+
+	if err := g.checkRemovable("a", Position{X: 0, Y: 2}); CodeOf(err) != CodeOwnChip {
+		t.Errorf("removing a teammate's chip: error %v, want %s", err, CodeOwnChip)
+	}
+	if err := g.checkRemovable("a", Position{X: 1, Y: 4}); err != nil {
+		t.Errorf("removing an opponent's chip: %v", err)
+	}
+	listed := false
+	for _, threat := range g.threats().Threats {
+		listed = listed || threat.PlayerID == "a"
+		if threat.PlayerID == "c" {
+			t.Errorf("team1's threat listed under c as well as a: %+v", threat)
+		}
+	}
+	if !listed {
+		t.Error("team1's line a chip short of a sequence is not a threat")
+	}
+
+	card, _ := ParseCardID("5S")
+	g.Players["a"].Hand = []Card{*card}
+	if err := g.PlayAction("a", PlayerAction{CardID: card.ID, BoardPos: Position{X: 0, Y: 4}}); err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[string]int{"a": 1, "b": 0, "c": 1, "d": 0} {
+		if got := g.Players[id].Sequences; got != want {
+			t.Errorf("player %s has %d sequences, want %d", id, got, want)
+		}
+	}
+	if g.GamePhase != "Finished" || g.Winner != "a" {
+		t.Errorf("phase %s, winner %q; want a's team to have won", g.GamePhase, g.Winner)
+	}
+}
@@ -0,0 +1,126 @@
+package game
+
+// CurrentPlayerID returns the ID of the player whose turn it is, or "" outside of play.
+func (g *Game) CurrentPlayerID() string {
+	g.Lock()
+	defer g.Unlock()
+	return g.currentPlayerID()
+}
+
+func (g *Game) currentPlayerID() string {
+	if g.GamePhase != "InProgress" || len(g.PlayerOrder) == 0 || g.CurrentTurnIndex >= len(g.PlayerOrder) {
+		return ""
+	}
+	return g.PlayerOrder[g.CurrentTurnIndex]
+}
+
+// LegalMoves lists every play PlayAction would accept from the player right now,
+// one PlayerAction per card, move kind and space. Duplicate cards in hand are listed once.
+func (g *Game) LegalMoves(playerID string) []PlayerAction {
+	g.Lock()
+	defer g.Unlock()
+	return g.legalMoves(playerID)
+}
+
+func (g *Game) legalMoves(playerID string) []PlayerAction {
+	player, ok := g.Players[playerID]
+	if !ok || g.currentPlayerID() != playerID {
+		return nil
+	}
+
+	var moves []PlayerAction
+	seen := make(map[string]bool)
+	for i := range player.Hand {
+		card := &player.Hand[i]
+		if seen[card.ID] {
+			continue
+		}
+		seen[card.ID] = true
+		for _, kind := range g.Rules.Jacks.MovesFor(card) {
+			moves = append(moves, g.movesOfKind(playerID, card, kind)...)
+		}
+	}
+	return moves
+}
+
+// movesOfKind lists the spaces where card can be played as kind.
+func (g *Game) movesOfKind(playerID string, card *Card, kind MoveKind) []PlayerAction {
+	var moves []PlayerAction
+	for x := 0; x < BoardSize; x++ {
+		for y := 0; y < BoardSize; y++ {
+			pos := Position{X: x, Y: y}
+			space := g.Board[x][y]
+			switch kind {
+			case MovePlace:
+				if space.Card != nil && space.Card.ID == card.ID && g.checkOpen(pos) == nil {
+					moves = append(moves, PlayerAction{CardID: card.ID, BoardPos: pos, MoveKind: kind})
+				}
+			case MoveWild:
+				if g.checkOpen(pos) == nil {
+					moves = append(moves, PlayerAction{CardID: card.ID, BoardPos: pos, MoveKind: kind})
+				}
+			case MoveRemove:
+				if g.checkRemovable(playerID, pos) == nil {
+					moves = append(moves, PlayerAction{CardID: card.ID, BoardPos: pos, MoveKind: kind})
+				}
+			case MoveSwap:
+				if g.checkRemovable(playerID, pos) != nil || space.Card == nil {
+					continue
+				}
+				for _, target := range g.spacesFor(space.Card.ID) {
+					if target != pos && g.checkOpen(target) == nil {
+						target := target
+						moves = append(moves, PlayerAction{CardID: card.ID, BoardPos: pos, MoveKind: kind, TargetPos: &target})
+					}
+				}
+			}
+		}
+	}
+	return moves
+}
+
+// spacesFor lists the board spaces showing the card.
+func (g *Game) spacesFor(cardID string) []Position {
+	var spaces []Position
+	for x := 0; x < BoardSize; x++ {
+		for y := 0; y < BoardSize; y++ {
+			if card := g.Board[x][y].Card; card != nil && card.ID == cardID {
+				spaces = append(spaces, Position{X: x, Y: y})
+			}
+		}
+	}
+	return spaces
+}
+
+// DeadCards lists the cards in the player's hand that HandleDeadCard would accept,
+// ignoring whose turn it is.
+func (g *Game) DeadCards(playerID string) []string {
+	g.Lock()
+	defer g.Unlock()
+
+	player, ok := g.Players[playerID]
+	if !ok {
+		return nil
+	}
+	var dead []string
+	seen := make(map[string]bool)
+	for i := range player.Hand {
+		card := &player.Hand[i]
+		if seen[card.ID] || g.Rules.Jacks.HasPower(card) {
+			continue
+		}
+		seen[card.ID] = true
+		spaces := g.spacesFor(card.ID)
+		isDead := len(spaces) > 0
+		for _, pos := range spaces {
+			if g.Board[pos.X][pos.Y].OccupiedBy == "" {
+				isDead = false
+				break
+			}
+		}
+		if isDead {
+			dead = append(dead, card.ID)
+		}
+	}
+	return dead
+}
@@ -0,0 +1,307 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// --- Game Rules & Variant Presets ---
+
+// DeadCardPolicy controls how often a player may declare dead cards.
+type DeadCardPolicy string
+
+const (
+	DeadCardOncePerTurn DeadCardPolicy = "once_per_turn" // One dead card per turn, then play as usual
+	DeadCardUnlimited   DeadCardPolicy = "unlimited"     // Any number of dead cards, then play as usual
+)
+
+// DrawPileExhaustion controls what happens once the draw pile runs out.
+type DrawPileExhaustion string
+
+const (
+	ExhaustionPlayOut   DrawPileExhaustion = "play_out"  // Keep playing with shrinking hands, draw when all hands are empty
+	ExhaustionReshuffle DrawPileExhaustion = "reshuffle" // Shuffle the discard pile into a new draw pile
+	ExhaustionEndGame   DrawPileExhaustion = "end_game"  // The game ends in a draw as soon as the pile is empty
+)
+
+// HandSize maps a player count (up to and including MaxPlayers) to cards dealt per player.
+type HandSize struct {
+	MaxPlayers int `json:"maxPlayers"`
+	Cards      int `json:"cards"`
+}
+
+// MoveKind identifies what a PLAY_ACTION does on the board.
+type MoveKind string
+
+const (
+	MovePlace  MoveKind = "PLACE"  // Place a chip on an open space showing the played card
+	MoveWild   MoveKind = "WILD"   // Jack: place a chip on any open space
+	MoveRemove MoveKind = "REMOVE" // Jack: remove an opponent's chip
+	MoveSwap   MoveKind = "SWAP"   // Jack: move an opponent's chip to another open space showing the same card
+)
+
+// JackRules describes what the Jacks of each suit are allowed to do.
+type JackRules struct {
+	WildSuits   []Suit `json:"wildSuits"`   // Standard: two-eyed Jacks (Hearts, Diamonds)
+	RemoveSuits []Suit `json:"removeSuits"` // Standard: one-eyed Jacks (Clubs, Spades)
+	SwapSuits   []Suit `json:"swapSuits"`
+	OnBoard     bool   `json:"onBoard"` // Jacks are printed on a custom layout and can be placed like any other card
+}
+
+var standardJacks = JackRules{WildSuits: []Suit{Hearts, Diamonds}, RemoveSuits: []Suit{Clubs, Spades}}
+
+func hasSuit(suits []Suit, suit Suit) bool {
+	for _, s := range suits {
+		if s == suit {
+			return true
+		}
+	}
+	return false
+}
+
+// MovesFor lists the move kinds a card may be played as, preferred kind first.
+func (j JackRules) MovesFor(card *Card) []MoveKind {
+	if card.Rank != Jack {
+		return []MoveKind{MovePlace}
+	}
+	var moves []MoveKind
+	if hasSuit(j.WildSuits, card.Suit) {
+		moves = append(moves, MoveWild)
+	}
+	if hasSuit(j.RemoveSuits, card.Suit) {
+		moves = append(moves, MoveRemove)
+	}
+	if hasSuit(j.SwapSuits, card.Suit) {
+		moves = append(moves, MoveSwap)
+	}
+	if j.OnBoard {
+		moves = append(moves, MovePlace)
+	}
+	return moves
+}
+
+// HasPower reports whether the card is a Jack with a special move (and so is never dead).
+func (j JackRules) HasPower(card *Card) bool {
+	for _, move := range j.MovesFor(card) {
+		if move != MovePlace {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveMove returns the move kind for playing the card, defaulting to its preferred kind.
+func (j JackRules) ResolveMove(card *Card, requested MoveKind) (MoveKind, error) {
+	moves := j.MovesFor(card)
+	if len(moves) == 0 {
+		return "", reject(CodeInvalidMove, "%s has no use under these rules", card.ToEmojiString())
+	}
+	if requested == "" {
+		return moves[0], nil
+	}
+	for _, move := range moves {
+		if move == requested {
+			return move, nil
+		}
+	}
+	return "", reject(CodeInvalidMove, "%s cannot be played as %s", card.ToEmojiString(), requested)
+}
+
+// Rules is the full rule configuration of a game, chosen at CREATE_GAME.
+type Rules struct {
+	Preset           string             `json:"preset"`
+	HandSizes        []HandSize         `json:"handSizes"` // Sorted by MaxPlayers ascending
+	NumDecks         int                `json:"numDecks"`
+	Jacks            JackRules          `json:"jacks"`
+	CornersFree      bool               `json:"cornersFree"` // Corners count as a chip for every player
+	DeadCards        DeadCardPolicy     `json:"deadCards"`
+	DeadCardEndsTurn bool               `json:"deadCardEndsTurn"` // Legacy: declaring a dead card ends the turn
+	DrawPileEmpty    DrawPileExhaustion `json:"drawPileEmpty"`
+	// ExplicitDraw requires a DRAW_CARD after each play. A player who has not drawn
+	// by the time the next player plays loses that card for the rest of the game.
+	ExplicitDraw       bool `json:"explicitDraw"`
+	DrawTimeoutSeconds int  `json:"drawTimeoutSeconds"` // Auto-draw after this long; 0 never auto-draws
+	SequenceLength     int  `json:"sequenceLength"`
+	SequencesToWin     int  `json:"sequencesToWin"`
+	Hints              bool `json:"hints"` // Players may ask for hints and move analysis; off for competitive play
+	// Layout is an optional custom board of card IDs (and "FREE" corners); nil uses the standard board.
+	Layout [][]string `json:"layout,omitempty"`
+}
+
+var standardHandSizes = []HandSize{
+	{MaxPlayers: 2, Cards: 7},
+	{MaxPlayers: 4, Cards: 6},
+	{MaxPlayers: 6, Cards: 5},
+	{MaxPlayers: 9, Cards: 4},
+	{MaxPlayers: 12, Cards: 3},
+}
+
+// rulePresets holds the named variants selectable in the lobby.
+var rulePresets = map[string]Rules{
+	"Official": {
+		Preset:         "Official",
+		HandSizes:      standardHandSizes,
+		NumDecks:       NumDecks,
+		Jacks:          standardJacks,
+		CornersFree:    true,
+		DeadCards:      DeadCardOncePerTurn,
+		DrawPileEmpty:  ExhaustionPlayOut,
+		SequenceLength: 5,
+		SequencesToWin: DefaultSequencesToWin,
+		Hints:          true,
+	},
+	"Kids": {
+		Preset:         "Kids",
+		HandSizes:      standardHandSizes,
+		NumDecks:       NumDecks,
+		Jacks:          JackRules{WildSuits: []Suit{Hearts, Diamonds}},
+		CornersFree:    true,
+		DeadCards:      DeadCardUnlimited,
+		DrawPileEmpty:  ExhaustionReshuffle,
+		SequenceLength: 4,
+		SequencesToWin: 1,
+		Hints:          true,
+	},
+	"Tournament": {
+		Preset:             "Tournament",
+		HandSizes:          standardHandSizes,
+		NumDecks:           NumDecks,
+		Jacks:              standardJacks,
+		CornersFree:        true,
+		DeadCards:          DeadCardOncePerTurn,
+		DrawPileEmpty:      ExhaustionPlayOut,
+		ExplicitDraw:       true,
+		DrawTimeoutSeconds: 15,
+		SequenceLength:     5,
+		SequencesToWin:     DefaultSequencesToWin,
+	},
+	"Speed": {
+		Preset: "Speed",
+		HandSizes: []HandSize{
+			{MaxPlayers: 2, Cards: 5},
+			{MaxPlayers: 4, Cards: 4},
+			{MaxPlayers: 12, Cards: 3},
+		},
+		NumDecks:       NumDecks,
+		Jacks:          standardJacks,
+		CornersFree:    true,
+		DeadCards:      DeadCardUnlimited,
+		DrawPileEmpty:  ExhaustionEndGame,
+		SequenceLength: 5,
+		SequencesToWin: 1,
+		Hints:          true,
+	},
+}
+
+// DefaultRulesPreset is used when CREATE_GAME does not name a preset.
+const DefaultRulesPreset = "Official"
+
+// RulesPreset returns a copy of the named preset (case-insensitive).
+func RulesPreset(name string) (Rules, error) {
+	if name == "" {
+		name = DefaultRulesPreset
+	}
+	for presetName, rules := range rulePresets {
+		if strings.EqualFold(presetName, name) {
+			rules.HandSizes = append([]HandSize(nil), rules.HandSizes...)
+			return rules, nil
+		}
+	}
+	return Rules{}, fmt.Errorf("unknown rules preset %q (available: %s)", name, strings.Join(RulesPresetNames(), ", "))
+}
+
+// RulesPresetNames lists the available preset names in a stable order.
+func RulesPresetNames() []string {
+	names := make([]string, 0, len(rulePresets))
+	for name := range rulePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks that a rules configuration is playable on the standard board.
+func (r *Rules) Validate() error {
+	if len(r.HandSizes) == 0 {
+		return fmt.Errorf("rules must define at least one hand size")
+	}
+	sort.Slice(r.HandSizes, func(i, j int) bool { return r.HandSizes[i].MaxPlayers < r.HandSizes[j].MaxPlayers })
+	for i, hs := range r.HandSizes {
+		if hs.Cards < 1 || hs.Cards > 10 {
+			return fmt.Errorf("hand size for up to %d players must be between 1 and 10, got %d", hs.MaxPlayers, hs.Cards)
+		}
+		if hs.MaxPlayers < 2 || (i > 0 && hs.MaxPlayers == r.HandSizes[i-1].MaxPlayers) {
+			return fmt.Errorf("invalid or duplicate hand size player count %d", hs.MaxPlayers)
+		}
+	}
+	if r.NumDecks < 1 || r.NumDecks > 4 {
+		return fmt.Errorf("number of decks must be between 1 and 4, got %d", r.NumDecks)
+	}
+	switch r.DeadCards {
+	case DeadCardOncePerTurn, DeadCardUnlimited:
+	default:
+		return fmt.Errorf("unknown dead card policy %q", r.DeadCards)
+	}
+	switch r.DrawPileEmpty {
+	case ExhaustionPlayOut, ExhaustionReshuffle, ExhaustionEndGame:
+	default:
+		return fmt.Errorf("unknown draw pile exhaustion policy %q", r.DrawPileEmpty)
+	}
+	if r.DrawTimeoutSeconds < 0 || r.DrawTimeoutSeconds > 300 {
+		return fmt.Errorf("draw timeout must be between 0 and 300 seconds, got %d", r.DrawTimeoutSeconds)
+	}
+	if r.SequenceLength < 3 || r.SequenceLength > BoardSize {
+		return fmt.Errorf("sequence length must be between 3 and %d, got %d", BoardSize, r.SequenceLength)
+	}
+	if r.SequencesToWin < 1 || r.SequencesToWin > MaxSequencesToWin {
+		return fmt.Errorf("sequences to win must be between 1 and %d, got %d", MaxSequencesToWin, r.SequencesToWin)
+	}
+	if r.Layout != nil {
+		if err := r.validateLayout(); err != nil {
+			return fmt.Errorf("invalid board layout: %v", err)
+		}
+	}
+	return nil
+}
+
+// validateLayout checks a custom board: BoardSize rows of BoardSize parsable card IDs,
+// with Jacks only when the rules put them on the board.
+func (r *Rules) validateLayout() error {
+	if len(r.Layout) != BoardSize {
+		return fmt.Errorf("expected %d rows, got %d", BoardSize, len(r.Layout))
+	}
+	for row, ids := range r.Layout {
+		if len(ids) != BoardSize {
+			return fmt.Errorf("row %d: expected %d spaces, got %d", row, BoardSize, len(ids))
+		}
+		for col, id := range ids {
+			if id == "FREE" {
+				continue
+			}
+			card, err := ParseCardID(id)
+			if err != nil {
+				return fmt.Errorf("space (%d,%d): %v", row, col, err)
+			}
+			if card.Rank == Jack && !r.Jacks.OnBoard {
+				return fmt.Errorf("space (%d,%d): Jacks on the board require the onBoard Jack rule", row, col)
+			}
+		}
+	}
+	return nil
+}
+
+// MaxSupportedPlayers is the largest player count the hand size table covers.
+func (r *Rules) MaxSupportedPlayers() int {
+	return r.HandSizes[len(r.HandSizes)-1].MaxPlayers
+}
+
+// HandSizeFor returns the number of cards dealt to each player for the given player count.
+func (r *Rules) HandSizeFor(numPlayers int) int {
+	for _, hs := range r.HandSizes {
+		if numPlayers <= hs.MaxPlayers {
+			return hs.Cards
+		}
+	}
+	return r.HandSizes[len(r.HandSizes)-1].Cards
+}
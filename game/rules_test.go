@@ -0,0 +1,354 @@
+package game
+
+import "testing"
+
+// startedGame starts a two-player game with the rules, where it is "a"'s turn
+// and "a" holds only the given card.
+func startedGame(t *testing.T, rules Rules, cardID string) *Game {
+	t.Helper()
+	g := NewGame("a", "A", 2, 0, rules)
+	g.quiet = true
+	for _, id := range []string{"a", "b"} {
+		if _, err := g.AddPlayer(id, id, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.StartGame("a"); err != nil {
+		t.Fatal(err)
+	}
+	card, err := ParseCardID(cardID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Players["a"].Hand = []Card{*card}
+	return g
+}
+
+// standardLayout is the standard board as a custom layout.
+func standardLayout() [][]string {
+	layout := make([][]string, BoardSize)
+	for r := range layout {
+		layout[r] = append([]string(nil), boardCardDistribution[r][:]...)
+	}
+	return layout
+}
+
+func TestJackPowers(t *testing.T) {
+	official, err := RulesPreset("Official")
+	if err != nil {
+		t.Fatal(err)
+	}
+	withSwap := official
+	withSwap.Jacks = JackRules{WildSuits: []Suit{Hearts}, RemoveSuits: []Suit{Spades}, SwapSuits: []Suit{Clubs, Diamonds}}
+	powerless := official
+	powerless.Jacks = JackRules{}
+	onBoard := official
+	onBoard.Jacks = JackRules{OnBoard: true}
+	onBoard.Layout = standardLayout()
+	onBoard.Layout[1][1] = "JH" // In place of 7C
+
+	pos := func(x, y int) *Position { return &Position{X: x, Y: y} }
+	tests := []struct {
+		name     string
+		rules    Rules
+		card     string
+		action   PlayerAction
+		opponent *Position // An opponent's chip
+		locked   bool      // The opponent's chip is part of a sequence
+		own      *Position // A chip of the player's
+		wantCode ErrorCode
+		wantChip *Position // Where a chip must be afterwards, and whose
+		wantBy   string
+		wantGone *Position // Where no chip may be afterwards
+	}{
+		{name: "two-eyed Jack is wild", rules: official, card: "JH", action: PlayerAction{BoardPos: *pos(4, 4)}, wantChip: pos(4, 4), wantBy: "a"},
+		{name: "wild needs an open space", rules: official, card: "JD", action: PlayerAction{BoardPos: *pos(4, 4)}, opponent: pos(4, 4), wantCode: CodeSpaceOccupied},
+		{name: "wild cannot cover a corner", rules: official, card: "JD", action: PlayerAction{BoardPos: *pos(0, 0)}, wantCode: CodeCornerSpace},
+		{name: "one-eyed Jack removes", rules: official, card: "JS", action: PlayerAction{BoardPos: *pos(4, 4)}, opponent: pos(4, 4), wantGone: pos(4, 4)},
+		{name: "remove needs a chip", rules: official, card: "JC", action: PlayerAction{BoardPos: *pos(4, 4)}, wantCode: CodeEmptySpace},
+		{name: "remove spares own chips", rules: official, card: "JC", action: PlayerAction{BoardPos: *pos(4, 4)}, own: pos(4, 4), wantCode: CodeOwnChip},
+		{name: "remove spares sequences", rules: official, card: "JS", action: PlayerAction{BoardPos: *pos(4, 4)}, opponent: pos(4, 4), locked: true, wantCode: CodeLockedChip},
+		{name: "Jack played as a power it lacks", rules: official, card: "JH", action: PlayerAction{BoardPos: *pos(4, 4), MoveKind: MoveRemove}, opponent: pos(4, 4), wantCode: CodeInvalidMove},
+		{name: "swap moves to the twin space", rules: withSwap, card: "JC", action: PlayerAction{BoardPos: *pos(1, 1), TargetPos: pos(7, 8)}, opponent: pos(1, 1), wantChip: pos(7, 8), wantBy: "b", wantGone: pos(1, 1)},
+		{name: "swap needs a target", rules: withSwap, card: "JD", action: PlayerAction{BoardPos: *pos(1, 1)}, opponent: pos(1, 1), wantCode: CodeInvalidSwap},
+		{name: "swap needs the same card", rules: withSwap, card: "JC", action: PlayerAction{BoardPos: *pos(1, 1), TargetPos: pos(1, 2)}, opponent: pos(1, 1), wantCode: CodeInvalidSwap},
+		{name: "swap needs an open target", rules: withSwap, card: "JC", action: PlayerAction{BoardPos: *pos(1, 1), TargetPos: pos(7, 8)}, opponent: pos(1, 1), own: pos(7, 8), wantCode: CodeSpaceOccupied},
+		{name: "swap spares own chips", rules: withSwap, card: "JC", action: PlayerAction{BoardPos: *pos(1, 1), TargetPos: pos(7, 8)}, own: pos(1, 1), wantCode: CodeOwnChip},
+		{name: "Jack without powers", rules: powerless, card: "JS", action: PlayerAction{BoardPos: *pos(4, 4)}, wantCode: CodeInvalidMove},
+		{name: "Jack on the board is placed", rules: onBoard, card: "JH", action: PlayerAction{BoardPos: *pos(1, 1)}, wantChip: pos(1, 1), wantBy: "a"},
+		{name: "Jack on the board matches its space", rules: onBoard, card: "JH", action: PlayerAction{BoardPos: *pos(1, 2)}, wantCode: CodeCardMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := startedGame(t, tt.rules, tt.card)
+			if tt.opponent != nil {
+				g.Board[tt.opponent.X][tt.opponent.Y].OccupiedBy = "b"
+				g.Board[tt.opponent.X][tt.opponent.Y].IsLocked = tt.locked
+			}
+			if tt.own != nil {
+				g.Board[tt.own.X][tt.own.Y].OccupiedBy = "a"
+			}
+			tt.action.CardID = g.Players["a"].Hand[0].ID
+
+			err := g.PlayAction("a", tt.action)
+			if tt.wantCode != "" {
+				if CodeOf(err) != tt.wantCode {
+					t.Fatalf("PlayAction error = %v (%s), want %s", err, CodeOf(err), tt.wantCode)
+				}
+				if len(g.Players["a"].Hand) != 1 || g.MoveCount != 0 {
+					t.Errorf("a rejected play changed the game: hand %v, move count %d", g.Players["a"].Hand, g.MoveCount)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PlayAction: %v", err)
+			}
+			if tt.wantChip != nil {
+				if by := g.Board[tt.wantChip.X][tt.wantChip.Y].OccupiedBy; by != tt.wantBy {
+					t.Errorf("chip at %v is %q, want %q", *tt.wantChip, by, tt.wantBy)
+				}
+			}
+			if tt.wantGone != nil {
+				if by := g.Board[tt.wantGone.X][tt.wantGone.Y].OccupiedBy; by != "" {
+					t.Errorf("chip at %v is %q, want none", *tt.wantGone, by)
+				}
+			}
+			played := g.DiscardPile[len(g.DiscardPile)-1]
+			if used := g.Players["a"].JacksUsed; tt.rules.Jacks.HasPower(&played) != (used == 1) {
+				t.Errorf("JacksUsed = %d after playing %s", used, played.ID)
+			}
+		})
+	}
+}
+
+func TestValidateLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    func(r *Rules)
+		wantErr bool
+	}{
+		{name: "standard layout", edit: func(r *Rules) {}},
+		{name: "Jacks with the onBoard rule", edit: func(r *Rules) { r.Jacks.OnBoard = true; r.Layout[1][1] = "JH" }},
+		{name: "too few rows", edit: func(r *Rules) { r.Layout = r.Layout[:BoardSize-1] }, wantErr: true},
+		{name: "short row", edit: func(r *Rules) { r.Layout[3] = r.Layout[3][:BoardSize-1] }, wantErr: true},
+		{name: "long row", edit: func(r *Rules) { r.Layout[3] = append(r.Layout[3], "2S") }, wantErr: true},
+		{name: "unknown card", edit: func(r *Rules) { r.Layout[2][2] = "1X" }, wantErr: true},
+		{name: "Jacks without the onBoard rule", edit: func(r *Rules) { r.Layout[1][1] = "JH" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := RulesPreset("Official")
+			if err != nil {
+				t.Fatal(err)
+			}
+			rules.Layout = standardLayout()
+			tt.edit(&rules)
+			if err := rules.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error: %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fillSpaces covers every space showing the card with the player's chips,
+// making the card dead.
+func fillSpaces(g *Game, cardID, playerID string) {
+	for x := 0; x < BoardSize; x++ {
+		for y := 0; y < BoardSize; y++ {
+			if s := &g.Board[x][y]; s.Card != nil && s.Card.ID == cardID {
+				s.OccupiedBy = playerID
+			}
+		}
+	}
+}
+
+func TestDeadCards(t *testing.T) {
+	official, err := RulesPreset("Official")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlimited := official
+	unlimited.DeadCards = DeadCardUnlimited
+	endsTurn := official
+	endsTurn.DeadCardEndsTurn = true
+
+	tests := []struct {
+		name      string
+		rules     Rules
+		hand      []string // "a"'s hand; "2S" and "3S" are dead
+		emptyPile bool     // Nothing left to draw
+		turnIn    []string // Turned in one after another; only the last may fail
+		wantCode  ErrorCode
+		wantTurn  string // Whose turn it is afterwards
+		wantHand  int
+	}{
+		{name: "turn in and still play", rules: official, hand: []string{"2S", "5H"}, turnIn: []string{"2S"}, wantTurn: "a", wantHand: 2},
+		{name: "once per turn", rules: official, hand: []string{"2S", "3S", "5H"}, turnIn: []string{"2S", "3S"}, wantCode: CodeDeadCardUsed, wantTurn: "a", wantHand: 3},
+		{name: "unlimited per turn", rules: unlimited, hand: []string{"2S", "3S", "5H"}, turnIn: []string{"2S", "3S"}, wantTurn: "a", wantHand: 3},
+		{name: "legacy rule ends the turn", rules: endsTurn, hand: []string{"2S", "5H"}, turnIn: []string{"2S"}, wantTurn: "b", wantHand: 2},
+		{name: "empty hand ends the turn", rules: official, hand: []string{"2S"}, emptyPile: true, turnIn: []string{"2S"}, wantTurn: "b", wantHand: 0},
+		{name: "live card is not dead", rules: official, hand: []string{"5H", "2S"}, turnIn: []string{"5H"}, wantCode: CodeCardNotDead, wantTurn: "a", wantHand: 2},
+		{name: "Jacks are never dead", rules: official, hand: []string{"JH", "2S"}, turnIn: []string{"JH"}, wantCode: CodeCardNotDead, wantTurn: "a", wantHand: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := startedGame(t, tt.rules, tt.hand[0])
+			g.Players["a"].Hand = nil
+			for _, id := range tt.hand {
+				card, err := ParseCardID(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				g.Players["a"].Hand = append(g.Players["a"].Hand, *card)
+			}
+			fillSpaces(g, "2S", "b")
+			fillSpaces(g, "3S", "b")
+			if tt.emptyPile {
+				g.DrawPile = nil
+			}
+
+			for i, id := range tt.turnIn {
+				err := g.HandleDeadCard("a", id)
+				if i < len(tt.turnIn)-1 || tt.wantCode == "" {
+					if err != nil {
+						t.Fatalf("HandleDeadCard(%s): %v", id, err)
+					}
+					continue
+				}
+				if CodeOf(err) != tt.wantCode {
+					t.Fatalf("HandleDeadCard(%s) error = %v (%s), want %s", id, err, CodeOf(err), tt.wantCode)
+				}
+			}
+			if turn := g.CurrentPlayerID(); turn != tt.wantTurn {
+				t.Errorf("turn = %q, want %q", turn, tt.wantTurn)
+			}
+			if n := len(g.Players["a"].Hand); n != tt.wantHand {
+				t.Errorf("hand has %d cards, want %d", n, tt.wantHand)
+			}
+		})
+	}
+}
+
+// spaceOf returns the first open space showing the card.
+func spaceOf(t *testing.T, g *Game, cardID string) Position {
+	t.Helper()
+	for x := 0; x < BoardSize; x++ {
+		for y := 0; y < BoardSize; y++ {
+			if s := g.Board[x][y]; s.Card != nil && s.Card.ID == cardID && s.OccupiedBy == "" {
+				return Position{X: x, Y: y}
+			}
+		}
+	}
+	t.Fatalf("no open space shows %s", cardID)
+	return Position{}
+}
+
+func TestExplicitDraw(t *testing.T) {
+	tournament, err := RulesPreset("Tournament")
+	if err != nil {
+		t.Fatal(err)
+	}
+	official, err := RulesPreset("Official")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// bPlays has "b" play a 5H, which is never dead this early.
+	bPlays := func(t *testing.T, g *Game) {
+		t.Helper()
+		card, err := ParseCardID("5H")
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.Players["b"].Hand[0] = *card
+		if err := g.PlayAction("b", PlayerAction{CardID: "5H", BoardPos: spaceOf(t, g, "5H")}); err != nil {
+			t.Fatalf("PlayAction: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		rules       Rules
+		steps       func(t *testing.T, g *Game) error // After "a" has played; returns the error of the step under test
+		wantCode    ErrorCode
+		wantPending string
+		wantLimit   int // "a"'s hand limit afterwards
+		wantHand    int // Cards in "a"'s hand afterwards
+	}{
+		{
+			name:      "draw in time",
+			rules:     tournament,
+			steps:     func(t *testing.T, g *Game) error { return g.HandleDraw("a") },
+			wantLimit: 7, wantHand: 1,
+		},
+		{
+			name:        "next player moves first",
+			rules:       tournament,
+			steps:       func(t *testing.T, g *Game) error { bPlays(t, g); return nil },
+			wantPending: "b", wantLimit: 6, wantHand: 0,
+		},
+		{
+			name:     "draw after losing it",
+			rules:    tournament,
+			steps:    func(t *testing.T, g *Game) error { bPlays(t, g); return g.HandleDraw("a") },
+			wantCode: CodeNoDrawPending, wantPending: "b", wantLimit: 6, wantHand: 0,
+		},
+		{
+			name:     "no draw pending for the other player",
+			rules:    tournament,
+			steps:    func(t *testing.T, g *Game) error { return g.HandleDraw("b") },
+			wantCode: CodeNoDrawPending, wantPending: "a", wantLimit: 7, wantHand: 0,
+		},
+		{
+			name:     "cards drawn automatically",
+			rules:    official,
+			steps:    func(t *testing.T, g *Game) error { return g.HandleDraw("a") },
+			wantCode: CodeNoDrawPending, wantLimit: 7, wantHand: 1,
+		},
+		{
+			name:  "auto-draw on time",
+			rules: tournament,
+			steps: func(t *testing.T, g *Game) error {
+				if !g.AutoDraw("a", g.MoveCount) {
+					t.Error("AutoDraw did not draw")
+				}
+				return nil
+			},
+			wantLimit: 7, wantHand: 1,
+		},
+		{
+			name:  "auto-draw with a stale move count",
+			rules: tournament,
+			steps: func(t *testing.T, g *Game) error {
+				if g.AutoDraw("a", g.MoveCount-1) {
+					t.Error("AutoDraw drew for a stale move count")
+				}
+				return nil
+			},
+			wantPending: "a", wantLimit: 7, wantHand: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := startedGame(t, tt.rules, "2S")
+			if err := g.PlayAction("a", PlayerAction{CardID: "2S", BoardPos: spaceOf(t, g, "2S")}); err != nil {
+				t.Fatalf("PlayAction: %v", err)
+			}
+
+			err := tt.steps(t, g)
+			if CodeOf(err) != tt.wantCode {
+				t.Fatalf("error = %v (%s), want %q", err, CodeOf(err), tt.wantCode)
+			}
+			if g.PendingDraw != tt.wantPending {
+				t.Errorf("PendingDraw = %q, want %q", g.PendingDraw, tt.wantPending)
+			}
+			a := g.Players["a"]
+			if a.HandLimit != tt.wantLimit {
+				t.Errorf("HandLimit = %d, want %d", a.HandLimit, tt.wantLimit)
+			}
+			if len(a.Hand) != tt.wantHand {
+				t.Errorf("hand has %d cards, want %d", len(a.Hand), tt.wantHand)
+			}
+		})
+	}
+}
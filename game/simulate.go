@@ -0,0 +1,69 @@
+package game
+
+import (
+	"log"
+	mrand "math/rand"
+)
+
+// logf logs a game event unless the game is a look-ahead copy.
+func (g *Game) logf(format string, args ...interface{}) {
+	if !g.quiet {
+		log.Printf(format, args...)
+	}
+}
+
+// Clone returns an independent copy of the game for look-ahead: bots can play moves
+// on it without touching the real game. The copy has no connections and does not log.
+func (g *Game) Clone() *Game {
+	g.Lock()
+	defer g.Unlock()
+	return g.clone()
+}
+
+func (g *Game) clone() *Game {
+	c := &Game{
+		ID: g.ID, Board: g.Board, Players: make(map[string]*Player, len(g.Players)),
+		PlayerOrder: append([]string(nil), g.PlayerOrder...), CurrentTurnIndex: g.CurrentTurnIndex,
+		DrawPile: append([]Card(nil), g.DrawPile...), DrawPileCount: g.DrawPileCount,
+		DiscardPile: append([]Card(nil), g.DiscardPile...), GamePhase: g.GamePhase, Winner: g.Winner,
+		NumSequencesToWin: g.NumSequencesToWin, MaxPlayers: g.MaxPlayers, HostID: g.HostID,
+		HotSeat: g.HotSeat, Ranked: g.Ranked, Teams: g.Teams, Rules: g.Rules, DeadCardUsed: g.DeadCardUsed, PendingDraw: g.PendingDraw,
+		MoveCount: g.MoveCount, StartedAt: g.StartedAt, FinishedAt: g.FinishedAt, quiet: true,
+	}
+	for id, p := range g.Players {
+		c.Players[id] = &Player{
+			ID: p.ID, Name: p.Name, Hand: append([]Card(nil), p.Hand...), ChipColor: p.ChipColor,
+			Sequences: p.Sequences, IsConnected: p.IsConnected, HandLimit: p.HandLimit,
+			JacksUsed: p.JacksUsed, DeadCards: p.DeadCards, Bot: p.Bot, Team: p.Team,
+		}
+	}
+	return c
+}
+
+// Determinize returns a copy of the game as observer might imagine it: the cards
+// observer cannot see, the other players' hands and the draw pile, are shuffled
+// together and dealt back out in the same sizes. Those cards are exactly the decks
+// minus observer's hand and the discard pile, so the copy reveals nothing observer
+// could not work out. Later reshuffles in the copy use rng.
+func (g *Game) Determinize(observer string, rng *mrand.Rand) *Game {
+	g.Lock()
+	defer g.Unlock()
+
+	c := g.clone()
+	c.rng = rng
+	unseen := append([]Card(nil), c.DrawPile...)
+	for _, id := range c.PlayerOrder {
+		if id != observer {
+			unseen = append(unseen, c.Players[id].Hand...)
+		}
+	}
+	rng.Shuffle(len(unseen), func(i, j int) { unseen[i], unseen[j] = unseen[j], unseen[i] })
+	for _, id := range c.PlayerOrder {
+		if p := c.Players[id]; id != observer {
+			n := len(p.Hand)
+			p.Hand, unseen = unseen[:n:n], unseen[n:]
+		}
+	}
+	c.DrawPile = unseen
+	return c
+}
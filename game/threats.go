@@ -0,0 +1,180 @@
+package game
+
+// threatDirections are the four directions a sequence can run in.
+var threatDirections = []Position{{X: 0, Y: 1}, {X: 1, Y: 0}, {X: 1, Y: 1}, {X: 1, Y: -1}}
+
+// Threat is a line a player is building: a window of SequenceLength spaces that
+// holds only their chips (free corners count) and is one or two chips short of a
+// sequence. With the standard length these are the open fours and open threes.
+type Threat struct {
+	PlayerID string       `json:"playerId"`
+	Chips    int          `json:"chips"`   // Spaces of the window already counting for the player
+	Spaces   []Position   `json:"spaces"`  // The whole window, in order
+	Missing  []Completion `json:"missing"` // The empty spaces that complete it
+}
+
+// Completion is an empty space a line still needs and the card that fills it.
+type Completion struct {
+	Pos    Position `json:"pos"`
+	CardID string   `json:"cardId"` // Card printed on the space; a wild Jack fills it too
+	Live   bool     `json:"live"`   // A copy of the card is still in the draw pile or a hand, or will be reshuffled into it
+}
+
+// DoubleThreat is an empty space where a single chip would leave the player a chip
+// away from a sequence in two places at once, so that one block cannot stop both.
+type DoubleThreat struct {
+	PlayerID   string     `json:"playerId"`
+	Completion            // The space that sets it up
+	Completes  []Position `json:"completes"` // The spaces that would each then complete a sequence
+}
+
+// ThreatReport is every side's threats on the board, sorted by player in turn
+// order, then by board position. A team's threats are listed once, under its
+// first player in turn order.
+type ThreatReport struct {
+	Threats       []Threat       `json:"threats"`
+	DoubleThreats []DoubleThreat `json:"doubleThreats"`
+}
+
+// Threats scans the board for lines one or two chips short of a sequence and for
+// the spaces that would create two threats at once.
+func (g *Game) Threats() ThreatReport {
+	g.Lock()
+	defer g.Unlock()
+	return g.threats()
+}
+
+func (g *Game) threats() ThreatReport {
+	report := ThreatReport{Threats: []Threat{}, DoubleThreats: []DoubleThreat{}}
+	length := g.Rules.SequenceLength
+	for i, playerID := range g.PlayerOrder {
+		if g.teammateBefore(i) {
+			continue
+		}
+		for x := 0; x < BoardSize; x++ {
+			for y := 0; y < BoardSize; y++ {
+				for _, dir := range threatDirections {
+					spaces, missing, ok := g.window(playerID, Position{X: x, Y: y}, dir)
+					if !ok || len(missing) == 0 || len(missing) > 2 {
+						continue
+					}
+					threat := Threat{PlayerID: playerID, Chips: length - len(missing), Spaces: spaces}
+					for _, pos := range missing {
+						threat.Missing = append(threat.Missing, g.completion(pos))
+					}
+					report.Threats = append(report.Threats, threat)
+				}
+			}
+		}
+
+		for x := 0; x < BoardSize; x++ {
+			for y := 0; y < BoardSize; y++ {
+				pos := Position{X: x, Y: y}
+				if g.checkOpen(pos) != nil {
+					continue
+				}
+				if completes := g.threatsCreated(playerID, pos); len(completes) >= 2 {
+					report.DoubleThreats = append(report.DoubleThreats, DoubleThreat{PlayerID: playerID, Completion: g.completion(pos), Completes: completes})
+				}
+			}
+		}
+	}
+	return report
+}
+
+// teammateBefore reports whether a teammate of the ith player in turn order comes
+// before them.
+func (g *Game) teammateBefore(i int) bool {
+	for _, earlier := range g.PlayerOrder[:i] {
+		if g.sameSide(earlier, g.PlayerOrder[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// window looks at the SequenceLength spaces from start in direction dir. It reports
+// false when the window leaves the board or holds another player's chip, and
+// otherwise lists its spaces and the ones still empty.
+func (g *Game) window(playerID string, start, dir Position) (spaces, missing []Position, ok bool) {
+	for i := 0; i < g.Rules.SequenceLength; i++ {
+		pos := Position{X: start.X + i*dir.X, Y: start.Y + i*dir.Y}
+		if !onBoard(pos) {
+			return nil, nil, false
+		}
+		space := g.Board[pos.X][pos.Y]
+		switch {
+		case g.countsFor(space, playerID):
+		case g.checkOpen(pos) == nil:
+			missing = append(missing, pos)
+		default:
+			return nil, nil, false
+		}
+		spaces = append(spaces, pos)
+	}
+	return spaces, missing, true
+}
+
+// threatsCreated lists the distinct spaces that would complete a sequence for the
+// player after a chip of theirs on the empty space pos, counting only lines through
+// pos that were two chips short.
+func (g *Game) threatsCreated(playerID string, pos Position) []Position {
+	var completes []Position
+	length := g.Rules.SequenceLength
+	for _, dir := range threatDirections {
+		for offset := 0; offset < length; offset++ {
+			start := Position{X: pos.X - offset*dir.X, Y: pos.Y - offset*dir.Y}
+			_, missing, ok := g.window(playerID, start, dir)
+			if !ok || len(missing) != 2 {
+				continue
+			}
+			other := missing[0]
+			if other == pos {
+				other = missing[1]
+			}
+			if !containsPosition(completes, other) {
+				completes = append(completes, other)
+			}
+		}
+	}
+	return completes
+}
+
+// completion describes the empty space pos as a way to complete a line.
+func (g *Game) completion(pos Position) Completion {
+	c := Completion{Pos: pos}
+	if card := g.Board[pos.X][pos.Y].Card; card != nil {
+		c.CardID, c.Live = card.ID, g.cardLive(card.ID)
+	}
+	return c
+}
+
+// cardLive reports whether some copy of the card can still be played: it is in
+// the draw pile or a hand, or discarded under the reshuffle rule, which brings the
+// discard pile back once the draw pile runs out.
+func (g *Game) cardLive(cardID string) bool {
+	piles := [][]Card{g.DrawPile}
+	for _, p := range g.Players {
+		piles = append(piles, p.Hand)
+	}
+	if g.Rules.DrawPileEmpty == ExhaustionReshuffle {
+		piles = append(piles, g.DiscardPile)
+	}
+	for _, pile := range piles {
+		for _, card := range pile {
+			if card.ID == cardID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsPosition(positions []Position, pos Position) bool {
+	for _, p := range positions {
+		if p == pos {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package game
+
+import "testing"
+
+func TestCardLive(t *testing.T) {
+	tests := []struct {
+		name      string
+		exhausted DrawPileExhaustion
+		where     string // Where the copies of 7C that are not discarded are: "pile", "hand" or "none"
+		want      bool
+	}{
+		{name: "in the draw pile", exhausted: ExhaustionPlayOut, where: "pile", want: true},
+		{name: "in a hand", exhausted: ExhaustionPlayOut, where: "hand", want: true},
+		{name: "every copy discarded", exhausted: ExhaustionPlayOut, where: "none", want: false},
+		{name: "every copy discarded, game ends when the pile does", exhausted: ExhaustionEndGame, where: "none", want: false},
+		{name: "every copy discarded, to be reshuffled", exhausted: ExhaustionReshuffle, where: "none", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := RulesPreset("Official")
+			if err != nil {
+				t.Fatal(err)
+			}
+			rules.DrawPileEmpty = tt.exhausted
+			g := startedGame(t, rules, "2S")
+
+			// Take every 7C out of play, then put one back where the case says
+			var seven Card
+			var pile []Card
+			for _, card := range g.DrawPile {
+				if card.ID == "7C" {
+					seven = card
+					g.DiscardPile = append(g.DiscardPile, card)
+				} else {
+					pile = append(pile, card)
+				}
+			}
+			g.DrawPile = pile
+			for _, p := range g.Players {
+				var hand []Card
+				for _, card := range p.Hand {
+					if card.ID == "7C" {
+						seven = card
+						g.DiscardPile = append(g.DiscardPile, card)
+					} else {
+						hand = append(hand, card)
+					}
+				}
+				p.Hand = hand
+			}
+			switch tt.where {
+			case "pile":
+				g.DrawPile = append(g.DrawPile, seven)
+			case "hand":
+				g.Players["b"].Hand = append(g.Players["b"].Hand, seven)
+			}
+
+			if got := g.cardLive("7C"); got != tt.want {
+				t.Errorf("cardLive(7C) = %t, want %t", got, tt.want)
+			}
+			if !g.cardLive("2S") {
+				t.Error("a card in a hand is not live")
+			}
+		})
+	}
+}
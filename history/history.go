@@ -0,0 +1,233 @@
+// Package history keeps a record of every finished game and answers statistics
+// about players from it. Records are appended to a JSON Lines file, one game per
+// line, and read back whole when the server starts.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"sequence-game/game"
+)
+
+// Result is how a game ended, overall or for one player.
+type Result string
+
+const (
+	ResultWin        Result = "WIN"
+	ResultLoss       Result = "LOSS"
+	ResultDraw       Result = "DRAW"
+	ResultInProgress Result = "IN_PROGRESS" // Summaries of games still being played
+)
+
+// Participant is one player's part in a game. Teammates share a chip colour and
+// a result; everyone else plays for themselves.
+type Participant struct {
+	PlayerID  string `json:"playerId"`
+	Name      string `json:"name"`
+	ChipColor string `json:"chipColor"`
+	Seat      int    `json:"seat"` // Position in the turn order, from 1
+	Sequences int    `json:"sequences"`
+	JacksUsed int    `json:"jacksUsed"`
+	DeadCards int    `json:"deadCardsDeclared"`
+	Result    Result `json:"result"`
+	Bot       bool   `json:"bot,omitempty"`  // Played by a program
+	Team      string `json:"team,omitempty"` // In a team game
+}
+
+// Side is who the participant played for: their team, or themselves.
+func (p Participant) Side() string {
+	if p.Team != "" {
+		return p.Team
+	}
+	return p.PlayerID
+}
+
+// Record is the summary of a game.
+type Record struct {
+	GameID          string        `json:"gameId"`
+	Rules           game.Rules    `json:"rules"`
+	HotSeat         bool          `json:"hotSeat,omitempty"`
+	Ranked          bool          `json:"ranked,omitempty"`
+	StartedAt       time.Time     `json:"startedAt"`
+	FinishedAt      time.Time     `json:"finishedAt"`
+	DurationSeconds float64       `json:"durationSeconds"`
+	MoveCount       int           `json:"moveCount"`
+	Winner          string        `json:"winner,omitempty"` // PlayerID of the winner, whose team won with them; empty for a draw
+	Result          Result        `json:"result"`           // WIN, DRAW or IN_PROGRESS
+	Players         []Participant `json:"players"`          // In turn order
+}
+
+// NewRecord summarizes g as it stands, finished or not. The caller must hold the game lock.
+func NewRecord(g *game.Game) Record {
+	r := Record{
+		GameID: g.ID, Rules: g.Rules, HotSeat: g.HotSeat, Ranked: g.Ranked, StartedAt: g.StartedAt, FinishedAt: g.FinishedAt,
+		MoveCount: g.MoveCount, Winner: g.Winner, Result: ResultInProgress, Players: []Participant{},
+	}
+	end := time.Now()
+	if g.GamePhase == "Finished" {
+		end = g.FinishedAt
+		r.Result = ResultDraw
+		if g.Winner != "" {
+			r.Result = ResultWin
+		}
+	}
+	if !g.StartedAt.IsZero() {
+		r.DurationSeconds = end.Sub(g.StartedAt).Round(time.Second).Seconds()
+	}
+	for i, id := range g.PlayerOrder {
+		p := g.Players[id]
+		participant := Participant{
+			PlayerID: id, Name: p.Name, ChipColor: p.ChipColor, Seat: i + 1,
+			Sequences: p.Sequences, JacksUsed: p.JacksUsed, DeadCards: p.DeadCards, Result: ResultInProgress, Bot: p.Bot, Team: p.Team,
+		}
+		switch r.Result {
+		case ResultDraw:
+			participant.Result = ResultDraw
+		case ResultWin:
+			participant.Result = ResultLoss
+			if winner := g.Players[g.Winner]; winner != nil && winner.Side() == p.Side() {
+				participant.Result = ResultWin
+			}
+		}
+		r.Players = append(r.Players, participant)
+	}
+	return r
+}
+
+// Stats sums up a player's finished games.
+type Stats struct {
+	PlayerID         string  `json:"playerId"`
+	Name             string  `json:"name,omitempty"` // Name in their latest game
+	GamesPlayed      int     `json:"gamesPlayed"`
+	Wins             int     `json:"wins"`
+	Losses           int     `json:"losses"`
+	Draws            int     `json:"draws"`
+	WinRate          float64 `json:"winRate"` // Wins per game played, from 0 to 1
+	AverageSequences float64 `json:"averageSequences"`
+	JacksUsed        int     `json:"jacksUsed"`
+	DeadCards        int     `json:"deadCardsDeclared"`
+}
+
+// Store holds the records, appending each new one to its file.
+type Store struct {
+	mu      sync.Mutex
+	path    string
+	records []Record
+	byGame  map[string]int // Index into records
+}
+
+// Open loads the records kept at path; a missing file is an empty history. An
+// empty path keeps the records in memory only.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, byGame: make(map[string]int)}
+	if path == "" {
+		return s, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		s.byGame[r.GameID] = len(s.records)
+		s.records = append(s.records, r)
+	}
+	return s, scanner.Err()
+}
+
+// Add records a finished game. A game already recorded is left as it was.
+func (s *Store) Add(r Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.byGame[r.GameID]; ok {
+		return nil
+	}
+	if err := s.append(data); err != nil {
+		return err
+	}
+	s.byGame[r.GameID] = len(s.records)
+	s.records = append(s.records, r)
+	return nil
+}
+
+// append adds a line to the store's file, if it has one.
+func (s *Store) append(line []byte) error {
+	if s.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Game returns the record of a finished game.
+func (s *Store) Game(gameID string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.byGame[gameID]
+	if !ok {
+		return Record{}, false
+	}
+	return s.records[i], true
+}
+
+// Player sums up every finished game the player took part in.
+func (s *Store) Player(playerID string) Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := Stats{PlayerID: playerID}
+	sequences := 0
+	for _, r := range s.records {
+		for _, p := range r.Players {
+			if p.PlayerID != playerID {
+				continue
+			}
+			stats.Name = p.Name
+			stats.GamesPlayed++
+			switch p.Result {
+			case ResultWin:
+				stats.Wins++
+			case ResultLoss:
+				stats.Losses++
+			case ResultDraw:
+				stats.Draws++
+			}
+			sequences += p.Sequences
+			stats.JacksUsed += p.JacksUsed
+			stats.DeadCards += p.DeadCards
+		}
+	}
+	if stats.GamesPlayed > 0 {
+		stats.WinRate = float64(stats.Wins) / float64(stats.GamesPlayed)
+		stats.AverageSequences = float64(sequences) / float64(stats.GamesPlayed)
+	}
+	return stats
+}
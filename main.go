@@ -1,45 +1,68 @@
 package main
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"math/big" // For crypto/rand
 	"net/http"
-	"os"            // Added for checking file existence
-	"path/filepath" // Added for path manipulation
-	"strconv"
-	"strings"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/websocket"
+
+	"sequence-game/accounts"
+	"sequence-game/bot"
+	"sequence-game/game"
+	"sequence-game/history"
+	"sequence-game/protocol"
+	"sequence-game/ratings"
+	"sequence-game/tournament"
 )
 
 // --- Constants & Configuration ---
+// The settings an operator may change are in Config (config.go).
 const (
-	BoardSize             = 10
-	NumDecks              = 2
-	DefaultSequencesToWin = 2
-	StaticDir             = "./static"   // Directory for static files
-	ClientHTMLFile        = "index.html" // Name of your HTML client file
-	LogsDir               = "./logs"     // Directory for game logs
+	ClientHTMLFile     = "index.html"      // Name of your HTML client file
+	TournamentHTMLFile = "tournament.html" // Tournament spectator page
+	AccountsFile       = "accounts.json"   // Files under the data directory
+	HistoryFile        = "history.jsonl"   // One finished game per line
+	RatingsFile        = "ratings.jsonl"   // One rated game's rating changes per line
+	TournamentsFile    = "tournaments.json"
+
+	IdleCheckInterval = time.Minute // How often games are checked against the idle timeout
+
+	HintSuggestions = 3                      // Moves suggested in each HINT
+	HintThinkTime   = 500 * time.Millisecond // Expert search time behind each HINT
+
+	MatchmakingInterval = time.Second      // How often queued players are grouped into games
+	MatchRangeStep      = 50.0             // Rating points a queued player's range widens by...
+	MatchRangeEvery     = 10 * time.Second // ...each time they have waited this long
 )
 
 // --- Utility: Ensure logs directory exists ---
 func ensureLogsDir() error {
-	if _, err := os.Stat(LogsDir); os.IsNotExist(err) {
-		return os.MkdirAll(LogsDir, 0o755)
+	if _, err := os.Stat(cfg.LogsDir); os.IsNotExist(err) {
+		return os.MkdirAll(cfg.LogsDir, 0o755)
 	}
 	return nil
 }
 
 // --- Utility: Write game log ---
 func writeGameLog(gameID string, content string) error {
+	if cfg.LogsDir == "" {
+		return nil
+	}
 	if err := ensureLogsDir(); err != nil {
 		return err
 	}
-	logFile := filepath.Join(LogsDir, gameID+".log")
+	logFile := filepath.Join(cfg.LogsDir, gameID+".log")
 	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
 	if err != nil {
 		return err
@@ -49,1020 +72,1160 @@ func writeGameLog(gameID string, content string) error {
 	return err
 }
 
-// --- Enums for Cards ---
-type Suit int
-
-const (
-	Hearts Suit = iota
-	Diamonds
-	Clubs
-	Spades
-	NoSuit // For Jokers or special cards if extended
-)
-
-// String for internal ID construction (e.g., "H", "S")
-func (s Suit) String() string {
-	return []string{"H", "D", "C", "S", "X"}[s]
-}
-
-// ToEmoji for display
-func (s Suit) ToEmoji() string {
-	return map[Suit]string{Hearts: "♥️", Diamonds: "♦️", Clubs: "♣️", Spades: "♠️", NoSuit: ""}[s]
-}
-
-type Rank int
-
-const (
-	Ace Rank = iota + 1 // Ace as 1 for simplicity in loops, can map to 'A'
-	Two
-	Three
-	Four
-	Five
-	Six
-	Seven
-	Eight
-	Nine
-	Ten   // Numeric 10
-	Jack  // J
-	Queen // Q
-	King  // K
-	NoRank
+// --- Game Management ---
+var (
+	games           = make(map[string]*game.Game)
+	gamesMu         sync.Mutex
+	accountStore    *accounts.Store
+	historyStore    *history.Store
+	ratingStore     *ratings.Store
+	tournamentStore *tournament.Store
+	upgrader        = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     checkOrigin,
+	}
 )
 
-// String for internal ID construction (e.g., "A", "10", "K")
-func (r Rank) String() string {
-	if r >= Two && r <= Ten {
-		return strconv.Itoa(int(r)) // "2", "3", ..., "10"
-	}
-	return map[Rank]string{
-		Ace:   "A",
-		Jack:  "J",
-		Queen: "Q",
-		King:  "K",
-	}[r]
-}
-
-// ToUnicode for display part of emoji string
-func (r Rank) ToUnicode() string {
-	// Same as String() for ranks, but explicit for display purposes
-	if r >= Two && r <= Ten {
-		return strconv.Itoa(int(r))
+// scheduleAutoDraw draws on behalf of a player who has not sent DRAW_CARD within the
+// rules' timeout, unless the draw window has already closed.
+func scheduleAutoDraw(g *game.Game, playerID string) {
+	g.Lock()
+	if !g.Rules.ExplicitDraw || g.Rules.DrawTimeoutSeconds <= 0 || g.PendingDraw != playerID {
+		g.Unlock()
+		return
 	}
-	return map[Rank]string{Ace: "A", Jack: "J", Queen: "Q", King: "K"}[r]
-}
-
-// --- Core Data Structures ---
+	moveCount := g.MoveCount
+	timeout := time.Duration(g.Rules.DrawTimeoutSeconds) * time.Second
+	g.Unlock()
 
-// Card represents a playing card
-type Card struct {
-	Rank Rank
-	Suit Suit
-	ID   string // e.g., "KH" for King of Hearts, "10S" for 10 of Spades
-}
-
-// ToEmojiString creates a display string like "A♠️"
-func (c Card) ToEmojiString() string {
-	if c.Rank == NoRank || c.Suit == NoSuit {
-		return c.ID // Fallback for special cases or if ID is already display-ready
-	}
-	return c.Rank.ToUnicode() + c.Suit.ToEmoji()
+	time.AfterFunc(timeout, func() {
+		if shuttingDown.Load() {
+			return // The draw is still pending in the snapshot, and rescheduled on restore
+		}
+		if g.AutoDraw(playerID, moveCount) {
+			broadcastGameState(g, protocol.EventGameUpdate, protocol.DrawDetail{Action: "AUTO_DRAW", PlayerID: playerID})
+		}
+	})
 }
 
-// Player represents a player in the game
-type Player struct {
-	ID          string          `json:"id"`
-	Name        string          `json:"name"`
-	Hand        []Card          `json:"-"`                     // Hide hand from other players in general broadcasts
-	VisibleHand []string        `json:"visibleHand,omitempty"` // For the player themselves (contains Card.ID)
-	ChipColor   string          `json:"chipColor"`
-	Sequences   int             `json:"sequences"`
-	Conn        *websocket.Conn `json:"-"` // WebSocket connection
-	IsConnected bool            `json:"isConnected"`
-}
+// --- WebSocket Handling ---
 
-// BoardSpace represents a single space on the game board
-type BoardSpace struct {
-	Card         *Card  `json:"card,omitempty"` // The card printed on this space (nil for corners)
-	OccupiedBy   string `json:"occupiedBy"`     // PlayerID of the chip on this space, or "" if empty
-	IsCorner     bool   `json:"isCorner"`       // To mark the free corner spaces
-	IsLocked     bool   `json:"isLocked"`       // If part of a completed sequence
-	DisplayValue string `json:"displayValue"`   // e.g. "A♠️", "7♦️", "FREE"
+// wsConn is a player's WebSocket. gorilla/websocket allows only one writer at a time,
+// and a connection is written to from its read loop, broadcasts, the auto-draw timer,
+// move analysis, the matchmaker and the shutdown, so every message goes through
+// WriteJSON's lock. Close and WriteControl are safe to call alongside it.
+type wsConn struct {
+	*websocket.Conn
+	writeMu sync.Mutex
 }
 
-// Position represents a coordinate on the board
-type Position struct {
-	X int `json:"x"`
-	Y int `json:"y"`
+func (c *wsConn) WriteJSON(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.Conn.WriteJSON(v)
 }
 
-// Game represents the entire game state
-type Game struct {
-	ID                string                           `json:"id"`
-	Board             [BoardSize][BoardSize]BoardSpace `json:"board"`
-	Players           map[string]*Player               `json:"players"`     // Map PlayerID to Player struct
-	PlayerOrder       []string                         `json:"playerOrder"` // To maintain turn order
-	CurrentTurnIndex  int                              `json:"currentTurnIndex"`
-	DrawPile          []Card                           `json:"-"` // Not usually sent to client
-	DrawPileCount     int                              `json:"drawPileCount"`
-	DiscardPile       []Card                           `json:"-"`
-	GamePhase         string                           `json:"gamePhase"`        // e.g., "Lobby", "InProgress", "Finished"
-	Winner            string                           `json:"winner,omitempty"` // PlayerID or TeamID
-	NumSequencesToWin int                              `json:"numSequencesToWin"`
-	MaxPlayers        int                              `json:"maxPlayers"`
-	HostID            string                           `json:"hostId"`
-	mu                sync.Mutex
+// tableSync remembers the last state broadcast for a game, so later broadcasts only send what changed
+type tableSync struct {
+	last      protocol.GameState
+	hands     map[string][]string // Last hand sent to each player
+	snapshots map[string]bool     // Players who must get a full snapshot on the next broadcast
+	analysis  map[string]bool     // Players who asked for a MOVE_ANALYSIS of each of their moves
+	recorded  bool                // The finished game has been written to the history
+	active    time.Time           // Last broadcast, for the idle timeout
+
+	// Hot-seat games only: the seat whose hand the device shows, the seat the
+	// device was last passed to, and the seat that confirmed it holds the device
+	shown, passedTo, revealed string
 }
 
-// --- Game Management ---
 var (
-	games    = make(map[string]*Game)
-	gamesMu  sync.Mutex
-	upgrader = websocket.Upgrader{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
-		CheckOrigin:     func(r *http.Request) bool { return true }, // Allow all origins for simplicity
-	}
+	tableSyncs   = make(map[string]*tableSync)
+	tableSyncsMu sync.Mutex
 )
 
-// generateID creates a unique random ID
-func generateID() string {
-	bytes := make([]byte, 8)
-	if _, err := rand.Read(bytes); err != nil {
-		return "errorID" // Fallback
+func syncFor(gameID string) *tableSync {
+	tableSyncsMu.Lock()
+	defer tableSyncsMu.Unlock()
+	ts, ok := tableSyncs[gameID]
+	if !ok {
+		ts = &tableSync{hands: make(map[string][]string), snapshots: make(map[string]bool), analysis: make(map[string]bool)}
+		tableSyncs[gameID] = ts
 	}
-	return hex.EncodeToString(bytes)
+	return ts
 }
 
-// --- Card and Deck Logic ---
-
-// newDeck creates a specified number of standard 52-card decks
-func newDeck(numDecks int) []Card {
-	var deck []Card
-	suits := []Suit{Hearts, Diamonds, Clubs, Spades}
-	ranks := []Rank{Ace, Two, Three, Four, Five, Six, Seven, Eight, Nine, Ten, Jack, Queen, King}
-
-	for i := 0; i < numDecks; i++ {
-		for _, suit := range suits {
-			for _, rank := range ranks {
-				// Card ID is canonical, e.g., "AS", "10H", "KD"
-				cardID := rank.String() + suit.String()
-				deck = append(deck, Card{Rank: rank, Suit: suit, ID: cardID})
-			}
-		}
+// requestSnapshot makes the next broadcast send playerID a full snapshot, e.g. after they (re)join
+func requestSnapshot(g *game.Game, playerID string) {
+	g.Lock()
+	defer g.Unlock()
+	ts := syncFor(g.ID)
+	ts.snapshots[playerID] = true
+	if g.HotSeat {
+		ts.revealed = "" // A reconnecting device is handed over again before any hand is shown
 	}
-	return deck
 }
 
-// shuffleDeck shuffles a slice of cards
-func shuffleDeck(deck []Card) {
-	n := len(deck)
-	for i := n - 1; i > 0; i-- {
-		jBig, _ := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
-		j := jBig.Int64()
-		deck[i], deck[j] = deck[j], deck[i]
+// sendHand sends player's hand over conn if it differs from the last one sent, or if force is set
+func sendHand(ts *tableSync, conn game.Conn, player *game.Player, version int, force bool) {
+	visibleHandIDs := make([]string, len(player.Hand))
+	for i, cardInHand := range player.Hand {
+		visibleHandIDs[i] = cardInHand.ID
+	}
+	if !force && reflect.DeepEqual(ts.hands[player.ID], visibleHandIDs) {
+		return
+	}
+	ts.hands[player.ID] = visibleHandIDs
+	handMsg := protocol.HandUpdate{Type: protocol.EventHandUpdate, Version: version, PlayerID: player.ID, Hand: visibleHandIDs}
+	if err := conn.WriteJSON(handMsg); err != nil {
+		log.Printf("Error sending hand update to player %s (%s): %v", player.Name, player.ID, err)
 	}
 }
 
-// dealCards deals cards to players based on game rules
-func (g *Game) dealCards() {
-	cardsPerPlayer := 0
-	numPlayers := len(g.Players)
-
-	switch {
-	case numPlayers <= 2:
-		cardsPerPlayer = 7
-	case numPlayers <= 4:
-		cardsPerPlayer = 6
-	case numPlayers <= 6:
-		cardsPerPlayer = 5
-	case numPlayers <= 9:
-		cardsPerPlayer = 4
-	case numPlayers <= 12:
-		cardsPerPlayer = 3
-	default:
-		log.Printf("Warning: Too many players (%d) for standard dealing.", numPlayers)
-		cardsPerPlayer = 3
+// sendHotSeatHand shows a hot-seat device the hand of the seat whose turn it is, once
+// that seat has confirmed with REVEAL_HAND that it holds the device. Until then the
+// hand is hidden and the device is told to PASS_DEVICE. The caller must hold the game lock.
+func sendHotSeatHand(g *game.Game, ts *tableSync, host *game.Player, force bool) {
+	active := ts.last.CurrentTurnPlayerID
+	if active != "" && ts.revealed == active {
+		sendHand(ts, host.Conn, g.Players[active], ts.last.Version, force || ts.shown != active)
+		ts.shown = active
+		return
 	}
-
-	for i := 0; i < cardsPerPlayer; i++ {
-		for _, playerID := range g.PlayerOrder {
-			player := g.Players[playerID]
-			if len(g.DrawPile) > 0 {
-				card := g.DrawPile[0]
-				g.DrawPile = g.DrawPile[1:]
-				player.Hand = append(player.Hand, card)
-			}
+	if ts.shown != "" || force {
+		hidden := protocol.HandUpdate{Type: protocol.EventHandUpdate, Version: ts.last.Version, Hand: []string{}}
+		if err := host.Conn.WriteJSON(hidden); err != nil {
+			log.Printf("Error hiding hand on hot-seat device of game %s: %v", g.ID, err)
+		}
+		ts.shown = ""
+	}
+	if active != "" && (force || ts.passedTo != active) {
+		passMsg := protocol.PassDevice{Type: protocol.EventPassDevice, GameID: g.ID, PlayerID: active, PlayerName: g.Players[active].Name}
+		if err := host.Conn.WriteJSON(passMsg); err != nil {
+			log.Printf("Error asking hot-seat device of game %s to pass to %s: %v", g.ID, active, err)
 		}
+		ts.passedTo = active
 	}
-	g.DrawPileCount = len(g.DrawPile)
 }
 
-// drawCard allows a player to draw a card
-func (g *Game) drawCard(playerID string) (*Card, error) {
-	player, ok := g.Players[playerID]
-	if !ok {
-		return nil, fmt.Errorf("player %s not found", playerID)
+// revealHand records that the hot-seat device has been passed to seatID and shows their hand
+func revealHand(g *game.Game, player *game.Player, seatID string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	if !g.HotSeat || player.ID != g.HostID {
+		return &game.ActionError{Code: protocol.CodeBadRequest, Message: "not a hot-seat game"}
 	}
-	if len(g.DrawPile) == 0 {
-		return nil, fmt.Errorf("draw pile is empty")
+	ts := syncFor(g.ID)
+	if seatID == "" || seatID != ts.last.CurrentTurnPlayerID {
+		return &game.ActionError{Code: game.CodeNotYourTurn, Message: fmt.Sprintf("it is not %s's turn", seatID)}
 	}
-
-	card := g.DrawPile[0]
-	g.DrawPile = g.DrawPile[1:]
-	g.DrawPileCount = len(g.DrawPile)
-	player.Hand = append(player.Hand, card)
-	return &card, nil
-}
-
-// --- Board Initialization ---
-
-// Standard Sequence Game Board Layout.
-// Each of the 48 non-Jack cards (A, K, Q, 10, 9, 8, 7, 6, 5, 4, 3, 2 for each suit) appears twice.
-// "FREE" denotes a corner space. Jacks are not on the board.
-// Card IDs are "RankSuit", e.g., "AS", "10D".
-// Suffixes like "_alt" are used in this array literal for the second instance of a card
-// to make the string unique if needed for visual clarity or specific tools;
-// parseCardID function will strip these suffixes to get the canonical card ID.
-var boardCardDistribution = [BoardSize][BoardSize]string{
-	{"FREE", "2S", "3S", "4S", "5S", "6S", "7S", "8S", "9S", "FREE"},
-	{"6C", "7C", "8C", "9C", "10C", "QC", "KC", "AC", "AD", "10S"},
-	{"5C", "4C", "3C", "2C", "AH", "KH", "QH", "10H", "KD", "AS"},
-	{"4D", "5D", "6D", "7D", "8D", "9D", "10D", "QD", "KS", "2D"},
-	{"3D", "2D_alt", "AS_alt", "KS_alt", "QS", "10S_alt", "9S_alt", "8S_alt", "QS_alt", "2H"},
-	{"4H", "5H", "6H", "7H", "8H", "9H", "10H_alt", "QH_alt", "KH_alt", "3H"},
-	{"3S_alt", "2H_alt", "AH_alt", "AC_alt", "KC_alt", "QC_alt", "10C_alt", "9C_alt", "8C_alt", "4S_alt"},
-	{"2S_alt", "3H_alt", "4H_alt", "5H_alt", "6H_alt", "7H_alt", "8H_alt", "9H_alt", "7C_alt", "5S_alt"},
-	{"AD_alt", "KD_alt", "QD_alt", "10D_alt", "9D_alt", "8D_alt", "7D_alt", "6D_alt", "6C_alt", "6S_alt"},
-	{"FREE", "5C_alt", "4C_alt", "3C_alt", "2C_alt", "4D_alt", "5D_alt", "7S_alt", "9S_another", "FREE"}, // Corrected last row for standard layout
+	ts.revealed = seatID
+	sendHotSeatHand(g, ts, player, false)
+	return nil
 }
 
-// parseCardID parses a string like "AS" or "10D_alt" into a Card struct.
-// It expects canonical IDs (e.g., "10S" not "TS").
-func parseCardID(idStr string) (*Card, error) {
-	if len(idStr) < 2 {
-		return nil, fmt.Errorf("card ID too short: %s", idStr)
+// seatFor returns the player a move sent by player is made for. In a hot-seat game
+// the host's connection plays whichever seat has been handed the device; it reports
+// false if the device has not been handed over yet.
+func seatFor(g *game.Game, player *game.Player) (*game.Player, bool) {
+	if !g.HotSeat {
+		return player, true
 	}
-
-	// Normalize by removing potential suffixes like "_alt", "_another", etc.
-	normalizedIDStr := idStr
-	if strings.Contains(idStr, "_") {
-		parts := strings.Split(idStr, "_")
-		normalizedIDStr = parts[0] // Take the part before the first underscore
+	g.Lock()
+	defer g.Unlock()
+	ts := syncFor(g.ID)
+	active := ts.last.CurrentTurnPlayerID
+	if active == "" {
+		return player, true // Not in play; the engine rejects the move
 	}
+	if ts.revealed != active {
+		return nil, false
+	}
+	return g.Players[active], true
+}
 
-	rankStr := ""
-	suitChar := ""
+// setHotSeatsConnected marks every seat of a hot-seat game online or offline with the
+// host's connection. The caller must hold the game lock.
+func setHotSeatsConnected(g *game.Game, connected bool) {
+	for _, seat := range g.Players {
+		seat.IsConnected = connected
+	}
+}
 
-	// Handle "10" rank
-	if strings.HasPrefix(normalizedIDStr, "10") {
-		if len(normalizedIDStr) != 3 {
-			return nil, fmt.Errorf("invalid card ID for 10: %s (from %s)", normalizedIDStr, idStr)
+// broadcastGameState bumps the state version and sends every connected player a patch
+// against the previous version (or a full snapshot where needed), followed by their hand if it changed
+func broadcastGameState(g *game.Game, eventType protocol.EventType, details interface{}) {
+	g.Lock()
+	defer g.Unlock()
+
+	ts := syncFor(g.ID)
+	ts.active = time.Now()
+	state := protocol.NewGameState(g, eventType, details)
+	state.Version = ts.last.Version + 1
+	if ts.last.DrawPileCount > 0 && state.DrawPileCount == 0 {
+		drawPilesExhausted.inc(string(g.Rules.DrawPileEmpty))
+	}
+	patch, patchable := state.Diff(&ts.last)
+	ts.last = state
+
+	for pid, player := range g.Players {
+		if player.Conn == nil || !player.IsConnected {
+			continue
 		}
-		rankStr = "10"
-		suitChar = string(normalizedIDStr[2])
-	} else {
-		if len(normalizedIDStr) != 2 {
-			return nil, fmt.Errorf("invalid card ID format: %s (from %s)", normalizedIDStr, idStr)
+		snapshot := !patchable || ts.snapshots[pid]
+		var msg interface{} = patch
+		if snapshot {
+			msg = state
+			delete(ts.snapshots, pid)
+		}
+		if err := player.Conn.WriteJSON(msg); err != nil {
+			log.Printf("Error broadcasting game state to player %s: %v", player.ID, err)
+		}
+		if g.HotSeat {
+			sendHotSeatHand(g, ts, player, snapshot)
+		} else {
+			sendHand(ts, player.Conn, player, state.Version, snapshot)
 		}
-		rankStr = string(normalizedIDStr[0])
-		suitChar = string(normalizedIDStr[1])
-	}
-
-	var rank Rank
-	switch rankStr {
-	case "A":
-		rank = Ace
-	case "2":
-		rank = Two
-	case "3":
-		rank = Three
-	case "4":
-		rank = Four
-	case "5":
-		rank = Five
-	case "6":
-		rank = Six
-	case "7":
-		rank = Seven
-	case "8":
-		rank = Eight
-	case "9":
-		rank = Nine
-	case "10":
-		rank = Ten
-	// Jacks are not on the board, so no case for "J" from board layout
-	case "Q":
-		rank = Queen
-	case "K":
-		rank = King
-	default:
-		return nil, fmt.Errorf("unknown rank string: '%s' in ID '%s' (from %s)", rankStr, normalizedIDStr, idStr)
-	}
-
-	var suit Suit
-	switch suitChar {
-	case "S":
-		suit = Spades
-	case "H":
-		suit = Hearts
-	case "D":
-		suit = Diamonds
-	case "C":
-		suit = Clubs
-	default:
-		return nil, fmt.Errorf("unknown suit char: '%s' in ID '%s' (from %s)", suitChar, normalizedIDStr, idStr)
 	}
+	broadcastSeconds.observe(time.Since(ts.active).Seconds())
+	log.Printf("Broadcasted game state for game %s, type: %s, version: %d", g.ID, eventType, state.Version)
 
-	// Construct canonical ID to ensure consistency
-	canonicalID := rank.String() + suit.String()
-	return &Card{Rank: rank, Suit: suit, ID: canonicalID}, nil
-}
-
-func (g *Game) initializeBoardLayout() {
-	for r := 0; r < BoardSize; r++ {
-		for c := 0; c < BoardSize; c++ {
-			idOnBoard := boardCardDistribution[r][c]
-			if idOnBoard == "FREE" {
-				g.Board[r][c] = BoardSpace{IsCorner: true, OccupiedBy: "CORNER", DisplayValue: "FREE"}
-			} else if idOnBoard == "EMPTY" || idOnBoard == "" { // Should not happen with complete board
-				g.Board[r][c] = BoardSpace{DisplayValue: " "}
-			} else {
-				card, err := parseCardID(idOnBoard)
-				if err != nil {
-					log.Printf("Error parsing card ID '%s' for board at [%d][%d]: %v. Setting as 'ERR'.", idOnBoard, r, c, err)
-					g.Board[r][c] = BoardSpace{DisplayValue: "ERR"}
-					continue
-				}
-				g.Board[r][c] = BoardSpace{Card: card, DisplayValue: card.ToEmojiString()}
+	// Every way a game can end is broadcast, so this is where it is recorded
+	if g.GamePhase == "Finished" && !ts.recorded {
+		ts.recorded = true
+		if !g.StartedAt.IsZero() {
+			gameSeconds.observe(g.FinishedAt.Sub(g.StartedAt).Seconds())
+		}
+		record := history.NewRecord(g)
+		if err := historyStore.Add(record); err != nil {
+			log.Printf("Error recording game %s in the history: %v", g.ID, err)
+		}
+		if entry, rated, err := ratingStore.Rate(record); err != nil {
+			log.Printf("Error rating game %s: %v", g.ID, err)
+		} else if rated {
+			for _, c := range entry.Changes {
+				_ = writeGameLog(g.ID, fmt.Sprintf("%s rating (%s): %.1f -> %.1f", c.Name, entry.Mode, c.Before, c.After))
 			}
 		}
+		tournamentGameFinished(record)
 	}
-	log.Println("Board initialized with a standard Sequence board layout.")
 }
 
-// --- Game Actions & Logic ---
+// sendSnapshot answers a RESYNC with the full state at the current version
+func sendSnapshot(g *game.Game, playerID string) {
+	g.Lock()
+	defer g.Unlock()
 
-// NewGame creates a new game instance
-func NewGame(hostID, hostName string, maxPlayers, sequencesToWin int) *Game {
-	gameID := generateID()
-	if sequencesToWin <= 0 {
-		sequencesToWin = DefaultSequencesToWin
+	player, ok := g.Players[playerID]
+	if !ok || player.Conn == nil {
+		return
 	}
-	if maxPlayers <= 0 || maxPlayers > 12 {
-		maxPlayers = 4
+	ts := syncFor(g.ID)
+	state := protocol.NewGameState(g, protocol.EventGameUpdate, nil)
+	state.Version = ts.last.Version
+	if err := player.Conn.WriteJSON(state); err != nil {
+		log.Printf("Error sending snapshot to player %s: %v", playerID, err)
 	}
-
-	g := &Game{
-		ID: gameID, Players: make(map[string]*Player), DrawPile: newDeck(NumDecks),
-		GamePhase: "Lobby", NumSequencesToWin: sequencesToWin, MaxPlayers: maxPlayers,
-		HostID: hostID, CurrentTurnIndex: 0,
+	if g.HotSeat {
+		sendHotSeatHand(g, ts, player, true)
+	} else {
+		sendHand(ts, player.Conn, player, state.Version, true)
 	}
-	g.initializeBoardLayout()
-	shuffleDeck(g.DrawPile)
-	g.DrawPileCount = len(g.DrawPile)
-	log.Printf("New game created: %s by %s", gameID, hostName)
-	return g
 }
 
-// --- Helper: Count unique sequences only ---
-func (g *Game) countUniqueSequences(playerID string) int {
-	locked := make(map[[5][2]int]bool)
-	unique := 0
-	dirs := [][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
-	for x := 0; x < BoardSize; x++ {
-		for y := 0; y < BoardSize; y++ {
-			for _, dir := range dirs {
-				var seq [5][2]int
-				ok := true
-				for i := 0; i < 5; i++ {
-					nx, ny := x+dir[0]*i, y+dir[1]*i
-					if nx < 0 || nx >= BoardSize || ny < 0 || ny >= BoardSize {
-						ok = false
-						break
-					}
-					space := g.Board[nx][ny]
-					if !(space.OccupiedBy == playerID || (space.IsCorner && !space.IsLocked)) {
-						ok = false
-						break
-					}
-					seq[i] = [2]int{nx, ny}
-				}
-				if ok && !locked[seq] {
-					unique++
-					locked[seq] = true
-				}
-			}
+// sendHint answers a REQUEST_HINT with the expert's suggestions for seat's turn
+func sendHint(g *game.Game, conn *wsConn, seat *game.Player) {
+	hint := protocol.Hint{Type: protocol.EventHint, GameID: g.ID, PlayerID: seat.ID, Suggestions: []protocol.Suggestion{}, Threats: g.Threats()}
+	for _, s := range bot.Suggest(g, seat.ID, HintSuggestions, HintThinkTime) {
+		suggestion := protocol.Suggestion{DeadCard: s.DeadCard, Reasons: s.Reasons}
+		if s.DeadCard == "" {
+			action := s.Action
+			suggestion.Action = &action
 		}
+		hint.Suggestions = append(hint.Suggestions, suggestion)
+	}
+	g.Lock() // Broadcasts write to the same connection under the game lock
+	defer g.Unlock()
+	if err := conn.WriteJSON(hint); err != nil {
+		log.Printf("Error sending hint to player %s: %v", seat.ID, err)
 	}
-	return unique
 }
 
-// AddPlayer adds a player to the game or reconnects them if PlayerID matches
-func (g *Game) AddPlayer(playerID, playerName string, conn *websocket.Conn) (*Player, error) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	if g.GamePhase != "Lobby" && g.GamePhase != "InProgress" {
-		return nil, fmt.Errorf("game %s is not joinable", g.ID)
+// setAnalysis turns MOVE_ANALYSIS on or off for a player. Hot-seat games have no
+// analysis: it would reach the shared device after the next seat has taken it.
+func setAnalysis(g *game.Game, playerID string, enabled bool) error {
+	g.Lock()
+	defer g.Unlock()
+	if enabled && !g.Rules.Hints {
+		return fmt.Errorf("move analysis is disabled in this game")
 	}
-	if len(g.Players) >= g.MaxPlayers && g.Players[playerID] == nil {
-		return nil, fmt.Errorf("game %s is full", g.ID)
+	if enabled && g.HotSeat {
+		return fmt.Errorf("move analysis is not available in hot-seat games")
 	}
+	syncFor(g.ID).analysis[playerID] = enabled
+	return nil
+}
 
-	// Rejoin by PlayerID (preferred)
-	if existingPlayer, exists := g.Players[playerID]; exists {
-		existingPlayer.Conn = conn
-		existingPlayer.IsConnected = true
-		log.Printf("Player %s (%s) rejoined game %s", existingPlayer.Name, playerID, g.ID)
-		return existingPlayer, nil
-	}
+// wantsAnalysis reports whether playerID's moves are to be reviewed
+func wantsAnalysis(g *game.Game, playerID string) bool {
+	g.Lock()
+	defer g.Unlock()
+	return g.Rules.Hints && !g.HotSeat && syncFor(g.ID).analysis[playerID]
+}
 
-	// Rejoin by name (legacy fallback)
-	for _, existingPlayer := range g.Players {
-		if existingPlayer.Name == playerName {
-			existingPlayer.Conn = conn
-			existingPlayer.IsConnected = true
-			log.Printf("Player %s (rejoin by name) reconnected to game %s", playerName, g.ID)
-			return existingPlayer, nil
+// sendAnalysis reviews the play seatID made from before, a copy of the game taken just
+// before it, and sends the MOVE_ANALYSIS to playerID. It replays candidate moves, so
+// callers run it on its own goroutine.
+func sendAnalysis(g *game.Game, playerID, seatID string, before *game.Game, action game.PlayerAction) {
+	review := bot.Analyze(before, seatID, action)
+	msg := protocol.MoveAnalysis{
+		Type: protocol.EventMoveAnalysis, GameID: g.ID, PlayerID: seatID, MoveCount: before.MoveCount + 1,
+		Action: action, Verdict: protocol.Verdict(review.Verdict), Notes: review.Notes, Better: review.Better,
+		Threats: review.Threats,
+	}
+	g.Lock()
+	defer g.Unlock()
+	if player, ok := g.Players[playerID]; ok && player.Conn != nil && player.IsConnected {
+		if err := player.Conn.WriteJSON(msg); err != nil {
+			log.Printf("Error sending move analysis to player %s: %v", playerID, err)
 		}
 	}
+}
 
-	// New player
-	colors := []string{"red", "blue", "green", "yellow", "purple", "orange", "pink", "cyan", "lime", "brown", "teal", "magenta"}
-	chipColor := colors[len(g.Players)%len(colors)]
+// accountEvent describes who a connection plays as; account is nil for a guest
+func accountEvent(playerID string, account *accounts.Account, sessionToken string) *protocol.Account {
+	event := &protocol.Account{Type: protocol.EventAccount, PlayerID: playerID, SessionToken: sessionToken}
+	if account != nil {
+		profile := account.Profile
+		event.Username, event.Profile = account.Username, &profile
+	}
+	return event
+}
 
-	player := &Player{
-		ID: playerID, Name: playerName, ChipColor: chipColor, Conn: conn,
-		IsConnected: true, Hand: make([]Card, 0),
+// sendAccount answers an account request with the connection's new identity
+func sendAccount(conn *wsConn, playerID string, account *accounts.Account, sessionToken string) {
+	if err := conn.WriteJSON(accountEvent(playerID, account, sessionToken)); err != nil {
+		log.Printf("Error sending account to %s: %v", playerID, err)
 	}
-	g.Players[playerID] = player
-	g.PlayerOrder = append(g.PlayerOrder, playerID)
-	log.Printf("Player %s (%s) added to game %s with color %s", playerName, playerID, g.ID, chipColor)
-	return player, nil
 }
 
-// StartGame transitions the game from Lobby to InProgress
-func (g *Game) StartGame(playerID string) error {
-	g.mu.Lock()
-	defer g.mu.Unlock()
+// nameFor is the name a player sits down with: the one asked for, else their display name
+func nameFor(requested string, account *accounts.Account) string {
+	if requested == "" && account != nil {
+		return account.Profile.DisplayName
+	}
+	return requested
+}
 
-	if g.HostID != playerID {
-		return fmt.Errorf("only the host can start the game")
+// applyProfile gives a logged-in player who just sat down their chip colour and settings
+func applyProfile(g *game.Game, playerID string, account *accounts.Account) {
+	if account == nil {
+		return
 	}
-	if g.GamePhase != "Lobby" {
-		return fmt.Errorf("game %s is not in lobby phase", g.ID)
+	if color := account.Profile.ChipColor; color != "" {
+		g.PreferChipColor(playerID, color)
 	}
-	if len(g.Players) < 2 {
-		return fmt.Errorf("not enough players to start. Need at least 2, have %d", len(g.Players))
+	if account.Profile.Settings.ReviewMoves && g.Rules.Hints {
+		_ = setAnalysis(g, playerID, true)
 	}
-
-	g.dealCards()
-	g.GamePhase = "InProgress"
-	g.CurrentTurnIndex = 0
-	log.Printf("Game %s started by %s", g.ID, playerID)
-	return nil
 }
 
-// removeCardFromHand removes a specific card (by Card.ID) from a player's hand
-func (p *Player) removeCardFromHand(cardID string) bool {
-	for i, cardInHand := range p.Hand {
-		if cardInHand.ID == cardID {
-			p.Hand = append(p.Hand[:i], p.Hand[i+1:]...)
-			return true
-		}
+// markBot records that a player who just sat down is a program, which keeps the
+// game off the ratings.
+func markBot(g *game.Game, playerID string) {
+	g.Lock()
+	if p, ok := g.Players[playerID]; ok {
+		p.Bot = true
 	}
-	return false
+	g.Unlock()
 }
 
-// GetCardFromHand retrieves a card from hand by Card.ID
-func (p *Player) GetCardFromHand(cardID string) (*Card, bool) {
-	for i := range p.Hand {
-		if p.Hand[i].ID == cardID {
-			return &p.Hand[i], true
+// sendError reports a rejected request to its sender only
+func sendError(conn *wsConn, gameID, requestID string, code game.ErrorCode, errorMessage string) {
+	errPayload := protocol.Error{Type: protocol.EventError, GameID: gameID, RequestID: requestID, Code: code, Error: errorMessage}
+	actionsRejected.inc(string(code))
+	if conn != nil {
+		if err := conn.WriteJSON(errPayload); err != nil {
+			log.Printf("Error sending error: %v", err)
 		}
 	}
-	return nil, false
 }
 
-// PlayAction handles a player's move
-func (g *Game) PlayAction(playerID string, action PlayerAction) error {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	if g.GamePhase != "InProgress" {
-		return fmt.Errorf("game is not in progress")
-	}
-	if len(g.PlayerOrder) == 0 || g.CurrentTurnIndex >= len(g.PlayerOrder) || g.PlayerOrder[g.CurrentTurnIndex] != playerID {
-		return fmt.Errorf("it's not player %s's turn", playerID)
-	}
-
-	player, ok := g.Players[playerID]
-	if !ok {
-		return fmt.Errorf("player %s not found", playerID)
+// rulesForCreate resolves and validates the rules requested in CREATE_GAME,
+// including its sequencesToWin override
+func rulesForCreate(req *protocol.CreateGame) (game.Rules, error) {
+	var rules game.Rules
+	var err error
+	switch {
+	case req.Rules != nil:
+		rules = *req.Rules
+		rules.Preset = "Custom"
+	case req.RulesPreset == "":
+		rules, err = cfg.defaultRules()
+	default:
+		rules, err = game.RulesPreset(req.RulesPreset)
 	}
-
-	playedCard, hasCard := player.GetCardFromHand(action.CardID)
-	if !hasCard {
-		return fmt.Errorf("player %s does not have card %s", playerID, action.CardID)
+	if err != nil {
+		return rules, err
 	}
-
-	if action.BoardPos.X < 0 || action.BoardPos.X >= BoardSize || action.BoardPos.Y < 0 || action.BoardPos.Y >= BoardSize {
-		return fmt.Errorf("invalid board position")
-	}
-	targetSpace := &g.Board[action.BoardPos.X][action.BoardPos.Y]
-
-	isTwoEyedJack := playedCard.Rank == Jack && (playedCard.Suit == Hearts || playedCard.Suit == Diamonds)
-	isOneEyedJack := playedCard.Rank == Jack && (playedCard.Suit == Clubs || playedCard.Suit == Spades)
-
-	if isOneEyedJack {
-		if targetSpace.OccupiedBy == "" || targetSpace.OccupiedBy == "CORNER" {
-			return fmt.Errorf("cannot remove chip from empty or corner space")
-		}
-		if targetSpace.OccupiedBy == player.ID {
-			return fmt.Errorf("cannot remove your own chip with One-Eyed Jack")
-		}
-		if targetSpace.IsLocked {
-			return fmt.Errorf("cannot remove chip from a locked sequence")
-		}
-
-		log.Printf("Player %s uses One-Eyed Jack %s to remove chip at (%d,%d) by %s", player.Name, playedCard.ToEmojiString(), action.BoardPos.X, action.BoardPos.Y, targetSpace.OccupiedBy)
-		targetSpace.OccupiedBy = ""
-	} else {
-		if targetSpace.OccupiedBy != "" && targetSpace.OccupiedBy != "CORNER" {
-			return fmt.Errorf("space (%d,%d) is already occupied by %s", action.BoardPos.X, action.BoardPos.Y, targetSpace.OccupiedBy)
-		}
-		if !isTwoEyedJack {
-			if targetSpace.Card == nil {
-				return fmt.Errorf("board space (%d,%d) has no card defined, cannot play %s", action.BoardPos.X, action.BoardPos.Y, playedCard.ToEmojiString())
-			}
-			if targetSpace.Card.ID != playedCard.ID {
-				return fmt.Errorf("card %s (%s) does not match board space (%d,%d) which is %s (expected card ID: %s)",
-					playedCard.ToEmojiString(), playedCard.ID, action.BoardPos.X, action.BoardPos.Y, targetSpace.DisplayValue, targetSpace.Card.ID)
-			}
-		}
-		log.Printf("Player %s plays %s to place chip at (%d,%d)", player.Name, playedCard.ToEmojiString(), action.BoardPos.X, action.BoardPos.Y)
-		targetSpace.OccupiedBy = playerID
+	if req.SequencesToWin > 0 {
+		rules.SequencesToWin = req.SequencesToWin
 	}
+	return rules, rules.Validate()
+}
 
-	player.removeCardFromHand(playedCard.ID)
-	if _, err := g.drawCard(playerID); err != nil {
-		log.Printf("Player %s could not draw card: %v", playerID, err)
+// checkHotSeat validates the local players requested in CREATE_GAME for a hot-seat game
+func checkHotSeat(req *protocol.CreateGame, rules game.Rules) error {
+	if seats := len(req.HotSeat) + 1; seats > rules.MaxSupportedPlayers() {
+		return fmt.Errorf("%d hot-seat players is more than these rules allow (%d)", seats, rules.MaxSupportedPlayers())
 	}
-
-	newSequencesFormed := g.checkForSequencesAfterPlay(playerID, action.BoardPos.X, action.BoardPos.Y)
-	if newSequencesFormed > 0 {
-		player.Sequences = g.countUniqueSequences(playerID)
-		log.Printf("Player %s formed %d new sequence(s)! Total sequences: %d", player.Name, newSequencesFormed, player.Sequences)
-		if player.Sequences >= g.NumSequencesToWin {
-			g.GamePhase = "Finished"
-			g.Winner = playerID
-			log.Printf("Game Over! Player %s wins!", player.Name)
+	names := map[string]bool{req.PlayerName: true}
+	for _, name := range req.HotSeat {
+		if name == "" || names[name] {
+			return fmt.Errorf("hot-seat player names must be unique and not empty, got %q", name)
 		}
-	}
-
-	if g.GamePhase == "InProgress" {
-		g.CurrentTurnIndex = (g.CurrentTurnIndex + 1) % len(g.PlayerOrder)
+		names[name] = true
 	}
 	return nil
 }
 
-// HandleDeadCard allows a player to discard a dead card and draw a new one.
-func (g *Game) HandleDeadCard(playerID string, cardID string) error {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	if g.GamePhase != "InProgress" {
-		return fmt.Errorf("game is not in progress")
-	}
-	if len(g.PlayerOrder) == 0 || g.CurrentTurnIndex >= len(g.PlayerOrder) || g.PlayerOrder[g.CurrentTurnIndex] != playerID {
-		return fmt.Errorf("it's not player %s's turn", playerID)
+// playDetail describes an accepted PLAY_ACTION for the GAME_UPDATE broadcast
+func playDetail(g *game.Game, playerName string, action game.PlayerAction) protocol.PlayDetail {
+	detail := protocol.PlayDetail{
+		Action: protocol.ActionPlay, Player: playerName,
+		CardPlayedDisplay: action.CardID, CardPlayedID: action.CardID, Pos: action.BoardPos,
 	}
+	playedCard, err := game.ParseCardID(action.CardID)
+	if err != nil {
+		return detail
+	}
+	detail.CardPlayedDisplay = playedCard.ToEmojiString()
+	detail.MoveKind, _ = g.Rules.Jacks.ResolveMove(playedCard, action.MoveKind)
+	switch detail.MoveKind {
+	case game.MoveRemove:
+		detail.RemovedChipAt = &action.BoardPos
+	case game.MoveSwap:
+		detail.MovedChipFrom = &action.BoardPos
+		detail.MovedChipTo = action.TargetPos
+	}
+	return detail
+}
 
-	player, ok := g.Players[playerID]
-	if !ok {
-		return fmt.Errorf("player %s not found", playerID)
+// handleDisconnect marks the player offline and drops the game once nobody is left
+func handleDisconnect(g *game.Game, playerID string) {
+	if shuttingDown.Load() {
+		return // The game is saved and closed along with the server
 	}
-
-	deadCardInHand, hasCard := player.GetCardFromHand(cardID)
-	if !hasCard {
-		return fmt.Errorf("player %s does not have card %s", playerID, cardID)
+	g.Lock()
+	p, ok := g.Players[playerID]
+	if !ok {
+		g.Unlock()
+		return
 	}
-	if deadCardInHand.Rank == Jack {
-		return fmt.Errorf("jacks cannot be dead cards")
+	p.IsConnected = false
+	if g.HotSeat {
+		setHotSeatsConnected(g, false)
 	}
+	log.Printf("Player %s (%s) disconnected from game %s.", p.Name, playerID, g.ID)
 
-	isActuallyDead := true
-	spotsForThisCard := 0
-	for r := 0; r < BoardSize; r++ {
-		for c := 0; c < BoardSize; c++ {
-			space := g.Board[r][c]
-			if space.Card != nil && space.Card.ID == deadCardInHand.ID {
-				spotsForThisCard++
-				if space.OccupiedBy == "" || space.OccupiedBy == "CORNER" {
-					isActuallyDead = false
-					break
-				}
-			}
-		}
-		if !isActuallyDead {
+	allDisconnected := true
+	for _, playerInGame := range g.Players {
+		if playerInGame.IsConnected {
+			allDisconnected = false
 			break
 		}
 	}
+	// Tournament games wait for their players to come back
+	_, isMatch := tournamentStore.MatchPlayers(g.ID)
+	removeGame := allDisconnected && g.GamePhase != "Finished" && !isMatch
+	g.Unlock()
 
-	if spotsForThisCard == 0 {
-		log.Printf("Error: Card %s (%s) declared dead by %s, but no spots found on board for this card ID. Check board layout.",
-			deadCardInHand.ToEmojiString(), deadCardInHand.ID, player.Name)
-		return fmt.Errorf("card %s not found on board layout, cannot be dead", deadCardInHand.ToEmojiString())
-	}
-	if !isActuallyDead {
-		return fmt.Errorf("card %s (%s) is not dead, an available spot exists", deadCardInHand.ToEmojiString(), deadCardInHand.ID)
-	}
-
-	log.Printf("Player %s declares %s (%s) as a dead card.", player.Name, deadCardInHand.ToEmojiString(), deadCardInHand.ID)
-	player.removeCardFromHand(deadCardInHand.ID)
-
-	if _, err := g.drawCard(playerID); err != nil {
-		log.Printf("Player %s could not draw replacement card: %v", playerID, err)
-	}
-
-	if g.GamePhase == "InProgress" {
-		g.CurrentTurnIndex = (g.CurrentTurnIndex + 1) % len(g.PlayerOrder)
-	}
-	return nil
-}
-
-// checkForSequencesAfterPlay
-func (g *Game) checkForSequencesAfterPlay(playerID string, x, y int) int {
-	sequencesFound := 0
-	dirs := [][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
-
-	for _, dir := range dirs {
-		count := 1
-		chipsInSequence := []Position{{X: x, Y: y}}
-
-		for i := 1; i < 5; i++ {
-			nx, ny := x+dir[0]*i, y+dir[1]*i
-			if nx < 0 || nx >= BoardSize || ny < 0 || ny >= BoardSize {
-				break
-			}
-			space := g.Board[nx][ny]
-			if space.OccupiedBy == playerID || (space.IsCorner && !space.IsLocked) {
-				count++
-				chipsInSequence = append(chipsInSequence, Position{X: nx, Y: ny})
-			} else {
-				break
-			}
-		}
-		for i := 1; i < 5; i++ {
-			nx, ny := x-dir[0]*i, y-dir[1]*i
-			if nx < 0 || nx >= BoardSize || ny < 0 || ny >= BoardSize {
-				break
-			}
-			space := g.Board[nx][ny]
-			if space.OccupiedBy == playerID || (space.IsCorner && !space.IsLocked) {
-				count++
-				chipsInSequence = append(chipsInSequence, Position{X: nx, Y: ny})
-			} else {
-				break
-			}
-		}
-
-		if count >= 5 {
-			isNewSequence := false
-			for _, pos := range chipsInSequence {
-				boardChip := g.Board[pos.X][pos.Y]
-				if !boardChip.IsLocked || boardChip.IsCorner || (pos.X == x && pos.Y == y) {
-					isNewSequence = true
-					break
-				}
-			}
-			if isNewSequence {
-				sequencesFound++
-				log.Printf("Sequence of %d found for player %s at (%d,%d) in dir (%d,%d)", count, playerID, x, y, dir[0], dir[1])
-				for _, pos := range chipsInSequence {
-					if !g.Board[pos.X][pos.Y].IsCorner {
-						g.Board[pos.X][pos.Y].IsLocked = true
-					}
-				}
-			}
-		}
+	if removeGame {
+		log.Printf("All players disconnected from game %s. Removing game.", g.ID)
+		forgetGame(g)
+		return
 	}
-	return sequencesFound
+	broadcastGameState(g, protocol.EventGameUpdate, protocol.NoticeDetail{Message: fmt.Sprintf("Player %s disconnected", p.Name)})
 }
 
-// --- WebSocket Handling ---
-
-// ClientMessage
-type ClientMessage struct {
-	ActionType string       `json:"actionType"`
-	Payload    PlayerAction `json:"payload"`
+// forgetGame removes a game from the server.
+func forgetGame(g *game.Game) {
+	gamesMu.Lock()
+	delete(games, g.ID)
+	gamesMu.Unlock()
+	tableSyncsMu.Lock()
+	delete(tableSyncs, g.ID)
+	tableSyncsMu.Unlock()
 }
 
-// PlayerAction
-type PlayerAction struct {
-	GameID         string   `json:"gameId,omitempty"`
-	PlayerName     string   `json:"playerName,omitempty"`
-	CardID         string   `json:"cardId,omitempty"`
-	BoardPos       Position `json:"boardPos"`
-	MaxPlayers     int      `json:"maxPlayers,omitempty"`
-	SequencesToWin int      `json:"sequencesToWin,omitempty"`
+// roomForGame reports whether another game may be opened under the MaxGames
+// limit. The caller must hold gamesMu.
+func roomForGame() bool {
+	return cfg.MaxGames == 0 || len(games) < cfg.MaxGames
 }
 
-// broadcastGameState
-func (g *Game) broadcastGameState(messageType string, specificPayload interface{}) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	type BroadcastPlayer struct {
-		ID          string `json:"id"`
-		Name        string `json:"name"`
-		ChipColor   string `json:"chipColor"`
-		Sequences   int    `json:"sequences"`
-		IsConnected bool   `json:"isConnected"`
-		HandCount   int    `json:"handCount"`
-		IsMyTurn    bool   `json:"isMyTurn"`
-	}
-
-	broadcastPlayers := make(map[string]BroadcastPlayer)
-	for pid, p := range g.Players {
-		isMyTurn := false
-		if g.GamePhase == "InProgress" && len(g.PlayerOrder) > 0 && g.CurrentTurnIndex < len(g.PlayerOrder) {
-			isMyTurn = (g.PlayerOrder[g.CurrentTurnIndex] == pid)
+// closeIdleGames closes every game without a broadcast for IdleGameTimeout,
+// telling any players still connected. Tournament games wait for their players.
+func closeIdleGames(now time.Time) {
+	timeout := time.Duration(cfg.IdleGameTimeout)
+	gamesMu.Lock()
+	var idle []*game.Game
+	for _, g := range games {
+		idle = append(idle, g)
+	}
+	gamesMu.Unlock()
+	for _, g := range idle {
+		if _, isMatch := tournamentStore.MatchPlayers(g.ID); isMatch {
+			continue
 		}
-		broadcastPlayers[pid] = BroadcastPlayer{
-			ID: p.ID, Name: p.Name, ChipColor: p.ChipColor, Sequences: p.Sequences,
-			IsConnected: p.IsConnected, HandCount: len(p.Hand), IsMyTurn: isMyTurn,
-		}
-	}
-
-	currentTurnPlayerID := ""
-	if g.GamePhase == "InProgress" && len(g.PlayerOrder) > 0 && g.CurrentTurnIndex < len(g.PlayerOrder) {
-		currentTurnPlayerID = g.PlayerOrder[g.CurrentTurnIndex]
-	}
-
-	gameStateForBroadcast := struct {
-		Type                string                           `json:"type"`
-		GameID              string                           `json:"gameId"`
-		Board               [BoardSize][BoardSize]BoardSpace `json:"board"`
-		Players             map[string]BroadcastPlayer       `json:"players"`
-		PlayerOrder         []string                         `json:"playerOrder"`
-		CurrentTurnPlayerID string                           `json:"currentTurnPlayerId"`
-		GamePhase           string                           `json:"gamePhase"`
-		Winner              string                           `json:"winner,omitempty"`
-		NumSequencesToWin   int                              `json:"numSequencesToWin"`
-		MaxPlayers          int                              `json:"maxPlayers"`
-		HostID              string                           `json:"hostId"`
-		DrawPileCount       int                              `json:"drawPileCount"`
-		Message             string                           `json:"message,omitempty"`
-		Details             interface{}                      `json:"details,omitempty"`
-	}{
-		Type: messageType, GameID: g.ID, Board: g.Board, Players: broadcastPlayers, PlayerOrder: g.PlayerOrder,
-		CurrentTurnPlayerID: currentTurnPlayerID, GamePhase: g.GamePhase, Winner: g.Winner,
-		NumSequencesToWin: g.NumSequencesToWin, MaxPlayers: g.MaxPlayers, HostID: g.HostID,
-		DrawPileCount: g.DrawPileCount, Details: specificPayload,
-	}
-
-	for playerIDLoop, player := range g.Players {
-		if player.Conn != nil && player.IsConnected {
-			if err := player.Conn.WriteJSON(gameStateForBroadcast); err != nil {
-				log.Printf("Error broadcasting game state to player %s: %v", player.ID, err)
-			}
-
-			visibleHandIDs := make([]string, len(player.Hand))
-			for i, cardInHand := range player.Hand {
-				visibleHandIDs[i] = cardInHand.ID
-			}
-			handMsg := struct {
-				Type string   `json:"type"`
-				Hand []string `json:"hand"`
-			}{Type: "HAND_UPDATE", Hand: visibleHandIDs}
-
-			if player.Conn != nil && player.IsConnected {
-				if err := player.Conn.WriteJSON(handMsg); err != nil {
-					log.Printf("Error sending hand update to player %s (%s): %v", player.Name, playerIDLoop, err)
-				}
-			}
+		g.Lock()
+		active := syncFor(g.ID).active
+		g.Unlock()
+		if active.IsZero() || now.Sub(active) < timeout {
+			continue
 		}
+		log.Printf("Closing game %s after %s without activity.", g.ID, timeout)
+		_ = writeGameLog(g.ID, fmt.Sprintf("Game closed after %s without activity", timeout))
+		broadcastGameState(g, protocol.EventGameUpdate, protocol.NoticeDetail{Message: fmt.Sprintf("This game was closed after %s without activity.", timeout)})
+		forgetGame(g)
 	}
-	log.Printf("Broadcasted game state for game %s, type: %s", g.ID, messageType)
 }
 
-// sendError
-func sendError(conn *websocket.Conn, gameID string, errorMessage string) {
-	errPayload := struct {
-		Type   string `json:"type"`
-		GameID string `json:"gameId,omitempty"`
-		Error  string `json:"error"`
-	}{"ERROR", gameID, errorMessage}
-	if conn != nil {
-		if err := conn.WriteJSON(errPayload); err != nil {
-			log.Printf("Error sending error: %v", err)
-		}
+// runIdleReaper closes idle games every IdleCheckInterval.
+func runIdleReaper() {
+	for now := range time.Tick(IdleCheckInterval) {
+		closeIdleGames(now)
 	}
 }
 
 func handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("Upgrade error:", err)
 		return
 	}
+	conn := &wsConn{Conn: ws}
 	defer conn.Close()
+	defer trackConnection(conn)()
 
 	// Accept PlayerID from client if provided, else generate new
-	var handshake struct {
-		PlayerID string `json:"playerId"`
+	var hello protocol.Hello
+	if err := conn.ReadJSON(&hello); err != nil {
+		log.Printf("Handshake error: %v", err)
+		return
+	}
+	version, errVersion := protocol.Negotiate(hello.ProtocolVersion)
+	if errVersion != nil {
+		sendError(conn, "", "", protocol.CodeUnsupportedVersion, errVersion.Error())
+		return
+	}
+	playerID := hello.PlayerID
+	isBot := hello.Bot
+	var account *accounts.Account // Logged-in account, nil for a guest
+	sessionToken := ""
+	if hello.SessionToken != "" {
+		if resumed, errSession := accountStore.Session(hello.SessionToken); errSession == nil {
+			account, sessionToken, playerID = &resumed, hello.SessionToken, resumed.PlayerID
+		}
+	}
+	if account == nil && playerID != "" && accountStore.OwnsPlayerID(playerID) {
+		playerID = "" // An account's player ID is only for its sessions
 	}
-	conn.ReadJSON(&handshake)
-	playerID := handshake.PlayerID
 	if playerID == "" {
-		playerID = generateID()
+		playerID = game.GenerateID()
+	}
+	welcome := protocol.Welcome{Type: protocol.EventWelcome, PlayerID: playerID, ProtocolVersion: version}
+	if account != nil {
+		welcome.Account = accountEvent(playerID, account, "")
 	}
-	var currentGame *Game
-	var currentPlayer *Player
-	log.Printf("Player %s connected via WebSocket.", playerID)
+	if err := conn.WriteJSON(welcome); err != nil {
+		log.Printf("Error sending welcome to %s: %v", playerID, err)
+		return
+	}
+	var currentGame *game.Game
+	var currentPlayer *game.Player
+	var ticket *matchTicket // Set while waiting in the matchmaking queue
+	// joinMatch moves the connection into the game the matchmaker seated it in
+	joinMatch := func(matched *game.Game) {
+		ticket = nil
+		currentGame = matched
+		matched.Lock()
+		currentPlayer = matched.Players[playerID]
+		matched.Unlock()
+	}
+	// dequeue leaves the matchmaking queue, reporting false if a match was made first
+	dequeue := func() bool {
+		if ticket == nil {
+			return true
+		}
+		if matched := leaveQueue(ticket); matched != nil {
+			joinMatch(matched)
+			return false
+		}
+		ticket = nil
+		return true
+	}
+	// settle acts on the matchmaker's outcome for the ticket
+	settle := func(matched *game.Game, ok bool) {
+		if ok {
+			joinMatch(matched)
+		} else {
+			ticket = nil // Dropped for a newer ticket from another connection
+		}
+	}
+	log.Printf("Player %s connected via WebSocket (protocol v%d).", playerID, version)
+
+	// Messages are read on their own goroutine, so that the connection moves into
+	// a match as soon as the matchmaker seats it rather than at its next message.
+	type read struct {
+		msg protocol.ClientMessage
+		err error
+	}
+	reads := make(chan read)
+	stopReading := make(chan struct{})
+	defer close(stopReading)
+	go func() {
+		for {
+			var r read
+			r.err = conn.ReadJSON(&r.msg)
+			select {
+			case reads <- r:
+			case <-stopReading:
+				return
+			}
+			if r.err != nil {
+				return
+			}
+		}
+	}()
 
 	for {
-		var msg ClientMessage
-		err := conn.ReadJSON(&msg)
+		var msg protocol.ClientMessage
+		var err error
+		select {
+		case matched, ok := <-ticket.outcome():
+			settle(matched, ok)
+			continue
+		case r := <-reads:
+			msg, err = r.msg, r.err
+			select {
+			case matched, ok := <-ticket.outcome(): // Seated while the message was on its way
+				settle(matched, ok)
+			default:
+			}
+		}
 		if err != nil {
+			dequeue()
 			log.Printf("Read error from %s: %v", playerID, err)
 			if currentPlayer != nil && currentGame != nil {
-				currentGame.mu.Lock()
-				if p, ok := currentGame.Players[currentPlayer.ID]; ok {
-					p.IsConnected = false
-					log.Printf("Player %s (%s) disconnected from game %s.", p.Name, playerID, currentGame.ID)
-
-					allDisconnected := true
-					for _, playerInGame := range currentGame.Players {
-						if playerInGame.IsConnected {
-							allDisconnected = false
-							break
-						}
-					}
-					if allDisconnected && currentGame.GamePhase != "Finished" {
-						log.Printf("All players disconnected from game %s. Removing game.", currentGame.ID)
-						gamesMu.Lock()
-						delete(games, currentGame.ID)
-						gamesMu.Unlock()
-					} else {
-						currentGame.broadcastGameState("GAME_UPDATE", map[string]string{"message": fmt.Sprintf("Player %s disconnected", p.Name)})
-					}
-				}
-				currentGame.mu.Unlock()
+				handleDisconnect(currentGame, currentPlayer.ID)
 			}
 			break
 		}
 
-		log.Printf("Received action from %s: %s, Payload: %+v", playerID, msg.ActionType, msg.Payload)
+		if msg.ActionType.Known() {
+			messagesReceived.inc(string(msg.ActionType))
+		} else {
+			messagesReceived.inc("unknown") // Clients pick the action, so only the protocol's own are labelled
+		}
+		logged := string(msg.Payload)
+		if msg.ActionType == protocol.ActionRegister || msg.ActionType == protocol.ActionLogin {
+			logged = "(credentials withheld)"
+		}
+		log.Printf("Received action from %s: %s, Payload: %s", playerID, msg.ActionType, logged)
 		if currentGame != nil {
-			_ = writeGameLog(currentGame.ID, fmt.Sprintf("Action from %s: %s, Payload: %+v", playerID, msg.ActionType, msg.Payload))
+			_ = writeGameLog(currentGame.ID, fmt.Sprintf("Action from %s: %s, Payload: %s", playerID, msg.ActionType, logged))
+		}
+
+		payload, errDecode := msg.Decode()
+		if errDecode != nil {
+			log.Printf("Rejected action from %s: %v", playerID, errDecode)
+			sendError(conn, "", msg.RequestID, protocol.CodeBadRequest, errDecode.Error())
+			continue
 		}
 
-		switch msg.ActionType {
-		case "CREATE_GAME":
+		switch payload.(type) {
+		case *protocol.StartGame, *protocol.Play, *protocol.DeadCard, *protocol.DrawCard:
+			if shuttingDown.Load() {
+				// Games are saved as they stand once the server stops, so they are kept still until then
+				gameID := ""
+				if currentGame != nil {
+					gameID = currentGame.ID
+				}
+				sendError(conn, gameID, msg.RequestID, protocol.CodeShuttingDown, "The server is shutting down; the game carries on once it is back.")
+				continue
+			}
+		}
+
+		switch req := payload.(type) {
+		case *protocol.CreateGame:
+			if !dequeue() {
+				sendError(conn, currentGame.ID, msg.RequestID, protocol.CodeInGame, "A match was found before the game could be created.")
+				continue
+			}
+			rules, errRules := rulesForCreate(req)
+			if errRules != nil {
+				sendError(conn, "", msg.RequestID, protocol.CodeInvalidRules, fmt.Sprintf("Invalid rules: %v", errRules))
+				continue
+			}
+			maxPlayers := req.MaxPlayers
+			if len(req.HotSeat) > 0 {
+				if errSeats := checkHotSeat(req, rules); errSeats != nil {
+					sendError(conn, "", msg.RequestID, protocol.CodeBadRequest, fmt.Sprintf("Invalid hot-seat game: %v", errSeats))
+					continue
+				}
+				maxPlayers = len(req.HotSeat) + 1 // Nobody else can join
+			}
+			if req.Teams > 0 && maxPlayers <= 0 {
+				maxPlayers = 2 * req.Teams
+			}
+			if req.Ranked {
+				if isBot {
+					sendError(conn, "", msg.RequestID, protocol.CodeBadRequest, "Bots cannot play ranked games.")
+					continue
+				}
+				if len(req.HotSeat) > 0 {
+					sendError(conn, "", msg.RequestID, protocol.CodeBadRequest, "A ranked game cannot be played hot-seat.")
+					continue
+				}
+				rules.Hints = false // Ratings measure the players, not the hint engine
+			}
+
+			req.PlayerName = nameFor(req.PlayerName, account)
+			if shuttingDown.Load() {
+				sendError(conn, "", msg.RequestID, protocol.CodeShuttingDown, "The server is shutting down; try again once it is back.")
+				continue
+			}
 			gamesMu.Lock()
-			game := NewGame(playerID, msg.Payload.PlayerName, msg.Payload.MaxPlayers, msg.Payload.SequencesToWin)
-			games[game.ID] = game
+			if !roomForGame() {
+				gamesMu.Unlock()
+				sendError(conn, "", msg.RequestID, protocol.CodeServerFull, "The server is running as many games as it can; try again later.")
+				continue
+			}
+			newGame := game.NewGame(playerID, req.PlayerName, maxPlayers, 0, rules)
+			newGame.HotSeat = len(req.HotSeat) > 0
+			newGame.Ranked = req.Ranked
+			if errTeams := newGame.SetTeams(req.Teams); errTeams != nil {
+				gamesMu.Unlock()
+				sendError(conn, "", msg.RequestID, protocol.CodeBadRequest, fmt.Sprintf("Invalid teams: %v", errTeams))
+				continue
+			}
+			games[newGame.ID] = newGame
 			gamesMu.Unlock()
-			currentGame = game
+			currentGame = newGame
 
-			player, errAdd := currentGame.AddPlayer(playerID, msg.Payload.PlayerName, conn)
+			player, errAdd := currentGame.AddPlayer(playerID, req.PlayerName, conn)
 			if errAdd != nil {
-				sendError(conn, game.ID, fmt.Sprintf("Failed to add host to game: %v", errAdd))
+				sendError(conn, newGame.ID, msg.RequestID, protocol.CodeOf(errAdd), fmt.Sprintf("Failed to add host to game: %v", errAdd))
 				gamesMu.Lock()
-				delete(games, game.ID)
+				delete(games, newGame.ID)
 				gamesMu.Unlock()
 				return
 			}
 			currentPlayer = player
+			applyProfile(currentGame, playerID, account)
+			if isBot {
+				markBot(currentGame, playerID)
+			}
+			for i, seatName := range req.HotSeat {
+				// Seats have no connection of their own; the host's device plays them
+				if _, errSeat := currentGame.AddPlayer(fmt.Sprintf("%s-seat%d", playerID, i+2), seatName, nil); errSeat != nil {
+					log.Printf("Failed to seat hot-seat player %s in game %s: %v", seatName, currentGame.ID, errSeat)
+				}
+			}
 			log.Printf("Player %s (%s) created game %s as host.", currentPlayer.Name, playerID, currentGame.ID)
 			_ = writeGameLog(currentGame.ID, fmt.Sprintf("Player %s (%s) created the game as host", currentPlayer.Name, playerID))
-			currentGame.broadcastGameState("GAME_CREATED", nil)
+			broadcastGameState(currentGame, protocol.EventGameCreated, nil)
 
-		case "JOIN_GAME":
+		case *protocol.JoinGame:
+			if !dequeue() {
+				sendError(conn, currentGame.ID, msg.RequestID, protocol.CodeInGame, "A match was found before the game could be joined.")
+				continue
+			}
 			gamesMu.Lock()
-			game, exists := games[msg.Payload.GameID]
+			joinedGame, exists := games[req.GameID]
 			gamesMu.Unlock()
 			if !exists {
-				sendError(conn, msg.Payload.GameID, "Game not found.")
+				sendError(conn, req.GameID, msg.RequestID, protocol.CodeGameNotFound, "Game not found.")
+				continue
+			}
+			if joinedGame.HotSeat && playerID != joinedGame.HostID {
+				sendError(conn, req.GameID, msg.RequestID, game.CodeGameNotJoinable, "This is a hot-seat game played on a single device.")
+				continue
+			}
+			if joinedGame.Ranked && isBot {
+				sendError(conn, req.GameID, msg.RequestID, game.CodeGameNotJoinable, "Bots cannot join ranked games.")
+				continue
+			}
+			if errSeat := checkMatchSeat(joinedGame, playerID); errSeat != nil {
+				sendError(conn, req.GameID, msg.RequestID, game.CodeGameNotJoinable, fmt.Sprintf("Failed to join game: %v", errSeat))
 				continue
 			}
 
-			currentGame = game
-			player, errAdd := currentGame.AddPlayer(playerID, msg.Payload.PlayerName, conn)
+			currentGame = joinedGame
+			player, errAdd := currentGame.AddPlayer(playerID, nameFor(req.PlayerName, account), conn)
 			if errAdd != nil {
-				sendError(conn, currentGame.ID, fmt.Sprintf("Failed to join game: %v", errAdd))
+				sendError(conn, currentGame.ID, msg.RequestID, protocol.CodeOf(errAdd), fmt.Sprintf("Failed to join game: %v", errAdd))
 				currentGame = nil
 				continue
 			}
 			currentPlayer = player
+			applyProfile(currentGame, playerID, account)
+			if isBot {
+				markBot(currentGame, playerID)
+			}
+			if currentGame.HotSeat {
+				currentGame.Lock()
+				setHotSeatsConnected(currentGame, true)
+				currentGame.Unlock()
+			}
+			requestSnapshot(currentGame, currentPlayer.ID)
 			log.Printf("Player %s (%s) joined game %s.", currentPlayer.Name, playerID, currentGame.ID)
 			_ = writeGameLog(currentGame.ID, fmt.Sprintf("Player %s (%s) joined the game", currentPlayer.Name, playerID))
-			currentGame.broadcastGameState("PLAYER_JOINED", map[string]string{"playerName": currentPlayer.Name, "playerId": currentPlayer.ID})
+			broadcastGameState(currentGame, protocol.EventPlayerJoined, protocol.PlayerJoinedDetail{PlayerName: currentPlayer.Name, PlayerID: currentPlayer.ID})
+			startMatchWhenSeated(currentGame)
 
-		case "START_GAME":
+		case *protocol.StartGame:
 			if currentGame == nil {
-				sendError(conn, "", "Not in a game.")
+				sendError(conn, "", msg.RequestID, protocol.CodeNotInGame, "Not in a game.")
 				continue
 			}
 			if currentPlayer == nil || currentGame.HostID != currentPlayer.ID {
-				sendError(conn, currentGame.ID, "Only the host can start the game.")
+				sendError(conn, currentGame.ID, msg.RequestID, game.CodeNotHost, "Only the host can start the game.")
 				continue
 			}
 			if errS := currentGame.StartGame(currentPlayer.ID); errS != nil {
-				sendError(conn, currentGame.ID, fmt.Sprintf("Failed to start game: %v", errS))
+				sendError(conn, currentGame.ID, msg.RequestID, protocol.CodeOf(errS), fmt.Sprintf("Failed to start game: %v", errS))
 				continue
 			}
 			log.Printf("Game %s started by host %s.", currentGame.ID, currentPlayer.Name)
 			_ = writeGameLog(currentGame.ID, fmt.Sprintf("Game started by host %s (%s)", currentPlayer.Name, currentPlayer.ID))
-			currentGame.broadcastGameState("GAME_STARTED", nil)
+			broadcastGameState(currentGame, protocol.EventGameStarted, nil)
 
-		case "PLAY_ACTION":
+		case *protocol.Play:
 			if currentGame == nil || currentPlayer == nil {
-				sendError(conn, "", "Not in active game.")
+				sendError(conn, "", msg.RequestID, protocol.CodeNotInGame, "Not in active game.")
 				continue
 			}
 
-			errPlay := currentGame.PlayAction(currentPlayer.ID, msg.Payload)
+			seat, handedOver := seatFor(currentGame, currentPlayer)
+			if !handedOver {
+				sendError(conn, currentGame.ID, msg.RequestID, protocol.CodeHandHidden, "Pass the device to the next player first.")
+				continue
+			}
+			var before *game.Game
+			if wantsAnalysis(currentGame, currentPlayer.ID) {
+				before = currentGame.Clone()
+			}
+			errPlay := currentGame.PlayAction(seat.ID, req.PlayerAction)
 			if errPlay != nil {
-				sendError(conn, currentGame.ID, fmt.Sprintf("Invalid action: %v", errPlay))
-				currentGame.broadcastGameState("GAME_UPDATE", map[string]string{"error": errPlay.Error()})
+				sendError(conn, currentGame.ID, msg.RequestID, protocol.CodeOf(errPlay), fmt.Sprintf("Invalid action: %v", errPlay))
 				continue
 			}
+			currentGame.Lock()
+			drawNow := currentGame.HotSeat && currentGame.PendingDraw == seat.ID
+			currentGame.Unlock()
+			if drawNow {
+				// The device changes hands right after a play, so hot-seat players draw at once
+				if errDraw := currentGame.HandleDraw(seat.ID); errDraw != nil {
+					log.Printf("Hot-seat draw for %s in game %s failed: %v", seat.ID, currentGame.ID, errDraw)
+				}
+			}
 
-			var playedCardDisplay string
-			parsedPlayedCard, parseErr := parseCardID(msg.Payload.CardID)
-			if parseErr == nil {
-				playedCardDisplay = parsedPlayedCard.ToEmojiString()
-			} else {
-				playedCardDisplay = msg.Payload.CardID
+			broadcastGameState(currentGame, protocol.EventGameUpdate, playDetail(currentGame, seat.Name, req.PlayerAction))
+			scheduleAutoDraw(currentGame, seat.ID)
+			if before != nil {
+				go sendAnalysis(currentGame, currentPlayer.ID, seat.ID, before, req.PlayerAction)
+			}
+			currentGame.Lock()
+			finished, winner := currentGame.GamePhase == "Finished", currentGame.Winner
+			currentGame.Unlock()
+			if finished {
+				log.Printf("Game %s finished. Winner: %s", currentGame.ID, winner)
+				_ = writeGameLog(currentGame.ID, fmt.Sprintf("Game finished. Winner: %s", winner))
 			}
 
-			detail := map[string]interface{}{
-				"action": "PLAY_ACTION", "player": currentPlayer.Name,
-				"cardPlayedDisplay": playedCardDisplay,
-				"cardPlayedID":      msg.Payload.CardID,
-				"pos":               msg.Payload.BoardPos,
+		case *protocol.DeadCard:
+			if currentGame == nil || currentPlayer == nil {
+				sendError(conn, "", msg.RequestID, protocol.CodeNotInGame, "Not in active game.")
+				continue
 			}
-			if parsedPlayedCard != nil && parsedPlayedCard.Rank == Jack && (parsedPlayedCard.Suit == Clubs || parsedPlayedCard.Suit == Spades) {
-				detail["removedChipAt"] = msg.Payload.BoardPos
+
+			seat, handedOver := seatFor(currentGame, currentPlayer)
+			if !handedOver {
+				sendError(conn, currentGame.ID, msg.RequestID, protocol.CodeHandHidden, "Pass the device to the next player first.")
+				continue
 			}
-			currentGame.broadcastGameState("GAME_UPDATE", detail)
-			if currentGame.GamePhase == "Finished" {
-				log.Printf("Game %s finished. Winner: %s", currentGame.ID, currentGame.Winner)
-				_ = writeGameLog(currentGame.ID, fmt.Sprintf("Game finished. Winner: %s", currentGame.Winner))
+			errDead := currentGame.HandleDeadCard(seat.ID, req.CardID)
+			if errDead != nil {
+				sendError(conn, currentGame.ID, msg.RequestID, protocol.CodeOf(errDead), fmt.Sprintf("Invalid dead card: %v", errDead))
+				continue
 			}
+			broadcastGameState(currentGame, protocol.EventGameUpdate, protocol.DeadCardDetail{
+				Action: protocol.ActionDeadCard, Player: seat.Name, CardDeclaredDeadID: req.CardID,
+			})
 
-		case "DEAD_CARD":
+		case *protocol.DrawCard:
 			if currentGame == nil || currentPlayer == nil {
-				sendError(conn, "", "Not in active game.")
+				sendError(conn, "", msg.RequestID, protocol.CodeNotInGame, "Not in active game.")
 				continue
 			}
 
-			errDead := currentGame.HandleDeadCard(currentPlayer.ID, msg.Payload.CardID)
-			if errDead != nil {
-				sendError(conn, currentGame.ID, fmt.Sprintf("Invalid dead card: %v", errDead))
-				currentGame.broadcastGameState("GAME_UPDATE", map[string]string{"error": errDead.Error()})
+			if errDraw := currentGame.HandleDraw(currentPlayer.ID); errDraw != nil {
+				sendError(conn, currentGame.ID, msg.RequestID, protocol.CodeOf(errDraw), fmt.Sprintf("Cannot draw: %v", errDraw))
+				continue
+			}
+			broadcastGameState(currentGame, protocol.EventGameUpdate, protocol.DrawDetail{Action: string(protocol.ActionDrawCard), Player: currentPlayer.Name})
+
+		case *protocol.Resync:
+			if currentGame == nil || currentPlayer == nil {
+				sendError(conn, "", msg.RequestID, protocol.CodeNotInGame, "Not in a game.")
+				continue
+			}
+			sendSnapshot(currentGame, currentPlayer.ID)
+
+		case *protocol.RevealHand:
+			if currentGame == nil || currentPlayer == nil {
+				sendError(conn, "", msg.RequestID, protocol.CodeNotInGame, "Not in a game.")
+				continue
+			}
+			if errReveal := revealHand(currentGame, currentPlayer, req.PlayerID); errReveal != nil {
+				sendError(conn, currentGame.ID, msg.RequestID, protocol.CodeOf(errReveal), fmt.Sprintf("Cannot reveal hand: %v", errReveal))
+			}
+
+		case *protocol.RequestHint:
+			if currentGame == nil || currentPlayer == nil {
+				sendError(conn, "", msg.RequestID, protocol.CodeNotInGame, "Not in a game.")
 				continue
 			}
-			detail := map[string]interface{}{
-				"action": "DEAD_CARD", "player": currentPlayer.Name,
-				"cardDeclaredDeadID": msg.Payload.CardID,
+			if !currentGame.Rules.Hints {
+				sendError(conn, currentGame.ID, msg.RequestID, protocol.CodeHintsDisabled, "Hints are disabled in this game.")
+				continue
+			}
+			seat, handedOver := seatFor(currentGame, currentPlayer)
+			if !handedOver {
+				sendError(conn, currentGame.ID, msg.RequestID, protocol.CodeHandHidden, "Pass the device to the next player first.")
+				continue
 			}
-			currentGame.broadcastGameState("GAME_UPDATE", detail)
+			if currentGame.CurrentPlayerID() != seat.ID {
+				sendError(conn, currentGame.ID, msg.RequestID, game.CodeNotYourTurn, "Hints are only given on your turn.")
+				continue
+			}
+			sendHint(currentGame, conn, seat)
 
-		default:
-			log.Printf("Unknown action: %s from %s", msg.ActionType, playerID)
-			sendError(conn, "", fmt.Sprintf("Unknown action: %s", msg.ActionType))
+		case *protocol.SetAnalysis:
+			if currentGame == nil || currentPlayer == nil {
+				sendError(conn, "", msg.RequestID, protocol.CodeNotInGame, "Not in a game.")
+				continue
+			}
+			if errAnalysis := setAnalysis(currentGame, currentPlayer.ID, req.Enabled); errAnalysis != nil {
+				sendError(conn, currentGame.ID, msg.RequestID, protocol.CodeHintsDisabled, errAnalysis.Error())
+			}
+
+		case *protocol.Register, *protocol.Login:
+			if currentGame != nil {
+				sendError(conn, currentGame.ID, msg.RequestID, protocol.CodeInGame, "Log in before creating or joining a game.")
+				continue
+			}
+			if ticket != nil {
+				sendError(conn, "", msg.RequestID, protocol.CodeInGame, "Log in before queueing for a match.")
+				continue
+			}
+			var loggedIn accounts.Account
+			var token string
+			var errAccount error
+			if register, ok := req.(*protocol.Register); ok {
+				var profile accounts.Profile
+				if register.Profile != nil {
+					profile = *register.Profile
+				}
+				loggedIn, token, errAccount = accountStore.Register(register.Username, register.Password, profile)
+			} else {
+				login := req.(*protocol.Login)
+				loggedIn, token, errAccount = accountStore.Login(login.Username, login.Password)
+			}
+			if errAccount != nil {
+				sendError(conn, "", msg.RequestID, protocol.CodeOf(errAccount), errAccount.Error())
+				continue
+			}
+			log.Printf("Connection of %s logged in as %s (%s).", playerID, loggedIn.Username, loggedIn.PlayerID)
+			account, sessionToken, playerID = &loggedIn, token, loggedIn.PlayerID
+			sendAccount(conn, playerID, account, sessionToken)
+
+		case *protocol.Logout:
+			if account == nil {
+				sendError(conn, "", msg.RequestID, protocol.CodeNotLoggedIn, "Not logged in.")
+				continue
+			}
+			if currentGame != nil {
+				sendError(conn, currentGame.ID, msg.RequestID, protocol.CodeInGame, "Cannot log out during a game.")
+				continue
+			}
+			if ticket != nil {
+				sendError(conn, "", msg.RequestID, protocol.CodeInGame, "Cannot log out while queued for a match.")
+				continue
+			}
+			if errLogout := accountStore.Logout(sessionToken); errLogout != nil {
+				log.Printf("Logout of %s failed: %v", account.Username, errLogout)
+			}
+			account, sessionToken, playerID = nil, "", game.GenerateID()
+			sendAccount(conn, playerID, nil, "")
+
+		case *protocol.UpdateProfile:
+			if account == nil {
+				sendError(conn, "", msg.RequestID, protocol.CodeNotLoggedIn, "Log in to keep a profile.")
+				continue
+			}
+			updated, errProfile := accountStore.UpdateProfile(sessionToken, req.Profile)
+			if errProfile != nil {
+				sendError(conn, "", msg.RequestID, protocol.CodeOf(errProfile), errProfile.Error())
+				continue
+			}
+			account = &updated
+			sendAccount(conn, playerID, account, "")
+
+		case *protocol.QueueForMatch:
+			if shuttingDown.Load() {
+				sendError(conn, "", msg.RequestID, protocol.CodeShuttingDown, "The server is shutting down; try again once it is back.")
+				continue
+			}
+			if protocol.MatchPlayers(req.Mode) == 0 {
+				sendError(conn, "", msg.RequestID, protocol.CodeModeUnavailable,
+					fmt.Sprintf("Cannot match %q games; queue for %q, %q, %q or %q.", req.Mode, protocol.MatchDuel, protocol.MatchFreeForAll, protocol.MatchTwoVsTwo, protocol.MatchThreeTeams))
+				continue
+			}
+			if req.Ranked && isBot {
+				sendError(conn, "", msg.RequestID, protocol.CodeBadRequest, "Bots cannot queue for ranked games.")
+				continue
+			}
+			if req.RatingRange < 0 {
+				sendError(conn, "", msg.RequestID, protocol.CodeBadRequest, "The rating range cannot be negative.")
+				continue
+			}
+			if currentGame != nil {
+				currentGame.Lock()
+				finished := currentGame.GamePhase == "Finished"
+				currentGame.Unlock()
+				if !finished {
+					sendError(conn, currentGame.ID, msg.RequestID, protocol.CodeInGame, "Finish the current game before queueing for a match.")
+					continue
+				}
+			}
+			if !dequeue() {
+				sendError(conn, currentGame.ID, msg.RequestID, protocol.CodeInGame, "A match was already found.")
+				continue
+			}
+			ticket = queueForMatch(conn, playerID, account, isBot, req)
+
+		case *protocol.LeaveQueue:
+			if ticket == nil {
+				sendError(conn, "", msg.RequestID, protocol.CodeNotQueued, "Not queued for a match.")
+				continue
+			}
+			left := ticket
+			if !dequeue() {
+				sendError(conn, currentGame.ID, msg.RequestID, protocol.CodeInGame, "A match was already found.")
+				continue
+			}
+			sendQueueLeft(conn, left)
 		}
 	}
 }
 
-// serveClient
-func serveClient(w http.ResponseWriter, r *http.Request) {
-	htmlFilePath := filepath.Join(StaticDir, ClientHTMLFile)
-	if _, err := os.Stat(htmlFilePath); os.IsNotExist(err) {
-		log.Printf("HTML client file not found at %s", htmlFilePath)
-		http.Error(w, "HTML client not found", http.StatusNotFound)
+// serveSchema serves the generated JSON Schema of the WebSocket protocol
+func serveSchema(w http.ResponseWriter, r *http.Request) {
+	schema, err := protocol.SchemaJSON()
+	if err != nil {
+		http.Error(w, "Failed to build protocol schema", http.StatusInternalServerError)
 		return
 	}
-	log.Printf("Serving client HTML from: %s", htmlFilePath)
-	http.ServeFile(w, r, htmlFilePath)
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(schema)
 }
 
 func main() {
-	if _, err := os.Stat(StaticDir); os.IsNotExist(err) {
-		if err := os.MkdirAll(StaticDir, 0o755); err != nil {
-			log.Fatalf("Failed to create static dir %s: %v", StaticDir, err)
+	loaded, printOnly, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	cfg = loaded
+	if printOnly {
+		if err := printConfig(cfg); err != nil {
+			log.Fatal(err)
 		}
-		log.Printf("Created static dir: %s. Place '%s' there.", StaticDir, ClientHTMLFile)
+		return
+	}
+
+	if assets, err = openAssets(cfg.StaticDir, cfg.RequireVendored); err != nil {
+		log.Fatalf("Failed to open the web client: %v", err)
+	}
+	if assets.embedded {
+		log.Printf("Serving the embedded web client (assets version %s).", assets.version)
+	} else {
+		log.Printf("Serving the web client from %s.", cfg.StaticDir)
+	}
+	if accountStore, err = accounts.Open(cfg.dataFile(AccountsFile)); err != nil {
+		log.Fatalf("Failed to load accounts from %s: %v", cfg.dataFile(AccountsFile), err)
+	}
+	if historyStore, err = history.Open(cfg.dataFile(HistoryFile)); err != nil {
+		log.Fatalf("Failed to load match history from %s: %v", cfg.dataFile(HistoryFile), err)
+	}
+	if ratingStore, err = ratings.Open(cfg.dataFile(RatingsFile)); err != nil {
+		log.Fatalf("Failed to load ratings from %s: %v", cfg.dataFile(RatingsFile), err)
+	}
+	if tournamentStore, err = tournament.Open(cfg.dataFile(TournamentsFile)); err != nil {
+		log.Fatalf("Failed to load tournaments from %s: %v", cfg.dataFile(TournamentsFile), err)
+	}
+	if cfg.Storage == StorageMemory {
+		log.Printf("Using memory storage: accounts, history, ratings and tournaments are lost when the server stops.")
+	}
+	restoreSnapshots()
+	resumeTournaments()
+	go runMatchmaker()
+	if cfg.IdleGameTimeout > 0 {
+		go runIdleReaper()
 	}
 	http.HandleFunc("/ws", handleWebSocket)
+	http.HandleFunc("/protocol/schema.json", serveSchema)
+	http.HandleFunc("GET /healthz", serveHealth)
+	http.HandleFunc("GET /readyz", serveReady)
+	http.HandleFunc("GET /metrics", serveMetrics)
+	http.HandleFunc("GET /api/players/{id}/stats", servePlayerStats)
+	http.HandleFunc("GET /api/games/{id}/summary", serveGameSummary)
+	http.HandleFunc("GET /api/leaderboard", serveLeaderboard)
+	http.HandleFunc("GET /api/tournaments", serveTournaments)
+	http.HandleFunc("POST /api/tournaments", createTournament)
+	http.HandleFunc("GET /api/tournaments/{id}", serveTournament)
+	http.HandleFunc("GET /api/tournaments/{id}/standings", serveStandings)
+	http.HandleFunc("POST /api/tournaments/{id}/entrants", registerEntrant)
+	http.HandleFunc("POST /api/tournaments/{id}/start", startTournament)
+	http.HandleFunc("POST /api/tournaments/{id}/matches/{match}/result", reportResult)
+	http.HandleFunc("GET /tournaments/{id}", serveSpectator)
+	http.HandleFunc("GET /assets/{version}/{file...}", assets.serveAsset)
 	http.HandleFunc("/", serveClient)
-	port := "8008"
-	log.Printf("Server starting on :%s. WebSocket: /ws, Client: /", port)
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		log.Fatal("ListenAndServe:", err)
-	}
+	srv := &http.Server{Addr: cfg.Addr}
+	go func() {
+		var err error
+		if cfg.TLSCert != "" {
+			log.Printf("Server starting on %s with TLS. WebSocket: /ws, Client: /", cfg.Addr)
+			err = srv.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+		} else {
+			log.Printf("Server starting on %s. WebSocket: /ws, Client: /", cfg.Addr)
+			err = srv.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("ListenAndServe:", err)
+		}
+	}()
+
+	stop, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-stop.Done()
+	cancel() // A second signal kills the server at once
+	shutdown(srv)
+	log.Printf("Server stopped.")
 }
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"sequence-game/accounts"
+	"sequence-game/game"
+	"sequence-game/protocol"
+	"sequence-game/ratings"
+)
+
+// --- Matchmaking ---
+
+// matchTicket is a connection waiting in the matchmaking queue.
+type matchTicket struct {
+	playerID    string
+	name        string
+	account     *accounts.Account
+	bot         bool
+	conn        *wsConn
+	mode        protocol.MatchMode
+	ranked      bool
+	rating      float64
+	ratingRange float64 // Zero accepts anyone
+	queuedAt    time.Time
+	matched     chan *game.Game // Receives the game once the matchmaker has seated the player; closed if the ticket is dropped
+}
+
+var (
+	matchQueue   []*matchTicket // Oldest first
+	matchQueueMu sync.Mutex
+)
+
+// ladderFor is the rating ladder of a match mode.
+func ladderFor(mode protocol.MatchMode) ratings.Mode {
+	switch {
+	case protocol.MatchTeams(mode) == 2:
+		return ratings.ModeTwoTeams
+	case protocol.MatchTeams(mode) == 3:
+		return ratings.ModeThreeTeams
+	case protocol.MatchPlayers(mode) == 2:
+		return ratings.ModeDuel
+	}
+	return ratings.ModeFreeForAll
+}
+
+// acceptsGap reports whether the ticket accepts an opponent rated gap away from it
+// at now. The accepted gap widens by MatchRangeStep every MatchRangeEvery.
+func (t *matchTicket) acceptsGap(gap float64, now time.Time) bool {
+	if t.ratingRange <= 0 {
+		return true
+	}
+	steps := math.Floor(float64(now.Sub(t.queuedAt)) / float64(MatchRangeEvery))
+	return gap <= t.ratingRange+steps*MatchRangeStep
+}
+
+// compatible reports whether two tickets can be seated at the same table.
+func (t *matchTicket) compatible(other *matchTicket, now time.Time) bool {
+	if t.playerID == other.playerID || t.mode != other.mode || t.ranked != other.ranked {
+		return false
+	}
+	gap := math.Abs(t.rating - other.rating)
+	return t.acceptsGap(gap, now) && other.acceptsGap(gap, now)
+}
+
+// status describes the ticket for a QUEUE_STATUS. The caller must hold matchQueueMu.
+func (t *matchTicket) status(state protocol.QueueState) protocol.QueueStatus {
+	waiting := 0
+	for _, queued := range matchQueue {
+		if queued.mode == t.mode && queued.ranked == t.ranked {
+			waiting++
+		}
+	}
+	return protocol.QueueStatus{
+		Type: protocol.EventQueueStatus, Status: state, Mode: t.mode, Ranked: t.ranked,
+		Rating: t.rating, RatingRange: t.ratingRange, Waiting: waiting,
+	}
+}
+
+// queueForMatch adds a ticket for the request to the queue and tells the player.
+// A ticket the player holds from another connection is dropped, and that
+// connection told it has left the queue, so nobody is matched twice.
+func queueForMatch(conn *wsConn, playerID string, account *accounts.Account, bot bool, req *protocol.QueueForMatch) *matchTicket {
+	t := &matchTicket{
+		playerID: playerID, name: nameFor(req.PlayerName, account), account: account, bot: bot, conn: conn,
+		mode: req.Mode, ranked: req.Ranked, ratingRange: req.RatingRange,
+		rating: ratingStore.Rating(ladderFor(req.Mode), playerID), queuedAt: time.Now(),
+		matched: make(chan *game.Game, 1),
+	}
+	matchQueueMu.Lock()
+	var dropped []*matchTicket
+	remaining := matchQueue[:0]
+	for _, queued := range matchQueue {
+		if queued.playerID == playerID {
+			dropped = append(dropped, queued)
+			close(queued.matched)
+			continue
+		}
+		remaining = append(remaining, queued)
+	}
+	matchQueue = append(remaining, t)
+	status := t.status(protocol.QueueWaiting)
+	left := make([]protocol.QueueStatus, len(dropped))
+	for i, old := range dropped {
+		left[i] = old.status(protocol.QueueLeft)
+	}
+	matchQueueMu.Unlock()
+
+	for i, old := range dropped {
+		log.Printf("Dropped an older ticket of player %s, who queued again from another connection.", playerID)
+		if err := old.conn.WriteJSON(left[i]); err != nil {
+			log.Printf("Error sending queue status to %s: %v", playerID, err)
+		}
+	}
+	log.Printf("Player %s (%s) queued for a %s match (ranked: %t, rating %.0f).", t.name, playerID, t.mode, t.ranked, t.rating)
+	if err := conn.WriteJSON(status); err != nil {
+		log.Printf("Error sending queue status to %s: %v", playerID, err)
+	}
+	return t
+}
+
+// leaveQueue takes the ticket out of the queue and returns nil, or, if the
+// matchmaker got to it first, waits for the game the player was seated in and
+// returns that instead. A dropped ticket has left already.
+func leaveQueue(t *matchTicket) *game.Game {
+	matchQueueMu.Lock()
+	for i, queued := range matchQueue {
+		if queued == t {
+			matchQueue = append(matchQueue[:i], matchQueue[i+1:]...)
+			matchQueueMu.Unlock()
+			return nil
+		}
+	}
+	matchQueueMu.Unlock()
+	return <-t.matched
+}
+
+// sendQueueLeft tells the player they have left the queue.
+func sendQueueLeft(conn *wsConn, t *matchTicket) {
+	matchQueueMu.Lock()
+	status := t.status(protocol.QueueLeft)
+	matchQueueMu.Unlock()
+	if err := conn.WriteJSON(status); err != nil {
+		log.Printf("Error sending queue status to %s: %v", t.playerID, err)
+	}
+}
+
+// outcome delivers the game the ticket is seated in, or is closed if the ticket
+// is dropped. A nil ticket's never delivers.
+func (t *matchTicket) outcome() <-chan *game.Game {
+	if t == nil {
+		return nil
+	}
+	return t.matched
+}
+
+// runMatchmaker groups queued players into games every MatchmakingInterval.
+func runMatchmaker() {
+	for range time.Tick(MatchmakingInterval) {
+		if shuttingDown.Load() {
+			continue
+		}
+		room := -1
+		gamesMu.Lock()
+		if cfg.MaxGames > 0 {
+			room = max(cfg.MaxGames-len(games), 0)
+		}
+		gamesMu.Unlock()
+		for _, group := range formMatches(time.Now(), room) {
+			startMatch(group)
+		}
+	}
+}
+
+// formMatches takes full tables of compatible players out of the queue, the
+// longest waiting first, up to room tables (any number when room is negative).
+func formMatches(now time.Time, room int) [][]*matchTicket {
+	matchQueueMu.Lock()
+	defer matchQueueMu.Unlock()
+	var groups [][]*matchTicket
+	taken := make(map[*matchTicket]bool)
+	for i, first := range matchQueue {
+		if len(groups) == room {
+			break
+		}
+		if taken[first] {
+			continue
+		}
+		group := []*matchTicket{first}
+		size := protocol.MatchPlayers(first.mode)
+		for _, candidate := range matchQueue[i+1:] {
+			if len(group) == size {
+				break
+			}
+			if taken[candidate] {
+				continue
+			}
+			fits := true
+			for _, seated := range group {
+				fits = fits && seated.compatible(candidate, now)
+			}
+			if fits {
+				group = append(group, candidate)
+			}
+		}
+		if len(group) < size {
+			continue
+		}
+		for _, t := range group {
+			taken[t] = true
+		}
+		groups = append(groups, group)
+	}
+
+	remaining := matchQueue[:0]
+	for _, t := range matchQueue {
+		if !taken[t] {
+			remaining = append(remaining, t)
+		}
+	}
+	matchQueue = remaining
+	return groups
+}
+
+// startMatch creates a game for a matched group, seats everyone and starts it.
+func startMatch(group []*matchTicket) {
+	host := group[0]
+	rules, _ := cfg.defaultRules()
+	if host.ranked {
+		rules.Hints = false
+	}
+	gamesMu.Lock()
+	g := game.NewGame(host.playerID, host.name, len(group), 0, rules)
+	g.Ranked = host.ranked
+	if err := g.SetTeams(protocol.MatchTeams(host.mode)); err != nil {
+		log.Printf("Failed to set up teams for a %s match: %v", host.mode, err)
+	}
+	games[g.ID] = g
+	gamesMu.Unlock()
+
+	for _, t := range group {
+		if _, err := g.AddPlayer(t.playerID, t.name, t.conn); err != nil {
+			log.Printf("Failed to seat matched player %s in game %s: %v", t.playerID, g.ID, err)
+			continue
+		}
+		applyProfile(g, t.playerID, t.account)
+		if t.bot {
+			markBot(g, t.playerID)
+		}
+		_ = writeGameLog(g.ID, fmt.Sprintf("Player %s (%s) was seated by the matchmaker", t.name, t.playerID))
+	}
+	// Hand the game over before anyone hears of it, so their next request finds it
+	for _, t := range group {
+		t.matched <- g
+	}
+	log.Printf("Matchmaker created game %s (%s, ranked: %t) for %d players.", g.ID, host.mode, host.ranked, len(group))
+	broadcastGameState(g, protocol.EventGameCreated, nil)
+	if err := g.StartGame(host.playerID); err != nil {
+		log.Printf("Failed to start matched game %s: %v", g.ID, err)
+		return
+	}
+	_ = writeGameLog(g.ID, "Game started by the matchmaker")
+	broadcastGameState(g, protocol.EventGameStarted, nil)
+
+	g.Lock()
+	defer g.Unlock()
+	for _, t := range group {
+		status := protocol.QueueStatus{
+			Type: protocol.EventQueueStatus, Status: protocol.QueueMatched, Mode: t.mode, Ranked: t.ranked,
+			Rating: t.rating, RatingRange: t.ratingRange, GameID: g.ID,
+		}
+		if err := t.conn.WriteJSON(status); err != nil {
+			log.Printf("Error sending queue status to %s: %v", t.playerID, err)
+		}
+	}
+}
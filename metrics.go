@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"sequence-game/game"
+)
+
+// --- Metrics & Health Checks ---
+
+// Metrics are exposed at /metrics in the Prometheus text format. Counters and
+// histograms are kept as the server runs; gauges are read when scraped.
+var (
+	messagesReceived   = newCounterVec() // WebSocket messages, by action
+	actionsRejected    = newCounterVec() // ERRORs sent, by code
+	drawPilesExhausted = newCounterVec() // Draw piles run out, by exhaustion policy
+	broadcastSeconds   = newHistogram(0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1)
+	gameSeconds        = newHistogram(60, 300, 600, 900, 1200, 1800, 2700, 3600, 5400, 7200)
+)
+
+// gamePhases are the phases games are counted by, each reported even when empty.
+var gamePhases = []string{"Lobby", "InProgress", "Finished"}
+
+// counterVec is a counter with one label.
+type counterVec struct {
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+func newCounterVec() *counterVec {
+	return &counterVec{values: make(map[string]uint64)}
+}
+
+func (c *counterVec) inc(label string) {
+	c.mu.Lock()
+	c.values[label]++
+	c.mu.Unlock()
+}
+
+// histogram counts observations into buckets by their upper bounds.
+type histogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []uint64 // Per bucket, not cumulative; the last is +Inf
+	sum    float64
+}
+
+func newHistogram(bounds ...float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	i, _ := slices.BinarySearch(h.bounds, v)
+	h.counts[i]++
+	h.sum += v
+}
+
+// writeCounter writes a counter with one series per label value.
+func writeCounter(b *bytes.Buffer, name, help, label string, c *counterVec) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		fmt.Fprintf(b, "%s{%s=\"%s\"} %d\n", name, label, labelValue(k), c.values[k])
+	}
+}
+
+// writeHistogram writes a histogram's cumulative buckets, sum and count.
+func writeHistogram(b *bytes.Buffer, name, help string, h *histogram) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var total uint64
+	for i, bound := range h.bounds {
+		total += h.counts[i]
+		fmt.Fprintf(b, "%s_bucket{le=\"%g\"} %d\n", name, bound, total)
+	}
+	total += h.counts[len(h.bounds)]
+	fmt.Fprintf(b, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %g\n%s_count %d\n", name, total, name, h.sum, name, total)
+}
+
+// labelValue escapes a label value for the text format.
+func labelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// serveMetrics answers GET /metrics.
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	gamesMu.Lock()
+	open := make([]*game.Game, 0, len(games))
+	for _, g := range games {
+		open = append(open, g)
+	}
+	gamesMu.Unlock()
+	phases := make(map[string]int)
+	for _, g := range open {
+		g.Lock()
+		phases[g.GamePhase]++
+		g.Unlock()
+	}
+	connectionsMu.Lock()
+	conns := len(connections)
+	connectionsMu.Unlock()
+
+	var b bytes.Buffer
+	b.WriteString("# HELP sequence_games Games open on the server, by phase.\n# TYPE sequence_games gauge\n")
+	for _, phase := range gamePhases {
+		fmt.Fprintf(&b, "sequence_games{phase=\"%s\"} %d\n", phase, phases[phase])
+	}
+	fmt.Fprintf(&b, "# HELP sequence_websocket_connections Open WebSocket connections; a player with several tabs open counts once per tab.\n# TYPE sequence_websocket_connections gauge\nsequence_websocket_connections %d\n", conns)
+	writeCounter(&b, "sequence_websocket_messages_total", "WebSocket messages received, by action.", "action", messagesReceived)
+	writeCounter(&b, "sequence_rejected_actions_total", "Requests answered with an ERROR, by code.", "code", actionsRejected)
+	writeCounter(&b, "sequence_draw_pile_exhausted_total", "Draw piles that ran out, by the game's exhaustion policy.", "policy", drawPilesExhausted)
+	writeHistogram(&b, "sequence_broadcast_duration_seconds", "Time to send a game state to every player at the table.", broadcastSeconds)
+	writeHistogram(&b, "sequence_game_duration_seconds", "Length of finished games, from start to end.", gameSeconds)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(b.Bytes())
+}
+
+// serveHealth answers GET /healthz: the server is up.
+func serveHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// serveReady answers GET /readyz: the server takes new players, which it stops
+// doing once it is shutting down.
+func serveReady(w http.ResponseWriter, r *http.Request) {
+	if shuttingDown.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "shutting down"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
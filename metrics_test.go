@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"sequence-game/game"
+)
+
+func TestWriteCounter(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels []string // Incremented once each
+		want   string
+	}{
+		{name: "empty", want: "# HELP test_total Help.\n# TYPE test_total counter\n"},
+		{
+			name: "sorted by label", labels: []string{"PLAY", "DRAW_CARD", "PLAY"},
+			want: "# HELP test_total Help.\n# TYPE test_total counter\ntest_total{action=\"DRAW_CARD\"} 1\ntest_total{action=\"PLAY\"} 2\n",
+		},
+		{
+			name: "escaped label", labels: []string{"a\"b\\c\nd"},
+			want: "# HELP test_total Help.\n# TYPE test_total counter\ntest_total{action=\"a\\\"b\\\\c\\nd\"} 1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCounterVec()
+			for _, label := range tt.labels {
+				c.inc(label)
+			}
+			var b bytes.Buffer
+			writeCounter(&b, "test_total", "Help.", "action", c)
+			if b.String() != tt.want {
+				t.Errorf("wrote\n%s\nwant\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteHistogram(t *testing.T) {
+	tests := []struct {
+		name     string
+		observed []float64
+		want     string
+	}{
+		{
+			name: "empty",
+			want: "test_seconds_bucket{le=\"1\"} 0\ntest_seconds_bucket{le=\"5\"} 0\ntest_seconds_bucket{le=\"+Inf\"} 0\ntest_seconds_sum 0\ntest_seconds_count 0\n",
+		},
+		{
+			name: "cumulative buckets", observed: []float64{0.5, 1, 3, 10},
+			want: "test_seconds_bucket{le=\"1\"} 2\ntest_seconds_bucket{le=\"5\"} 3\ntest_seconds_bucket{le=\"+Inf\"} 4\ntest_seconds_sum 14.5\ntest_seconds_count 4\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHistogram(1, 5)
+			for _, v := range tt.observed {
+				h.observe(v)
+			}
+			var b bytes.Buffer
+			writeHistogram(&b, "test_seconds", "Help.", h)
+			want := "# HELP test_seconds Help.\n# TYPE test_seconds histogram\n" + tt.want
+			if b.String() != want {
+				t.Errorf("wrote\n%s\nwant\n%s", b.String(), want)
+			}
+		})
+	}
+}
+
+func TestServeMetrics(t *testing.T) {
+	rules, err := game.RulesPreset(game.DefaultRulesPreset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lobby := game.NewGame("a", "A", 2, 0, rules)
+	gamesMu.Lock()
+	saved := games
+	games = map[string]*game.Game{lobby.ID: lobby}
+	gamesMu.Unlock()
+	t.Cleanup(func() {
+		gamesMu.Lock()
+		games = saved
+		gamesMu.Unlock()
+	})
+
+	rec := httptest.NewRecorder()
+	serveMetrics(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type %q, want the text format", ct)
+	}
+
+	// Every sample belongs to a family declared by HELP and TYPE before it
+	sample := regexp.MustCompile(`^([a-z_]+?)(_bucket|_sum|_count)?(\{[a-z]+="(?:[^"\\]|\\.)*"\})? [0-9.e+-]+$`)
+	declared := make(map[string]string)
+	body := rec.Body.String()
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+		if help, ok := strings.CutPrefix(line, "# HELP "); ok {
+			declared[strings.Fields(help)[0]] = ""
+			continue
+		}
+		if typ, ok := strings.CutPrefix(line, "# TYPE "); ok {
+			fields := strings.Fields(typ)
+			if _, ok := declared[fields[0]]; !ok || len(fields) != 2 {
+				t.Errorf("TYPE without HELP: %q", line)
+			}
+			declared[fields[0]] = fields[1]
+			continue
+		}
+		m := sample.FindStringSubmatch(line)
+		if m == nil {
+			t.Errorf("malformed sample %q", line)
+			continue
+		}
+		if declared[m[1]] == "" {
+			t.Errorf("sample of undeclared metric: %q", line)
+		}
+	}
+
+	for _, want := range []string{
+		"sequence_games{phase=\"Lobby\"} 1\n",
+		"sequence_games{phase=\"InProgress\"} 0\n",
+		"# TYPE sequence_websocket_connections gauge\n",
+		"# TYPE sequence_game_duration_seconds histogram\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics lack %q", want)
+		}
+	}
+}
@@ -0,0 +1,739 @@
+// Package protocol defines the JSON messages exchanged over the /ws WebSocket.
+//
+// A connection starts with the client sending a Hello frame. The server answers
+// with a Welcome carrying the negotiated protocol version and the player ID to
+// store for reconnects. After that the client sends ClientMessage envelopes,
+// whose payload is one of the typed action structs below, and the server sends
+// the typed events. Run "go generate ./protocol" to refresh schema.json.
+//
+// Table state is versioned. A player receives a full GameState when they create
+// or join a game and when they send RESYNC; after that every change arrives as a
+// STATE_PATCH whose BaseVersion must equal the version the client holds. A client
+// that sees a gap discards the patch and asks for a RESYNC.
+//
+// In a hot-seat game one connection plays every seat. The server hides the hand
+// whenever the turn moves on and sends PASS_DEVICE naming the next seat; the
+// client answers REVEAL_HAND once that player holds the device, and only then
+// gets their HAND_UPDATE.
+//
+// When the rules allow hints, a player may send REQUEST_HINT on their turn for a
+// HINT with suggested moves, and SET_ANALYSIS to get a MOVE_ANALYSIS of each of
+// their moves. Both answers go to that player only.
+//
+// Accounts are optional. REGISTER or LOGIN (outside a game) switches the
+// connection to the account's persistent player ID and answers with ACCOUNT,
+// carrying a session token; sending that token in a later Hello logs straight
+// back in. The player ID of an account can only be used with its token.
+//
+// Bots set Bot in their Hello. They cannot play ranked games, and a game with a
+// bot at the table is left off the ratings.
+//
+// Instead of sharing game IDs, players can send QUEUE_FOR_MATCH. The server
+// answers QUEUE_STATUS, and once it has grouped enough players it creates and
+// starts a game for them: each gets its GameState as if they had joined, and a
+// QUEUE_STATUS of MATCHED. LEAVE_QUEUE gives up waiting.
+//
+// Before the server stops it sends every connection SERVER_SHUTDOWN, with the
+// time it expects to be back if it knows, and then closes the WebSocket with
+// code 1001 (going away). A client reconnects once the server is back and sends
+// JOIN_GAME for its game: games in progress are saved and reopened with the same
+// seats, hands and version.
+package protocol
+
+//go:generate go run ../cmd/sequence-schema -o schema.json
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"sequence-game/accounts"
+	"sequence-game/game"
+)
+
+// --- Versioning & Handshake ---
+
+const (
+	Version    = 1 // Newest protocol version this server speaks
+	MinVersion = 1 // Oldest protocol version this server still accepts
+)
+
+// Hello is the first frame a client sends after connecting.
+type Hello struct {
+	PlayerID        string `json:"playerId,omitempty"`        // Reconnect as this player; empty to be assigned a new ID
+	ProtocolVersion int    `json:"protocolVersion,omitempty"` // Newest version the client speaks; 0 is treated as 1
+	SessionToken    string `json:"sessionToken,omitempty"`    // Log back in to an account; its player ID replaces PlayerID
+	Bot             bool   `json:"bot,omitempty"`             // The player is a program; its games are never rated
+}
+
+// Negotiate picks the protocol version to speak with a client whose newest version is clientVersion.
+func Negotiate(clientVersion int) (int, error) {
+	if clientVersion == 0 {
+		clientVersion = 1
+	}
+	if clientVersion < MinVersion {
+		return 0, fmt.Errorf("protocol version %d is no longer supported (server speaks %d-%d)", clientVersion, MinVersion, Version)
+	}
+	if clientVersion > Version {
+		return Version, nil
+	}
+	return clientVersion, nil
+}
+
+// --- Client Actions ---
+
+// ActionType names a client request.
+type ActionType string
+
+const (
+	ActionCreateGame    ActionType = "CREATE_GAME"
+	ActionJoinGame      ActionType = "JOIN_GAME"
+	ActionStartGame     ActionType = "START_GAME"
+	ActionPlay          ActionType = "PLAY_ACTION"
+	ActionDeadCard      ActionType = "DEAD_CARD"
+	ActionDrawCard      ActionType = "DRAW_CARD"
+	ActionResync        ActionType = "RESYNC"
+	ActionRevealHand    ActionType = "REVEAL_HAND"
+	ActionRequestHint   ActionType = "REQUEST_HINT"
+	ActionSetAnalysis   ActionType = "SET_ANALYSIS"
+	ActionRegister      ActionType = "REGISTER"
+	ActionLogin         ActionType = "LOGIN"
+	ActionLogout        ActionType = "LOGOUT"
+	ActionUpdateProfile ActionType = "UPDATE_PROFILE"
+	ActionQueueForMatch ActionType = "QUEUE_FOR_MATCH"
+	ActionLeaveQueue    ActionType = "LEAVE_QUEUE"
+)
+
+// ClientMessage is the envelope of every client request after the handshake.
+type ClientMessage struct {
+	ActionType ActionType      `json:"actionType"`
+	RequestID  string          `json:"requestId,omitempty"` // Echoed in any ERROR caused by this request
+	Payload    json.RawMessage `json:"payload,omitempty"`
+}
+
+// CreateGame creates a lobby with the sender as host.
+type CreateGame struct {
+	PlayerName     string      `json:"playerName"`
+	MaxPlayers     int         `json:"maxPlayers,omitempty"`
+	SequencesToWin int         `json:"sequencesToWin,omitempty"` // Overrides the rules' value when set
+	RulesPreset    string      `json:"rulesPreset,omitempty"`    // Named preset, e.g. "Official", "Kids", "Speed"
+	Rules          *game.Rules `json:"rules,omitempty"`          // Custom rules, takes precedence over RulesPreset
+	HotSeat        []string    `json:"hotSeat,omitempty"`        // Names of the other players sharing this device; makes a hot-seat game
+	Ranked         bool        `json:"ranked,omitempty"`         // Rates the game; turns hints off and rules out hot-seat play
+	Teams          int         `json:"teams,omitempty"`          // 2 or 3 for a team game; maxPlayers, 2 per team by default, must split evenly
+}
+
+// JoinGame joins a lobby, or rejoins a game in progress.
+type JoinGame struct {
+	GameID     string `json:"gameId"`
+	PlayerName string `json:"playerName"`
+}
+
+// StartGame deals the cards; only the host may send it.
+type StartGame struct {
+	GameID string `json:"gameId,omitempty"`
+}
+
+// Play plays a card from the sender's hand onto the board.
+type Play struct {
+	GameID string `json:"gameId,omitempty"`
+	game.PlayerAction
+}
+
+// DeadCard turns in a card whose spaces are all covered.
+type DeadCard struct {
+	GameID string `json:"gameId,omitempty"`
+	CardID string `json:"cardId"`
+}
+
+// DrawCard draws the card owed after a play under the explicit-draw rule.
+type DrawCard struct {
+	GameID string `json:"gameId,omitempty"`
+}
+
+// Resync asks for a full GameState snapshot after a missed STATE_PATCH.
+type Resync struct {
+	GameID string `json:"gameId,omitempty"`
+}
+
+// RevealHand confirms that the device has been passed to the seat named in
+// PASS_DEVICE, so its hand may be shown.
+type RevealHand struct {
+	GameID   string `json:"gameId,omitempty"`
+	PlayerID string `json:"playerId"`
+}
+
+// RequestHint asks for suggested moves for the sender's turn.
+type RequestHint struct {
+	GameID string `json:"gameId,omitempty"`
+}
+
+// SetAnalysis turns the review of the sender's own moves on or off.
+type SetAnalysis struct {
+	GameID  string `json:"gameId,omitempty"`
+	Enabled bool   `json:"enabled"`
+}
+
+// Register creates an account and logs in to it.
+type Register struct {
+	Username string            `json:"username"`
+	Password string            `json:"password"`
+	Profile  *accounts.Profile `json:"profile,omitempty"` // Display name defaults to the username
+}
+
+// Login logs in to an account.
+type Login struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Logout ends the session and goes back to playing as a new guest.
+type Logout struct{}
+
+// UpdateProfile replaces the logged-in account's profile.
+type UpdateProfile struct {
+	Profile accounts.Profile `json:"profile"`
+}
+
+// MatchMode is the kind of game a player queues for.
+type MatchMode string
+
+const (
+	MatchDuel       MatchMode = "1v1"    // Two players
+	MatchFreeForAll MatchMode = "ffa"    // Three players, each for themselves
+	MatchTwoVsTwo   MatchMode = "2v2"    // Two teams of two
+	MatchThreeTeams MatchMode = "3-team" // Three teams of two
+)
+
+// MatchPlayers is how many players a game of the mode seats; zero for a mode the
+// server cannot run.
+func MatchPlayers(mode MatchMode) int {
+	switch mode {
+	case MatchDuel:
+		return 2
+	case MatchFreeForAll:
+		return 3
+	case MatchTwoVsTwo:
+		return 4
+	case MatchThreeTeams:
+		return 6
+	}
+	return 0
+}
+
+// MatchTeams is how many teams a game of the mode has; zero when everyone plays
+// for themselves.
+func MatchTeams(mode MatchMode) int {
+	switch mode {
+	case MatchTwoVsTwo:
+		return 2
+	case MatchThreeTeams:
+		return 3
+	}
+	return 0
+}
+
+// QueueForMatch puts the sender in the matchmaking queue, or changes what they are
+// queued for. Once enough players are found the server creates the game, seats
+// them and starts it.
+type QueueForMatch struct {
+	PlayerName  string    `json:"playerName"`
+	Mode        MatchMode `json:"mode"`
+	Ranked      bool      `json:"ranked,omitempty"`      // Only match with players who also want a ranked game
+	RatingRange float64   `json:"ratingRange,omitempty"` // Widest rating gap accepted at first; it widens while waiting. Zero accepts anyone
+}
+
+// LeaveQueue takes the sender out of the matchmaking queue.
+type LeaveQueue struct{}
+
+// actionPayloads maps each action to a constructor for its payload.
+var actionPayloads = map[ActionType]func() interface{}{
+	ActionCreateGame:    func() interface{} { return &CreateGame{} },
+	ActionJoinGame:      func() interface{} { return &JoinGame{} },
+	ActionStartGame:     func() interface{} { return &StartGame{} },
+	ActionPlay:          func() interface{} { return &Play{} },
+	ActionDeadCard:      func() interface{} { return &DeadCard{} },
+	ActionDrawCard:      func() interface{} { return &DrawCard{} },
+	ActionResync:        func() interface{} { return &Resync{} },
+	ActionRevealHand:    func() interface{} { return &RevealHand{} },
+	ActionRequestHint:   func() interface{} { return &RequestHint{} },
+	ActionSetAnalysis:   func() interface{} { return &SetAnalysis{} },
+	ActionRegister:      func() interface{} { return &Register{} },
+	ActionLogin:         func() interface{} { return &Login{} },
+	ActionLogout:        func() interface{} { return &Logout{} },
+	ActionUpdateProfile: func() interface{} { return &UpdateProfile{} },
+	ActionQueueForMatch: func() interface{} { return &QueueForMatch{} },
+	ActionLeaveQueue:    func() interface{} { return &LeaveQueue{} },
+}
+
+// Known reports whether the action is part of the protocol.
+func (a ActionType) Known() bool {
+	_, ok := actionPayloads[a]
+	return ok
+}
+
+// Decode unmarshals the payload into the typed struct for the message's action,
+// e.g. *CreateGame for CREATE_GAME.
+func (m *ClientMessage) Decode() (interface{}, error) {
+	newPayload, ok := actionPayloads[m.ActionType]
+	if !ok {
+		return nil, fmt.Errorf("unknown action: %s", m.ActionType)
+	}
+	payload := newPayload()
+	if len(m.Payload) > 0 {
+		if err := json.Unmarshal(m.Payload, payload); err != nil {
+			return nil, fmt.Errorf("invalid %s payload: %v", m.ActionType, err)
+		}
+	}
+	return payload, nil
+}
+
+// NewClientMessage wraps a typed action payload in its envelope.
+func NewClientMessage(action ActionType, requestID string, payload interface{}) (ClientMessage, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return ClientMessage{}, err
+	}
+	return ClientMessage{ActionType: action, RequestID: requestID, Payload: raw}, nil
+}
+
+// --- Server Events ---
+
+// EventType names a server message; every event carries it in "type".
+type EventType string
+
+const (
+	EventWelcome      EventType = "WELCOME"
+	EventGameCreated  EventType = "GAME_CREATED"
+	EventPlayerJoined EventType = "PLAYER_JOINED"
+	EventGameStarted  EventType = "GAME_STARTED"
+	EventGameUpdate   EventType = "GAME_UPDATE"
+	EventHandUpdate   EventType = "HAND_UPDATE"
+	EventStatePatch   EventType = "STATE_PATCH"
+	EventPassDevice   EventType = "PASS_DEVICE"
+	EventHint         EventType = "HINT"
+	EventMoveAnalysis EventType = "MOVE_ANALYSIS"
+	EventAccount      EventType = "ACCOUNT"
+	EventQueueStatus  EventType = "QUEUE_STATUS"
+	EventShutdown     EventType = "SERVER_SHUTDOWN"
+	EventError        EventType = "ERROR"
+)
+
+// Welcome answers the Hello.
+type Welcome struct {
+	Type            EventType `json:"type"`
+	PlayerID        string    `json:"playerId"`
+	ProtocolVersion int       `json:"protocolVersion"`
+	Account         *Account  `json:"account,omitempty"` // Set when the Hello's session token logged in
+}
+
+// Account answers REGISTER, LOGIN, LOGOUT and UPDATE_PROFILE with who the
+// connection now plays as. Username is empty for a guest.
+type Account struct {
+	Type         EventType         `json:"type"`
+	Username     string            `json:"username,omitempty"`
+	PlayerID     string            `json:"playerId"`               // Store it, as after a Welcome
+	SessionToken string            `json:"sessionToken,omitempty"` // Only after REGISTER and LOGIN; send it in the next Hello
+	Profile      *accounts.Profile `json:"profile,omitempty"`
+}
+
+// QueueState is where a player stands with the matchmaker.
+type QueueState string
+
+const (
+	QueueWaiting QueueState = "QUEUED"  // In the queue
+	QueueLeft    QueueState = "LEFT"    // Out of the queue without a game
+	QueueMatched QueueState = "MATCHED" // Seated in GameID, which has started
+)
+
+// QueueStatus answers QUEUE_FOR_MATCH and LEAVE_QUEUE, and tells a queued player
+// when they have been matched.
+type QueueStatus struct {
+	Type        EventType  `json:"type"`
+	Status      QueueState `json:"status"`
+	Mode        MatchMode  `json:"mode"`
+	Ranked      bool       `json:"ranked,omitempty"`
+	Rating      float64    `json:"rating"`                // The player's rating on the mode's ladder
+	RatingRange float64    `json:"ratingRange,omitempty"` // Rating gap accepted at first; zero accepts anyone
+	Waiting     int        `json:"waiting"`               // Players queued for the same kind of game, including this one
+	GameID      string     `json:"gameId,omitempty"`      // Set when MATCHED
+}
+
+// ServerShutdown warns every connection that the server is stopping. The
+// connection is closed right after.
+type ServerShutdown struct {
+	Type      EventType  `json:"type"`
+	Message   string     `json:"message"`
+	RestartAt *time.Time `json:"restartAt,omitempty"` // When the server expects to be back, if it knows
+}
+
+// PlayerView is what every player at the table sees about a player.
+type PlayerView struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	ChipColor   string `json:"chipColor"`
+	Sequences   int    `json:"sequences"`
+	IsConnected bool   `json:"isConnected"`
+	HandCount   int    `json:"handCount"`
+	HandLimit   int    `json:"handLimit"`
+	IsMyTurn    bool   `json:"isMyTurn"`
+	Team        string `json:"team,omitempty"` // In a team game; teammates share a chip colour and their sequences
+}
+
+// GameState is a full snapshot of the table, sent for GAME_CREATED, PLAYER_JOINED,
+// GAME_STARTED and GAME_UPDATE. Details holds one of the *Detail types.
+type GameState struct {
+	Type                EventType                                       `json:"type"`
+	GameID              string                                          `json:"gameId"`
+	Version             int                                             `json:"version"`
+	Board               [game.BoardSize][game.BoardSize]game.BoardSpace `json:"board"`
+	Players             map[string]PlayerView                           `json:"players"`
+	PlayerOrder         []string                                        `json:"playerOrder"`
+	CurrentTurnPlayerID string                                          `json:"currentTurnPlayerId"`
+	GamePhase           string                                          `json:"gamePhase"`
+	Winner              string                                          `json:"winner,omitempty"`
+	NumSequencesToWin   int                                             `json:"numSequencesToWin"`
+	MaxPlayers          int                                             `json:"maxPlayers"`
+	HostID              string                                          `json:"hostId"`
+	HotSeat             bool                                            `json:"hotSeat,omitempty"` // Every seat is played from the host's device
+	Ranked              bool                                            `json:"ranked,omitempty"`  // The result counts towards ratings
+	Teams               int                                             `json:"teams,omitempty"`   // Number of teams in a team game
+	Rules               game.Rules                                      `json:"rules"`
+	DeadCardUsed        bool                                            `json:"deadCardUsed"`
+	PendingDraw         string                                          `json:"pendingDraw,omitempty"`
+	MoveCount           int                                             `json:"moveCount"`
+	DrawPileCount       int                                             `json:"drawPileCount"`
+	Message             string                                          `json:"message,omitempty"`
+	Details             interface{}                                     `json:"details,omitempty"`
+}
+
+// NewGameState builds the full table state of g, as every player sees it.
+// The caller must hold the game lock.
+func NewGameState(g *game.Game, eventType EventType, details interface{}) GameState {
+	broadcastPlayers := make(map[string]PlayerView)
+	for pid, p := range g.Players {
+		isMyTurn := false
+		if g.GamePhase == "InProgress" && len(g.PlayerOrder) > 0 && g.CurrentTurnIndex < len(g.PlayerOrder) {
+			isMyTurn = (g.PlayerOrder[g.CurrentTurnIndex] == pid)
+		}
+		broadcastPlayers[pid] = PlayerView{
+			ID: p.ID, Name: p.Name, ChipColor: p.ChipColor, Sequences: p.Sequences,
+			IsConnected: p.IsConnected, HandCount: len(p.Hand), HandLimit: p.HandLimit, IsMyTurn: isMyTurn,
+			Team: p.Team,
+		}
+	}
+
+	currentTurnPlayerID := ""
+	if g.GamePhase == "InProgress" && len(g.PlayerOrder) > 0 && g.CurrentTurnIndex < len(g.PlayerOrder) {
+		currentTurnPlayerID = g.PlayerOrder[g.CurrentTurnIndex]
+	}
+
+	return GameState{
+		Type: eventType, GameID: g.ID, Board: g.Board, Players: broadcastPlayers, PlayerOrder: g.PlayerOrder,
+		CurrentTurnPlayerID: currentTurnPlayerID, GamePhase: g.GamePhase, Winner: g.Winner,
+		NumSequencesToWin: g.NumSequencesToWin, MaxPlayers: g.MaxPlayers, HostID: g.HostID,
+		HotSeat: g.HotSeat, Ranked: g.Ranked, Teams: g.Teams, Rules: g.Rules, DeadCardUsed: g.DeadCardUsed,
+		PendingDraw: g.PendingDraw, MoveCount: g.MoveCount, DrawPileCount: g.DrawPileCount, Details: details,
+	}
+}
+
+// LegalMoves lists the plays the engine would accept from playerID holding hand
+// on this table, as if it were their turn. Clients use it to highlight moves.
+func (s *GameState) LegalMoves(playerID string, hand []string) []game.PlayerAction {
+	player := &game.Player{ID: playerID, Team: s.Players[playerID].Team}
+	for _, cardID := range hand {
+		if card, err := game.ParseCardID(cardID); err == nil {
+			player.Hand = append(player.Hand, *card)
+		}
+	}
+	scratch := &game.Game{
+		Board: s.Board, Rules: s.Rules, GamePhase: "InProgress",
+		Players: map[string]*game.Player{playerID: player}, PlayerOrder: []string{playerID},
+	}
+	for id, p := range s.Players {
+		if id != playerID {
+			scratch.Players[id] = &game.Player{ID: id, Team: p.Team} // Teammates' chips cannot be removed
+		}
+	}
+	return scratch.LegalMoves(playerID)
+}
+
+// StatePatch carries only what changed since BaseVersion. Event is the event the
+// change stands for (e.g. GAME_STARTED); nil fields are unchanged.
+type StatePatch struct {
+	Type                EventType             `json:"type"`
+	Event               EventType             `json:"event"`
+	GameID              string                `json:"gameId"`
+	Version             int                   `json:"version"`
+	BaseVersion         int                   `json:"baseVersion"`
+	Spaces              []SpacePatch          `json:"spaces,omitempty"`
+	Players             map[string]PlayerView `json:"players,omitempty"` // Changed players only
+	PlayerOrder         []string              `json:"playerOrder,omitempty"`
+	CurrentTurnPlayerID *string               `json:"currentTurnPlayerId,omitempty"`
+	GamePhase           *string               `json:"gamePhase,omitempty"`
+	Winner              *string               `json:"winner,omitempty"`
+	DeadCardUsed        *bool                 `json:"deadCardUsed,omitempty"`
+	PendingDraw         *string               `json:"pendingDraw,omitempty"`
+	MoveCount           *int                  `json:"moveCount,omitempty"`
+	DrawPileCount       *int                  `json:"drawPileCount,omitempty"`
+	Details             interface{}           `json:"details,omitempty"`
+}
+
+// SpacePatch is the new chip state of one board space; the printed card never changes.
+type SpacePatch struct {
+	Pos        game.Position `json:"pos"`
+	OccupiedBy string        `json:"occupiedBy"`
+	IsLocked   bool          `json:"isLocked"`
+}
+
+// HandUpdate privately sends a player their own cards, only when they change.
+type HandUpdate struct {
+	Type     EventType `json:"type"`
+	Version  int       `json:"version"`            // State version the hand belongs to
+	PlayerID string    `json:"playerId,omitempty"` // Whose hand it is: in hot-seat games the seat holding the device, empty while hidden
+	Hand     []string  `json:"hand"`               // Card IDs
+}
+
+// PassDevice tells a hot-seat device to hand over to the next seat. Its hand stays
+// hidden until the client sends REVEAL_HAND for it.
+type PassDevice struct {
+	Type       EventType `json:"type"`
+	GameID     string    `json:"gameId"`
+	PlayerID   string    `json:"playerId"`
+	PlayerName string    `json:"playerName"`
+}
+
+// Hint answers REQUEST_HINT with suggested moves, best first.
+type Hint struct {
+	Type        EventType         `json:"type"`
+	GameID      string            `json:"gameId"`
+	PlayerID    string            `json:"playerId"` // The seat the hint is for
+	Suggestions []Suggestion      `json:"suggestions"`
+	Threats     game.ThreatReport `json:"threats"` // Every player's threats on the board the hint is for
+}
+
+// Suggestion is one suggested move: a play, or a dead card to turn in first.
+type Suggestion struct {
+	Action   *game.PlayerAction `json:"action,omitempty"`
+	DeadCard string             `json:"deadCard,omitempty"`
+	Reasons  []string           `json:"reasons"` // e.g. "blocks red's 4-in-a-row", "completes your sequence"
+}
+
+// Verdict grades a move in a MOVE_ANALYSIS.
+type Verdict string
+
+const (
+	VerdictGood       Verdict = "GOOD"
+	VerdictInaccuracy Verdict = "INACCURACY" // A Jack spent where it was not needed
+	VerdictBlunder    Verdict = "BLUNDER"    // A sequence missed, or an opponent's sequence left open
+)
+
+// MoveAnalysis reviews a move just made, for a player who sent SET_ANALYSIS.
+type MoveAnalysis struct {
+	Type      EventType          `json:"type"`
+	GameID    string             `json:"gameId"`
+	PlayerID  string             `json:"playerId"`
+	MoveCount int                `json:"moveCount"` // MoveCount of the game after the move
+	Action    game.PlayerAction  `json:"action"`
+	Verdict   Verdict            `json:"verdict"`
+	Notes     []string           `json:"notes,omitempty"`
+	Better    *game.PlayerAction `json:"better,omitempty"` // A move that avoids the blunder
+	Threats   game.ThreatReport  `json:"threats"`          // Every player's threats on the board after the move
+}
+
+// Diff builds the patch that turns prev into s. It reports false when a field
+// only carried by snapshots (rules, limits, host) changed and a full GameState
+// must be sent instead.
+func (s *GameState) Diff(prev *GameState) (StatePatch, bool) {
+	if s.GameID != prev.GameID || s.HostID != prev.HostID || s.MaxPlayers != prev.MaxPlayers || s.Teams != prev.Teams ||
+		s.NumSequencesToWin != prev.NumSequencesToWin || !reflect.DeepEqual(s.Rules, prev.Rules) {
+		return StatePatch{}, false
+	}
+	patch := StatePatch{Type: EventStatePatch, Event: s.Type, GameID: s.GameID, Version: s.Version, BaseVersion: prev.Version, Details: s.Details}
+	for x := range s.Board {
+		for y := range s.Board[x] {
+			space, old := s.Board[x][y], prev.Board[x][y]
+			if space.OccupiedBy != old.OccupiedBy || space.IsLocked != old.IsLocked {
+				patch.Spaces = append(patch.Spaces, SpacePatch{Pos: game.Position{X: x, Y: y}, OccupiedBy: space.OccupiedBy, IsLocked: space.IsLocked})
+			}
+		}
+	}
+	for id, player := range s.Players {
+		if old, ok := prev.Players[id]; !ok || old != player {
+			if patch.Players == nil {
+				patch.Players = make(map[string]PlayerView)
+			}
+			patch.Players[id] = player
+		}
+	}
+	if !reflect.DeepEqual(s.PlayerOrder, prev.PlayerOrder) {
+		patch.PlayerOrder = s.PlayerOrder
+	}
+	if s.CurrentTurnPlayerID != prev.CurrentTurnPlayerID {
+		patch.CurrentTurnPlayerID = &s.CurrentTurnPlayerID
+	}
+	if s.GamePhase != prev.GamePhase {
+		patch.GamePhase = &s.GamePhase
+	}
+	if s.Winner != prev.Winner {
+		patch.Winner = &s.Winner
+	}
+	if s.DeadCardUsed != prev.DeadCardUsed {
+		patch.DeadCardUsed = &s.DeadCardUsed
+	}
+	if s.PendingDraw != prev.PendingDraw {
+		patch.PendingDraw = &s.PendingDraw
+	}
+	if s.MoveCount != prev.MoveCount {
+		patch.MoveCount = &s.MoveCount
+	}
+	if s.DrawPileCount != prev.DrawPileCount {
+		patch.DrawPileCount = &s.DrawPileCount
+	}
+	return patch, true
+}
+
+// Apply updates s in place with a patch built against s's version. It returns
+// an error, leaving s untouched, when the versions do not line up and the
+// client should send RESYNC.
+func (s *GameState) Apply(patch *StatePatch) error {
+	if patch.GameID != s.GameID || patch.BaseVersion != s.Version {
+		return fmt.Errorf("patch for version %d of game %s does not apply to version %d of game %s", patch.BaseVersion, patch.GameID, s.Version, s.GameID)
+	}
+	s.Type, s.Version, s.Details = patch.Event, patch.Version, patch.Details
+	for _, space := range patch.Spaces {
+		s.Board[space.Pos.X][space.Pos.Y].OccupiedBy = space.OccupiedBy
+		s.Board[space.Pos.X][space.Pos.Y].IsLocked = space.IsLocked
+	}
+	if len(patch.Players) > 0 {
+		players := make(map[string]PlayerView, len(s.Players)+len(patch.Players))
+		for id, player := range s.Players {
+			players[id] = player
+		}
+		for id, player := range patch.Players {
+			players[id] = player
+		}
+		s.Players = players
+	}
+	if patch.PlayerOrder != nil {
+		s.PlayerOrder = patch.PlayerOrder
+	}
+	if patch.CurrentTurnPlayerID != nil {
+		s.CurrentTurnPlayerID = *patch.CurrentTurnPlayerID
+	}
+	if patch.GamePhase != nil {
+		s.GamePhase = *patch.GamePhase
+	}
+	if patch.Winner != nil {
+		s.Winner = *patch.Winner
+	}
+	if patch.DeadCardUsed != nil {
+		s.DeadCardUsed = *patch.DeadCardUsed
+	}
+	if patch.PendingDraw != nil {
+		s.PendingDraw = *patch.PendingDraw
+	}
+	if patch.MoveCount != nil {
+		s.MoveCount = *patch.MoveCount
+	}
+	if patch.DrawPileCount != nil {
+		s.DrawPileCount = *patch.DrawPileCount
+	}
+	return nil
+}
+
+// Error reports a rejected request to its sender only.
+type Error struct {
+	Type      EventType      `json:"type"`
+	GameID    string         `json:"gameId,omitempty"`
+	RequestID string         `json:"requestId,omitempty"` // RequestID of the ClientMessage that caused the error
+	Code      game.ErrorCode `json:"code"`                // Engine code such as NOT_YOUR_TURN, or one of the server codes below
+	Error     string         `json:"error"`               // Human-readable message
+}
+
+// Error codes for requests the server rejects before they reach the engine.
+const (
+	CodeBadRequest         game.ErrorCode = "BAD_REQUEST" // Unknown action or malformed payload
+	CodeUnsupportedVersion game.ErrorCode = "UNSUPPORTED_VERSION"
+	CodeInvalidRules       game.ErrorCode = "INVALID_RULES"
+	CodeGameNotFound       game.ErrorCode = "GAME_NOT_FOUND"
+	CodeNotInGame          game.ErrorCode = "NOT_IN_GAME"
+	CodeHandHidden         game.ErrorCode = "HAND_NOT_REVEALED" // Hot-seat: the device has not been passed to the seat yet
+	CodeHintsDisabled      game.ErrorCode = "HINTS_DISABLED"    // The game's rules do not allow hints or analysis
+	CodeInvalidAccount     game.ErrorCode = "INVALID_ACCOUNT"   // Username, password or profile not acceptable
+	CodeUsernameTaken      game.ErrorCode = "USERNAME_TAKEN"
+	CodeBadCredentials     game.ErrorCode = "BAD_CREDENTIALS"
+	CodeNotLoggedIn        game.ErrorCode = "NOT_LOGGED_IN"
+	CodeInGame             game.ErrorCode = "IN_GAME"          // Not possible in a game, or while queued for one
+	CodeModeUnavailable    game.ErrorCode = "MODE_UNAVAILABLE" // The server cannot run games of the requested match mode
+	CodeNotQueued          game.ErrorCode = "NOT_QUEUED"
+	CodeServerFull         game.ErrorCode = "SERVER_FULL"   // The server is running as many games as it allows
+	CodeShuttingDown       game.ErrorCode = "SHUTTING_DOWN" // The server is stopping: it starts no new games, and games in progress take no more moves
+	CodeRejected           game.ErrorCode = "REJECTED"      // Any other failure
+)
+
+// ErrorCodes lists every code an ERROR may carry.
+func ErrorCodes() []game.ErrorCode {
+	return append([]game.ErrorCode{CodeBadRequest, CodeUnsupportedVersion, CodeInvalidRules, CodeGameNotFound, CodeNotInGame, CodeHandHidden, CodeHintsDisabled,
+		CodeInvalidAccount, CodeUsernameTaken, CodeBadCredentials, CodeNotLoggedIn, CodeInGame, CodeModeUnavailable, CodeNotQueued, CodeServerFull, CodeShuttingDown, CodeRejected}, game.ErrorCodes...)
+}
+
+// CodeOf returns the error code to report for err.
+func CodeOf(err error) game.ErrorCode {
+	if code := game.CodeOf(err); code != "" {
+		return code
+	}
+	switch {
+	case errors.Is(err, accounts.ErrInvalid):
+		return CodeInvalidAccount
+	case errors.Is(err, accounts.ErrUsernameTaken):
+		return CodeUsernameTaken
+	case errors.Is(err, accounts.ErrBadCredentials):
+		return CodeBadCredentials
+	case errors.Is(err, accounts.ErrNoSession):
+		return CodeNotLoggedIn
+	}
+	return CodeRejected
+}
+
+// --- Event Details ---
+
+// PlayerJoinedDetail accompanies PLAYER_JOINED.
+type PlayerJoinedDetail struct {
+	PlayerName string `json:"playerName"`
+	PlayerID   string `json:"playerId"`
+}
+
+// PlayDetail describes a PLAY_ACTION that was accepted.
+type PlayDetail struct {
+	Action            ActionType     `json:"action"`
+	Player            string         `json:"player"`
+	CardPlayedDisplay string         `json:"cardPlayedDisplay"`
+	CardPlayedID      string         `json:"cardPlayedID"`
+	Pos               game.Position  `json:"pos"`
+	MoveKind          game.MoveKind  `json:"moveKind,omitempty"`
+	RemovedChipAt     *game.Position `json:"removedChipAt,omitempty"`
+	MovedChipFrom     *game.Position `json:"movedChipFrom,omitempty"`
+	MovedChipTo       *game.Position `json:"movedChipTo,omitempty"`
+}
+
+// DeadCardDetail describes a dead card that was turned in.
+type DeadCardDetail struct {
+	Action             ActionType `json:"action"`
+	Player             string     `json:"player"`
+	CardDeclaredDeadID string     `json:"cardDeclaredDeadID"`
+}
+
+// DrawDetail describes a DRAW_CARD, or an AUTO_DRAW made by the server after the draw timeout.
+type DrawDetail struct {
+	Action   string `json:"action"` // "DRAW_CARD" or "AUTO_DRAW"
+	Player   string `json:"player,omitempty"`
+	PlayerID string `json:"playerId,omitempty"`
+}
+
+// NoticeDetail carries a free-form notice, such as a player disconnecting.
+type NoticeDetail struct {
+	Message string `json:"message,omitempty"`
+}
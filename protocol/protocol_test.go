@@ -0,0 +1,186 @@
+package protocol
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"sequence-game/game"
+)
+
+// stateOf is the state of g as broadcast at version.
+func stateOf(g *game.Game, version int) GameState {
+	g.Lock()
+	defer g.Unlock()
+	s := NewGameState(g, EventGameUpdate, nil)
+	s.Version = version
+	return s
+}
+
+// gameStates plays a short game and returns the state after each step.
+func gameStates(t *testing.T) map[string]GameState {
+	t.Helper()
+	rules, err := game.RulesPreset("Official")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := game.NewGame("a", "A", 3, 0, rules)
+	g.Seed(1)
+	states := make(map[string]GameState)
+	version := 0
+	step := func(name string) {
+		version++
+		states[name] = stateOf(g, version)
+	}
+	play := func(action game.PlayerAction) {
+		t.Helper()
+		if err := g.PlayAction(g.PlayerOrder[g.CurrentTurnIndex], action); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := g.AddPlayer("a", "A", nil); err != nil {
+		t.Fatal(err)
+	}
+	step("lobby")
+	if _, err := g.AddPlayer("b", "B", nil); err != nil {
+		t.Fatal(err)
+	}
+	step("joined")
+	if err := g.StartGame("a"); err != nil {
+		t.Fatal(err)
+	}
+	step("started")
+	play(g.LegalMoves("a")[0])
+	step("first play")
+
+	// b removes a's chip with a one-eyed Jack
+	var chip game.Position
+	for x := range g.Board {
+		for y := range g.Board[x] {
+			if g.Board[x][y].OccupiedBy == "a" {
+				chip = game.Position{X: x, Y: y}
+			}
+		}
+	}
+	jack, _ := game.ParseCardID("JS")
+	g.Players["b"].Hand[0] = *jack
+	step("hand changed")
+	play(game.PlayerAction{CardID: jack.ID, BoardPos: chip, MoveKind: game.MoveRemove})
+	step("chip removed")
+
+	// a fills the top row, two sequences with the corners, and wins
+	for y := 1; y < game.BoardSize-1; y++ {
+		if y != 5 {
+			g.Board[0][y].OccupiedBy = "a"
+		}
+	}
+	six, _ := game.ParseCardID("6S")
+	g.Players["a"].Hand[0] = *six
+	play(game.PlayerAction{CardID: six.ID, BoardPos: game.Position{X: 0, Y: 5}})
+	if g.GamePhase != "Finished" {
+		t.Fatalf("the game did not finish: phase %s", g.GamePhase)
+	}
+	step("won")
+	return states
+}
+
+func TestDiffApply(t *testing.T) {
+	states := gameStates(t)
+	tests := []struct {
+		from, to string
+		changes  func(p StatePatch) bool // What the patch must carry
+	}{
+		{"lobby", "joined", func(p StatePatch) bool { return len(p.Players) == 1 && p.PlayerOrder != nil && p.Spaces == nil }},
+		{"joined", "started", func(p StatePatch) bool {
+			return *p.GamePhase == "InProgress" && len(p.Players) == 2 && p.DrawPileCount != nil
+		}},
+		{"started", "first play", func(p StatePatch) bool {
+			return len(p.Spaces) == 1 && p.MoveCount != nil && p.CurrentTurnPlayerID != nil
+		}},
+		{"first play", "hand changed", func(p StatePatch) bool { return len(p.Players) == 0 && len(p.Spaces) == 0 }},
+		{"hand changed", "chip removed", func(p StatePatch) bool { return len(p.Spaces) == 1 && p.Spaces[0].OccupiedBy == "" }},
+		{"chip removed", "won", func(p StatePatch) bool { return len(p.Spaces) >= 5 && p.Spaces[0].IsLocked && *p.Winner == "a" }},
+		{"started", "won", func(p StatePatch) bool { return p.GamePhase != nil && p.Winner != nil }}, // Several versions at once
+		{"won", "won", func(p StatePatch) bool { return p.Spaces == nil && p.Players == nil && p.GamePhase == nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			from, to := states[tt.from], states[tt.to]
+			patch, ok := to.Diff(&from)
+			if !ok {
+				t.Fatal("Diff asked for a snapshot")
+			}
+			if !tt.changes(patch) {
+				t.Errorf("unexpected patch: %+v", patch)
+			}
+
+			got := from
+			if err := got.Apply(&patch); err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if !reflect.DeepEqual(got, to) {
+				t.Errorf("Apply(Diff) = %+v\nwant %+v", got, to)
+			}
+
+			// As sent to clients
+			data, err := json.Marshal(patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sent StatePatch
+			if err := json.Unmarshal(data, &sent); err != nil {
+				t.Fatal(err)
+			}
+			got = from
+			if err := got.Apply(&sent); err != nil {
+				t.Fatalf("Apply after JSON: %v", err)
+			}
+			if !reflect.DeepEqual(got.Board, to.Board) || !reflect.DeepEqual(got.Players, to.Players) || got.Version != to.Version ||
+				got.GamePhase != to.GamePhase || got.Winner != to.Winner || got.MoveCount != to.MoveCount || got.DrawPileCount != to.DrawPileCount {
+				t.Errorf("Apply(Diff) after JSON = %+v\nwant %+v", got, to)
+			}
+		})
+	}
+}
+
+func TestDiffNeedsSnapshot(t *testing.T) {
+	states := gameStates(t)
+	from, to := states["joined"], states["started"]
+	to.Rules.SequenceLength = 4
+	if _, ok := to.Diff(&from); ok {
+		t.Error("Diff patched a change of rules")
+	}
+	to = states["started"]
+	to.MaxPlayers++
+	if _, ok := to.Diff(&from); ok {
+		t.Error("Diff patched a change of table size")
+	}
+}
+
+func TestApplyVersionGap(t *testing.T) {
+	states := gameStates(t)
+	to := states["first play"]
+	base := states["started"]
+	patch, _ := to.Diff(&base)
+
+	tests := []struct {
+		name  string
+		state GameState
+	}{
+		{"older version", states["joined"]},
+		{"newer version", states["chip removed"]},
+		{"same version, other game", func() GameState { s := base; s.GameID = "other"; return s }()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.state
+			if err := got.Apply(&patch); err == nil {
+				t.Fatal("Apply accepted a patch for another version")
+			}
+			if !reflect.DeepEqual(got, tt.state) {
+				t.Error("a rejected patch changed the state")
+			}
+		})
+	}
+}
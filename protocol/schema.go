@@ -0,0 +1,208 @@
+package protocol
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"sequence-game/game"
+)
+
+// SchemaID is the $id of the generated JSON Schema document.
+const SchemaID = "urn:sequence-game:protocol"
+
+// enumValues lists the allowed values of the string-like enum types.
+var enumValues = map[reflect.Type][]string{
+	reflect.TypeOf(ActionType("")):              {string(ActionCreateGame), string(ActionJoinGame), string(ActionStartGame), string(ActionPlay), string(ActionDeadCard), string(ActionDrawCard), string(ActionResync), string(ActionRevealHand), string(ActionRequestHint), string(ActionSetAnalysis), string(ActionRegister), string(ActionLogin), string(ActionLogout), string(ActionUpdateProfile), string(ActionQueueForMatch), string(ActionLeaveQueue)},
+	reflect.TypeOf(EventType("")):               {string(EventWelcome), string(EventGameCreated), string(EventPlayerJoined), string(EventGameStarted), string(EventGameUpdate), string(EventHandUpdate), string(EventStatePatch), string(EventPassDevice), string(EventHint), string(EventMoveAnalysis), string(EventAccount), string(EventQueueStatus), string(EventShutdown), string(EventError)},
+	reflect.TypeOf(MatchMode("")):               {string(MatchDuel), string(MatchFreeForAll), string(MatchTwoVsTwo), string(MatchThreeTeams)},
+	reflect.TypeOf(QueueState("")):              {string(QueueWaiting), string(QueueLeft), string(QueueMatched)},
+	reflect.TypeOf(Verdict("")):                 {string(VerdictGood), string(VerdictInaccuracy), string(VerdictBlunder)},
+	reflect.TypeOf(game.MoveKind("")):           {string(game.MovePlace), string(game.MoveWild), string(game.MoveRemove), string(game.MoveSwap)},
+	reflect.TypeOf(game.DeadCardPolicy("")):     {string(game.DeadCardOncePerTurn), string(game.DeadCardUnlimited)},
+	reflect.TypeOf(game.DrawPileExhaustion("")): {string(game.ExhaustionPlayOut), string(game.ExhaustionReshuffle), string(game.ExhaustionEndGame)},
+	reflect.TypeOf(game.Suit(0)):                {"H", "D", "C", "S"},
+	reflect.TypeOf(game.ErrorCode("")):          errorCodeValues(),
+}
+
+func errorCodeValues() []string {
+	codes := ErrorCodes()
+	values := make([]string, len(codes))
+	for i, code := range codes {
+		values[i] = string(code)
+	}
+	return values
+}
+
+// events lists each server event with its Go type, in documentation order.
+var events = []struct {
+	Types []EventType
+	Value interface{}
+}{
+	{[]EventType{EventWelcome}, Welcome{}},
+	{[]EventType{EventGameCreated, EventPlayerJoined, EventGameStarted, EventGameUpdate}, GameState{}},
+	{[]EventType{EventStatePatch}, StatePatch{}},
+	{[]EventType{EventHandUpdate}, HandUpdate{}},
+	{[]EventType{EventPassDevice}, PassDevice{}},
+	{[]EventType{EventHint}, Hint{}},
+	{[]EventType{EventMoveAnalysis}, MoveAnalysis{}},
+	{[]EventType{EventAccount}, Account{}},
+	{[]EventType{EventQueueStatus}, QueueStatus{}},
+	{[]EventType{EventShutdown}, ServerShutdown{}},
+	{[]EventType{EventError}, Error{}},
+}
+
+// details lists the types that may appear in GameState.Details.
+var details = []interface{}{PlayerJoinedDetail{}, PlayDetail{}, DeadCardDetail{}, DrawDetail{}, NoticeDetail{}}
+
+// Schema builds a JSON Schema (draft 2020-12) for the handshake, every client
+// action and every server event, generated from the Go types in this package.
+func Schema() map[string]interface{} {
+	b := &schemaBuilder{defs: map[string]interface{}{}}
+
+	actions := make([]interface{}, 0, len(actionPayloads))
+	for _, action := range actionOrder {
+		actions = append(actions, map[string]interface{}{
+			"type":     "object",
+			"required": []string{"actionType"},
+			"properties": map[string]interface{}{
+				"actionType": map[string]interface{}{"const": string(action)},
+				"requestId":  map[string]interface{}{"type": "string"},
+				"payload":    b.typeSchema(reflect.TypeOf(actionPayloads[action]())),
+			},
+		})
+	}
+
+	serverEvents := make([]interface{}, 0, len(events))
+	for _, event := range events {
+		typeNames := make([]string, len(event.Types))
+		for i, t := range event.Types {
+			typeNames[i] = string(t)
+		}
+		serverEvents = append(serverEvents, map[string]interface{}{
+			"allOf": []interface{}{
+				b.typeSchema(reflect.TypeOf(event.Value)),
+				map[string]interface{}{"properties": map[string]interface{}{"type": map[string]interface{}{"enum": typeNames}}},
+			},
+		})
+	}
+
+	detailRefs := make([]interface{}, len(details))
+	for i, detail := range details {
+		detailRefs[i] = b.typeSchema(reflect.TypeOf(detail))
+	}
+	b.defs["GameStateDetails"] = map[string]interface{}{"anyOf": detailRefs}
+
+	return map[string]interface{}{
+		"$schema":         "https://json-schema.org/draft/2020-12/schema",
+		"$id":             SchemaID,
+		"title":           "Sequence game WebSocket protocol",
+		"protocolVersion": Version,
+		"$defs":           b.defs,
+		"properties": map[string]interface{}{
+			"hello":         b.typeSchema(reflect.TypeOf(Hello{})),
+			"clientMessage": map[string]interface{}{"oneOf": actions},
+			"serverMessage": map[string]interface{}{"oneOf": serverEvents},
+		},
+	}
+}
+
+// SchemaJSON returns the indented JSON encoding of Schema.
+func SchemaJSON() ([]byte, error) {
+	out, err := json.MarshalIndent(Schema(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// actionOrder lists the client actions in documentation order.
+var actionOrder = []ActionType{ActionCreateGame, ActionJoinGame, ActionStartGame, ActionPlay, ActionDeadCard, ActionDrawCard, ActionResync, ActionRevealHand, ActionRequestHint, ActionSetAnalysis, ActionRegister, ActionLogin, ActionLogout, ActionUpdateProfile, ActionQueueForMatch, ActionLeaveQueue}
+
+// schemaBuilder turns Go types into JSON Schema, collecting named structs under $defs.
+type schemaBuilder struct {
+	defs map[string]interface{}
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+func (b *schemaBuilder) typeSchema(t reflect.Type) map[string]interface{} {
+	if values, ok := enumValues[t]; ok {
+		return map[string]interface{}{"type": "string", "enum": values}
+	}
+	if t.Kind() != reflect.Ptr && t.Implements(textMarshalerType) {
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return b.typeSchema(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 { // json.RawMessage and []byte
+			return map[string]interface{}{}
+		}
+		return map[string]interface{}{"type": "array", "items": b.typeSchema(t.Elem())}
+	case reflect.Array:
+		return map[string]interface{}{"type": "array", "items": b.typeSchema(t.Elem()), "minItems": t.Len(), "maxItems": t.Len()}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.typeSchema(t.Elem())}
+	case reflect.Struct:
+		name := t.Name()
+		if _, seen := b.defs[name]; !seen {
+			b.defs[name] = nil // Reserve the name so recursive types terminate
+			b.defs[name] = b.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	b.addFields(t, properties, &required)
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// addFields adds the JSON-visible fields of t, flattening embedded structs like encoding/json does.
+func (b *schemaBuilder) addFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			b.addFields(field.Type, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fieldSchema := b.typeSchema(field.Type)
+		if (t == reflect.TypeOf(GameState{}) || t == reflect.TypeOf(StatePatch{})) && name == "details" {
+			fieldSchema = map[string]interface{}{"$ref": "#/$defs/GameStateDetails"}
+		}
+		properties[name] = fieldSchema
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+// Package ratings rates players from their ranked games and ranks them on
+// leaderboards. Head-to-head games use Elo. Games of three or more use its usual
+// multiplayer generalisation: each player is scored against every other as if
+// they had played them alone, the winner beating each opponent and the others
+// drawing among themselves, and the K-factor is shared out between the pairings.
+// Team games are rated the same way between the teams: each team plays at the mean
+// of its players' ratings, and every player of a team moves by the team's change.
+//
+// Each rated game's rating changes are appended to a JSON Lines file, which is
+// both the rating history and, replayed, the current ratings.
+package ratings
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"sequence-game/history"
+)
+
+// Rating parameters.
+const (
+	InitialRating = 1500.0 // Rating of a player's first rated game
+	KFactor       = 32.0   // Most a rating can move in one game
+)
+
+// Mode is a kind of game with its own ladder.
+type Mode string
+
+const (
+	ModeDuel       Mode = "1v1"    // Two players
+	ModeFreeForAll Mode = "ffa"    // Three players or more
+	ModeTwoTeams   Mode = "2v2"    // Two teams
+	ModeThreeTeams Mode = "3-team" // Three teams
+)
+
+// ParseMode checks a mode name sent by a client.
+func ParseMode(name string) (Mode, error) {
+	switch mode := Mode(name); mode {
+	case ModeDuel, ModeFreeForAll, ModeTwoTeams, ModeThreeTeams:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown game mode %q (use %q, %q, %q or %q)", name, ModeDuel, ModeFreeForAll, ModeTwoTeams, ModeThreeTeams)
+}
+
+// ModeOf is the ladder a game is rated on.
+func ModeOf(r history.Record) Mode {
+	if len(r.Players) > 0 && r.Players[0].Team != "" {
+		if len(sidesOf(r)) == 2 {
+			return ModeTwoTeams
+		}
+		return ModeThreeTeams
+	}
+	if len(r.Players) == 2 {
+		return ModeDuel
+	}
+	return ModeFreeForAll
+}
+
+// sidesOf lists the sides of a game in turn order: its teams, or its players.
+func sidesOf(r history.Record) []string {
+	var sides []string
+	seen := make(map[string]bool)
+	for _, p := range r.Players {
+		if !seen[p.Side()] {
+			seen[p.Side()] = true
+			sides = append(sides, p.Side())
+		}
+	}
+	return sides
+}
+
+// Rated reports whether a game counts towards ratings: a finished ranked game of
+// at least two sides, all of its players people, played without hints and not
+// hot-seat.
+func Rated(r history.Record) bool {
+	if !r.Ranked || r.HotSeat || r.Rules.Hints || r.Result == history.ResultInProgress || len(sidesOf(r)) < 2 {
+		return false
+	}
+	for _, p := range r.Players {
+		if p.Bot {
+			return false
+		}
+	}
+	return true
+}
+
+// Change is one player's rating change from a game.
+type Change struct {
+	PlayerID string         `json:"playerId"`
+	Name     string         `json:"name"`
+	Result   history.Result `json:"result"`
+	Before   float64        `json:"before"`
+	After    float64        `json:"after"`
+}
+
+// Entry is a rated game in the rating history.
+type Entry struct {
+	GameID     string    `json:"gameId"`
+	Mode       Mode      `json:"mode"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Changes    []Change  `json:"changes"` // In turn order
+}
+
+// Standing is a player's place on a leaderboard.
+type Standing struct {
+	Rank     int     `json:"rank"`
+	PlayerID string  `json:"playerId"`
+	Name     string  `json:"name"` // Name in their latest rated game
+	Rating   float64 `json:"rating"`
+	Change   float64 `json:"change"` // Rating gained or lost over the leaderboard's window
+	Games    int     `json:"games"`
+	Wins     int     `json:"wins"`
+	Losses   int     `json:"losses"`
+	Draws    int     `json:"draws"`
+}
+
+// Store holds the rating history, appending each rated game to its file.
+type Store struct {
+	mu      sync.Mutex
+	path    string
+	entries []Entry
+	rated   map[string]bool             // GameIDs already rated
+	current map[Mode]map[string]float64 // Latest rating by mode, then PlayerID
+}
+
+// Open loads the rating history kept at path; a missing file is an empty history.
+// An empty path keeps the history in memory only.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, rated: make(map[string]bool), current: make(map[Mode]map[string]float64)}
+	if path == "" {
+		return s, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		s.apply(e)
+	}
+	return s, scanner.Err()
+}
+
+// Rate updates the players' ratings from a finished game and records the changes.
+// It reports false, changing nothing, for a game that is not rated or was rated
+// already.
+func (s *Store) Rate(r history.Record) (Entry, bool, error) {
+	if !Rated(r) {
+		return Entry{}, false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rated[r.GameID] {
+		return Entry{}, false, nil
+	}
+
+	e := Entry{GameID: r.GameID, Mode: ModeOf(r), FinishedAt: r.FinishedAt}
+	sides := sidesOf(r)
+	rating := make(map[string]float64, len(sides)) // Each side's mean rating
+	result := make(map[string]history.Result, len(sides))
+	size := make(map[string]int, len(sides))
+	for _, p := range r.Players {
+		before := s.rating(e.Mode, p.PlayerID)
+		e.Changes = append(e.Changes, Change{PlayerID: p.PlayerID, Name: p.Name, Result: p.Result, Before: before})
+		rating[p.Side()] += before
+		result[p.Side()] = p.Result
+		size[p.Side()]++
+	}
+	for _, side := range sides {
+		rating[side] /= float64(size[side])
+	}
+	k := KFactor / float64(len(sides)-1)
+	change := make(map[string]float64, len(sides))
+	for _, me := range sides {
+		for _, them := range sides {
+			if me != them {
+				change[me] += k * (score(result[me], result[them]) - expected(rating[me], rating[them]))
+			}
+		}
+	}
+	for i, p := range r.Players {
+		c := &e.Changes[i]
+		c.After = math.Round((c.Before+change[p.Side()])*10) / 10
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	if err := s.append(data); err != nil {
+		return Entry{}, false, err
+	}
+	s.apply(e)
+	return e, true, nil
+}
+
+// append adds a line to the store's file, if it has one.
+func (s *Store) append(line []byte) error {
+	if s.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Rating returns a player's current rating in a mode.
+func (s *Store) Rating(mode Mode, playerID string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rating(mode, playerID)
+}
+
+// Leaderboard ranks the players of a mode who played rated games since the given
+// time (every game when it is zero) by their latest rating, highest first. The
+// record and rating change count only the games in that window.
+func (s *Store) Leaderboard(mode Mode, since time.Time) []Standing {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	byPlayer := make(map[string]*Standing)
+	start := make(map[string]float64) // Rating before the first game in the window
+	for _, e := range s.entries {
+		if e.Mode != mode || e.FinishedAt.Before(since) {
+			continue
+		}
+		for _, c := range e.Changes {
+			st := byPlayer[c.PlayerID]
+			if st == nil {
+				st = &Standing{PlayerID: c.PlayerID}
+				byPlayer[c.PlayerID] = st
+				start[c.PlayerID] = c.Before
+			}
+			st.Name, st.Rating = c.Name, c.After
+			st.Games++
+			switch c.Result {
+			case history.ResultWin:
+				st.Wins++
+			case history.ResultLoss:
+				st.Losses++
+			case history.ResultDraw:
+				st.Draws++
+			}
+		}
+	}
+
+	standings := make([]Standing, 0, len(byPlayer))
+	for id, st := range byPlayer {
+		st.Change = math.Round((st.Rating-start[id])*10) / 10
+		standings = append(standings, *st)
+	}
+	sort.Slice(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if a.Rating != b.Rating {
+			return a.Rating > b.Rating
+		}
+		if a.Games != b.Games {
+			return a.Games > b.Games
+		}
+		return a.PlayerID < b.PlayerID
+	})
+	for i := range standings {
+		standings[i].Rank = i + 1
+	}
+	return standings
+}
+
+func (s *Store) rating(mode Mode, playerID string) float64 {
+	if rating, ok := s.current[mode][playerID]; ok {
+		return rating
+	}
+	return InitialRating
+}
+
+// apply adds a rated game to the history and moves the players' ratings.
+func (s *Store) apply(e Entry) {
+	if s.current[e.Mode] == nil {
+		s.current[e.Mode] = make(map[string]float64)
+	}
+	for _, c := range e.Changes {
+		s.current[e.Mode][c.PlayerID] = c.After
+	}
+	s.rated[e.GameID] = true
+	s.entries = append(s.entries, e)
+}
+
+// expected is the Elo expected score of a player rated mine against one rated theirs.
+func expected(mine, theirs float64) float64 {
+	return 1 / (1 + math.Pow(10, (theirs-mine)/400))
+}
+
+// score is what a player earns against one opponent: the winner beats everyone and
+// players with the same result draw.
+func score(mine, theirs history.Result) float64 {
+	switch {
+	case mine == theirs:
+		return 0.5
+	case mine == history.ResultWin:
+		return 1
+	case theirs == history.ResultWin:
+		return 0
+	}
+	return 0.5
+}
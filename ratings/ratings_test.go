@@ -0,0 +1,153 @@
+package ratings
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"sequence-game/history"
+)
+
+// record is a finished ranked game between the players, in turn order, with
+// results given as "W", "L" or "D".
+func record(gameID string, results ...string) history.Record {
+	r := history.Record{GameID: gameID, Ranked: true, Result: history.ResultDraw, FinishedAt: time.Now()}
+	for i, result := range results {
+		p := history.Participant{PlayerID: fmt.Sprintf("p%d", i+1), Seat: i + 1}
+		switch result {
+		case "W":
+			p.Result = history.ResultWin
+			r.Result, r.Winner = history.ResultWin, p.PlayerID
+		case "L":
+			p.Result = history.ResultLoss
+		default:
+			p.Result = history.ResultDraw
+		}
+		r.Players = append(r.Players, p)
+	}
+	return r
+}
+
+// teamRecord is record with the players split into teams, seated in turn.
+func teamRecord(gameID string, teams int, results ...string) history.Record {
+	r := record(gameID, results...)
+	for i := range r.Players {
+		r.Players[i].Team = fmt.Sprintf("team%d", i%teams+1)
+	}
+	return r
+}
+
+func TestModeOf(t *testing.T) {
+	tests := []struct {
+		record history.Record
+		want   Mode
+	}{
+		{record("g", "W", "L"), ModeDuel},
+		{record("g", "W", "L", "L"), ModeFreeForAll},
+		{teamRecord("g", 2, "W", "L", "W", "L"), ModeTwoTeams},
+		{teamRecord("g", 3, "W", "L", "L", "W", "L", "L"), ModeThreeTeams},
+	}
+	for _, tt := range tests {
+		if got := ModeOf(tt.record); got != tt.want {
+			t.Errorf("ModeOf(%d players) = %s, want %s", len(tt.record.Players), got, tt.want)
+		}
+	}
+}
+
+func TestRated(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(r *history.Record)
+		want bool
+	}{
+		{name: "ranked", edit: func(r *history.Record) {}, want: true},
+		{name: "casual", edit: func(r *history.Record) { r.Ranked = false }},
+		{name: "hot-seat", edit: func(r *history.Record) { r.HotSeat = true }},
+		{name: "with hints", edit: func(r *history.Record) { r.Rules.Hints = true }},
+		{name: "unfinished", edit: func(r *history.Record) { r.Result = history.ResultInProgress }},
+		{name: "alone", edit: func(r *history.Record) { r.Players = r.Players[:1] }},
+		{name: "against a bot", edit: func(r *history.Record) { r.Players[1].Bot = true }},
+		{name: "one team", edit: func(r *history.Record) { r.Players[0].Team, r.Players[1].Team = "team1", "team1" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := record("g", "W", "L")
+			tt.edit(&r)
+			if got := Rated(r); got != tt.want {
+				t.Errorf("Rated() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateZeroSum(t *testing.T) {
+	tests := []struct {
+		name    string
+		teams   int
+		warmup  []string // A first game, so the players start from different ratings
+		results []string
+	}{
+		{name: "duel, even", results: []string{"W", "L"}},
+		{name: "duel, upset", warmup: []string{"W", "L"}, results: []string{"L", "W"}},
+		{name: "duel, draw", warmup: []string{"L", "W"}, results: []string{"D", "D"}},
+		{name: "three players, even", results: []string{"L", "W", "L"}},
+		{name: "three players, favourite wins", warmup: []string{"W", "L", "L"}, results: []string{"W", "L", "L"}},
+		{name: "three players, upset", warmup: []string{"W", "L", "L"}, results: []string{"L", "L", "W"}},
+		{name: "three players, draw", warmup: []string{"L", "W", "L"}, results: []string{"D", "D", "D"}},
+		{name: "two teams, even", teams: 2, results: []string{"W", "L", "W", "L"}},
+		{name: "two teams, upset", teams: 2, warmup: []string{"W", "L", "W", "L"}, results: []string{"L", "W", "L", "W"}},
+		{name: "three teams, upset", teams: 3, warmup: []string{"W", "L", "L", "W", "L", "L"}, results: []string{"L", "L", "W", "L", "L", "W"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Open("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			game := func(gameID string, results []string) history.Record {
+				if tt.teams > 0 {
+					return teamRecord(gameID, tt.teams, results...)
+				}
+				return record(gameID, results...)
+			}
+			if tt.warmup != nil {
+				if _, ok, err := s.Rate(game("warmup", tt.warmup)); !ok || err != nil {
+					t.Fatalf("warm-up game not rated: %t, %v", ok, err)
+				}
+			}
+			e, ok, err := s.Rate(game("game", tt.results))
+			if !ok || err != nil {
+				t.Fatalf("game not rated: %t, %v", ok, err)
+			}
+
+			sum := 0.0
+			for i, c := range e.Changes {
+				if tt.teams > 0 && i >= tt.teams {
+					if mate := e.Changes[i-tt.teams]; c.After-c.Before != mate.After-mate.Before {
+						t.Errorf("teammates %s and %s moved by %.1f and %.1f", mate.PlayerID, c.PlayerID, mate.After-mate.Before, c.After-c.Before)
+					}
+				}
+				delta := c.After - c.Before
+				sum += delta
+				switch c.Result {
+				case history.ResultWin:
+					if delta <= 0 {
+						t.Errorf("winner %s went from %.1f to %.1f", c.PlayerID, c.Before, c.After)
+					}
+				case history.ResultLoss:
+					if delta >= 0 {
+						t.Errorf("loser %s went from %.1f to %.1f", c.PlayerID, c.Before, c.After)
+					}
+				}
+				if got := s.Rating(e.Mode, c.PlayerID); got != c.After {
+					t.Errorf("Rating(%s) = %.1f, want %.1f", c.PlayerID, got, c.After)
+				}
+			}
+			// Each rating is rounded to a tenth, so the sum may be off by half of one per player
+			if tolerance := 0.05*float64(len(e.Changes)) + 1e-9; math.Abs(sum) > tolerance {
+				t.Errorf("rating changes sum to %.2f, want 0 (±%.2f): %+v", sum, tolerance, e.Changes)
+			}
+		})
+	}
+}
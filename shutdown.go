@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"sequence-game/game"
+	"sequence-game/protocol"
+)
+
+// --- Graceful Shutdown ---
+
+// SnapshotsDir is where the state of the games in progress is saved when the
+// server stops, under the data directory.
+const SnapshotsDir = "snapshots"
+
+var (
+	shuttingDown  atomic.Bool // Set once the server has begun to stop; no new games are started and no moves played
+	connections   = make(map[*wsConn]bool)
+	connectionsMu sync.Mutex
+	connectionsWG sync.WaitGroup // One per running handleWebSocket
+)
+
+// trackConnection registers a WebSocket until the returned func is called, so it
+// can be told about a shutdown and closed.
+func trackConnection(conn *wsConn) func() {
+	connectionsMu.Lock()
+	connections[conn] = true
+	connectionsMu.Unlock()
+	connectionsWG.Add(1)
+	return func() {
+		connectionsMu.Lock()
+		delete(connections, conn)
+		connectionsMu.Unlock()
+		connectionsWG.Done()
+	}
+}
+
+// gameSnapshot is the state of a game saved at shutdown: the game itself, with the
+// cards it keeps from players, and the table as they last saw it, whose version
+// later broadcasts carry on from.
+type gameSnapshot struct {
+	Game        json.RawMessage        `json:"game"`
+	Hands       map[string][]game.Card `json:"hands"`
+	DrawPile    []game.Card            `json:"drawPile"`
+	DiscardPile []game.Card            `json:"discardPile"`
+	State       protocol.GameState     `json:"state"`
+	Saved       time.Time              `json:"saved"`
+}
+
+// shutdown stops the server: it stops starting games and turns /readyz to 503
+// for cfg.ShutdownDrain, so readiness probes see it and move players elsewhere.
+// Then, within cfg.ShutdownTimeout, it stops accepting connections, tells every
+// connection why, saves the games in progress and closes the WebSockets with
+// 1001 (going away). Game logs and the stores are written as they change, so
+// they need no flushing.
+func shutdown(srv *http.Server) {
+	shuttingDown.Store(true)
+	if drain := time.Duration(cfg.ShutdownDrain); drain > 0 {
+		log.Printf("Shutting down: draining for %s, until readiness probes see /readyz fail...", drain)
+		time.Sleep(drain)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout))
+	defer cancel()
+	log.Printf("Shutting down within %s...", time.Duration(cfg.ShutdownTimeout))
+
+	// Stops listening and waits for API requests; WebSockets are left to us
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Error stopping the HTTP server: %v", err)
+	}
+
+	notice := protocol.ServerShutdown{Type: protocol.EventShutdown, Message: "The server is shutting down."}
+	if cfg.RestartIn > 0 {
+		restartAt := time.Now().Add(time.Duration(cfg.RestartIn)).UTC().Truncate(time.Second)
+		notice.RestartAt = &restartAt
+		notice.Message = fmt.Sprintf("The server is restarting and should be back by %s.", restartAt.Format(time.RFC3339))
+	}
+	notifyShutdown(notice)
+	saveSnapshots()
+
+	connectionsMu.Lock()
+	for conn := range connections {
+		closing := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+		if err := conn.WriteControl(websocket.CloseMessage, closing, time.Now().Add(time.Second)); err != nil {
+			conn.Close()
+		}
+	}
+	connectionsMu.Unlock()
+
+	closed := make(chan struct{})
+	go func() {
+		connectionsWG.Wait()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		log.Printf("All connections closed.")
+	case <-ctx.Done():
+		connectionsMu.Lock()
+		log.Printf("Shutdown deadline reached; dropping %d connection(s).", len(connections))
+		for conn := range connections {
+			conn.Close()
+		}
+		connectionsMu.Unlock()
+	}
+}
+
+// notifyShutdown sends the notice to every connection. Players at a table are
+// written to under the game lock, like any broadcast.
+func notifyShutdown(notice protocol.ServerShutdown) {
+	notified := make(map[game.Conn]bool)
+	gamesMu.Lock()
+	open := make([]*game.Game, 0, len(games))
+	for _, g := range games {
+		open = append(open, g)
+	}
+	gamesMu.Unlock()
+	for _, g := range open {
+		g.Lock()
+		for _, p := range g.Players {
+			if p.Conn == nil || !p.IsConnected || notified[p.Conn] {
+				continue
+			}
+			notified[p.Conn] = true
+			if err := p.Conn.WriteJSON(notice); err != nil {
+				log.Printf("Error sending the shutdown notice to %s: %v", p.ID, err)
+			}
+		}
+		g.Unlock()
+	}
+
+	connectionsMu.Lock()
+	defer connectionsMu.Unlock()
+	for conn := range connections {
+		if !notified[conn] {
+			if err := conn.WriteJSON(notice); err != nil {
+				log.Printf("Error sending the shutdown notice: %v", err)
+			}
+		}
+	}
+}
+
+// saveSnapshots writes the state of every game in progress to SnapshotsDir, and
+// notes the shutdown in each game's log. Nothing is saved with memory storage.
+func saveSnapshots() {
+	gamesMu.Lock()
+	open := make([]*game.Game, 0, len(games))
+	for _, g := range games {
+		open = append(open, g)
+	}
+	gamesMu.Unlock()
+
+	dir := cfg.dataFile(SnapshotsDir)
+	saved := 0
+	for _, g := range open {
+		g.Lock()
+		inProgress := g.GamePhase == "InProgress"
+		snapshot := gameSnapshot{
+			Hands:    make(map[string][]game.Card),
+			DrawPile: slices.Clone(g.DrawPile), DiscardPile: slices.Clone(g.DiscardPile),
+			State: protocol.NewGameState(g, protocol.EventGameUpdate, nil), Saved: time.Now(),
+		}
+		snapshot.State.Version = syncFor(g.ID).last.Version
+		for id, p := range g.Players {
+			snapshot.Hands[id] = slices.Clone(p.Hand)
+		}
+		var err error
+		snapshot.Game, err = json.Marshal(g)
+		g.Unlock()
+		if !inProgress {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error saving a snapshot of game %s: %v", g.ID, err)
+			continue
+		}
+		if dir == "" {
+			_ = writeGameLog(g.ID, "Server shut down; the game was not saved")
+			continue
+		}
+		file := filepath.Join(dir, g.ID+".json")
+		if err := writeSnapshot(file, snapshot); err != nil {
+			log.Printf("Error saving a snapshot of game %s: %v", g.ID, err)
+			continue
+		}
+		saved++
+		_ = writeGameLog(g.ID, fmt.Sprintf("Server shut down; game state saved to %s", file))
+	}
+	if saved > 0 {
+		log.Printf("Saved snapshots of %d game(s) in progress to %s.", saved, dir)
+	}
+}
+
+// restoreSnapshots reopens the games saved by saveSnapshots when the server last
+// stopped, and deletes each snapshot once its game is open again. Players take
+// their seats back by joining the game, and a game nobody returns to is closed
+// after IdleGameTimeout like any other.
+func restoreSnapshots() {
+	dir := cfg.dataFile(SnapshotsDir)
+	if dir == "" {
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		log.Printf("Error listing game snapshots: %v", err)
+		return
+	}
+	restored := 0
+	for _, file := range files {
+		g, snapshot, err := readSnapshot(file)
+		if err != nil {
+			log.Printf("Error restoring the game saved in %s: %v", file, err)
+			continue
+		}
+		gamesMu.Lock()
+		games[g.ID] = g
+		gamesMu.Unlock()
+		g.Lock()
+		ts := syncFor(g.ID)
+		ts.last = snapshot.State
+		ts.active = time.Now()
+		g.Unlock()
+		if err := os.Remove(file); err != nil {
+			log.Printf("Error deleting the snapshot of game %s: %v", g.ID, err)
+		}
+		restored++
+		_ = writeGameLog(g.ID, fmt.Sprintf("Server restarted; game restored from the snapshot saved at %s", snapshot.Saved.Format(time.RFC3339)))
+		if g.PendingDraw != "" {
+			scheduleAutoDraw(g, g.PendingDraw)
+		}
+	}
+	if restored > 0 {
+		log.Printf("Restored %d game(s) in progress from %s.", restored, dir)
+	}
+}
+
+// readSnapshot reads the game saved in file. Its players are offline until they
+// join again.
+func readSnapshot(file string) (*game.Game, gameSnapshot, error) {
+	var snapshot gameSnapshot
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, snapshot, err
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, snapshot, err
+	}
+	if snapshot.Game == nil {
+		return nil, snapshot, errors.New("the snapshot holds no game")
+	}
+	g := new(game.Game)
+	if err := json.Unmarshal(snapshot.Game, g); err != nil {
+		return nil, snapshot, err
+	}
+	if g.GamePhase != "InProgress" || len(g.Players) == 0 {
+		return nil, snapshot, fmt.Errorf("game %s is not in progress", g.ID)
+	}
+	g.DrawPile, g.DiscardPile = snapshot.DrawPile, snapshot.DiscardPile
+	for id, p := range g.Players {
+		p.Hand = snapshot.Hands[id]
+		p.Conn, p.IsConnected = nil, false
+	}
+	return g, snapshot, nil
+}
+
+// writeSnapshot writes a snapshot to file, replacing it only once the new
+// contents are safely written.
+func writeSnapshot(file string, snapshot gameSnapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
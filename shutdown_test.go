@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"sequence-game/game"
+	"sequence-game/protocol"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	saved := cfg
+	t.Cleanup(func() { cfg = saved })
+	cfg.Storage, cfg.DataDir, cfg.LogsDir = StorageFile, t.TempDir(), ""
+
+	rules, err := game.RulesPreset("Tournament")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules.DrawTimeoutSeconds = 1
+	g := game.NewGame("a", "A", 2, 0, rules)
+	for _, id := range []string{"a", "b"} {
+		if _, err := g.AddPlayer(id, id, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.StartGame("a"); err != nil {
+		t.Fatal(err)
+	}
+	broadcastGameState(g, protocol.EventGameStarted, nil)
+	player := g.CurrentPlayerID()
+	if err := g.PlayAction(player, g.LegalMoves(player)[0]); err != nil {
+		t.Fatal(err)
+	}
+	broadcastGameState(g, protocol.EventGameUpdate, nil) // The draw is left pending
+
+	want := g.Clone()
+	g.Lock()
+	version := syncFor(g.ID).last.Version
+	g.Unlock()
+	if want.PendingDraw != player || version == 0 {
+		t.Fatalf("pending draw %q at version %d before saving", want.PendingDraw, version)
+	}
+
+	gamesMu.Lock()
+	games = map[string]*game.Game{g.ID: g}
+	gamesMu.Unlock()
+	saveSnapshots()
+	gamesMu.Lock()
+	games = make(map[string]*game.Game)
+	gamesMu.Unlock()
+	tableSyncsMu.Lock()
+	delete(tableSyncs, g.ID)
+	tableSyncsMu.Unlock()
+	restoreSnapshots()
+
+	gamesMu.Lock()
+	restored := games[g.ID]
+	gamesMu.Unlock()
+	if restored == nil {
+		t.Fatal("the game was not restored")
+	}
+	restored.Lock()
+	for id, p := range want.Players {
+		if got := restored.Players[id]; got == nil || !reflect.DeepEqual(got.Hand, p.Hand) || got.IsConnected {
+			t.Errorf("player %s restored as %+v, want hand %v and offline", id, got, p.Hand)
+		}
+	}
+	if !reflect.DeepEqual(restored.DrawPile, want.DrawPile) || !reflect.DeepEqual(restored.DiscardPile, want.DiscardPile) {
+		t.Error("the draw or discard pile changed")
+	}
+	if !reflect.DeepEqual(restored.Board, want.Board) || restored.MoveCount != want.MoveCount || restored.CurrentTurnIndex != want.CurrentTurnIndex {
+		t.Error("the board or turn changed")
+	}
+	if got := syncFor(g.ID).last.Version; got != version {
+		t.Errorf("table restored at version %d, want %d", got, version)
+	}
+	restored.Unlock()
+
+	// The pending draw is rescheduled, and happens after the rules' timeout
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		restored.Lock()
+		pending, hand := restored.PendingDraw, len(restored.Players[player].Hand)
+		restored.Unlock()
+		if pending == "" {
+			if hand != len(want.Players[player].Hand)+1 {
+				t.Errorf("auto-draw left %s with %d cards, want %d", player, hand, len(want.Players[player].Hand)+1)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the pending draw was not made after restoring the game")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
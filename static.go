@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// --- Web Client Assets ---
+
+//go:embed static
+var embeddedStatic embed.FS
+
+// AssetsPlaceholder is written in the pages in place of the versioned assets
+// route, e.g. <script src="/assets/_/app.js">, and replaced when they are served.
+const AssetsPlaceholder = "/assets/_/"
+
+// VendorManifest lists the third-party scripts vendored under static/vendor, with
+// the pinned versions and SHA-256 checksums of the files cmd/sequence-vendor
+// downloads.
+const VendorManifest = "vendor/assets.json"
+
+// clientAssets are the files of the web client, embedded in the binary or read
+// from a directory on disk.
+type clientAssets struct {
+	files    fs.FS
+	embedded bool
+	version  string            // Hash of the embedded files; "dev" on disk, where they may change
+	missing  map[string]string // Vendored files not downloaded yet, by path, with the CDN URL browsers load them from
+}
+
+// vendorAsset is an entry of the VendorManifest.
+type vendorAsset struct {
+	File    string `json:"file"`
+	Version string `json:"version"`
+	URL     string `json:"url"`
+	SHA256  string `json:"sha256,omitempty"`
+}
+
+var assets *clientAssets
+
+// openAssets opens the embedded client, or the one in dir if it is not empty.
+// With requireVendored, vendored scripts that have not been downloaded and pinned
+// stop the server instead of being loaded from their CDN.
+func openAssets(dir string, requireVendored bool) (*clientAssets, error) {
+	if dir != "" {
+		a := &clientAssets{files: os.DirFS(dir), version: "dev"}
+		if _, err := fs.Stat(a.files, ClientHTMLFile); err != nil {
+			return nil, fmt.Errorf("static dir %s has no %s: %w", dir, ClientHTMLFile, err)
+		}
+		return a, a.checkVendored(requireVendored)
+	}
+	files, err := fs.Sub(embeddedStatic, "static")
+	if err != nil {
+		return nil, err
+	}
+	a := &clientAssets{files: files, embedded: true}
+	hash := sha256.New()
+	err = fs.WalkDir(files, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(files, name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00", name, len(data))
+		hash.Write(data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.version = hex.EncodeToString(hash.Sum(nil))[:12]
+	return a, a.checkVendored(requireVendored)
+}
+
+// checkVendored checks the vendored scripts against the SHA-256 checksums pinned
+// in the VendorManifest; a script that does not match is an error. One that has
+// not been downloaded is loaded by browsers from its CDN, which needs internet
+// access, and one without a checksum is served unchecked, both with a warning.
+// With requireVendored they are errors too.
+func (a *clientAssets) checkVendored(requireVendored bool) error {
+	data, err := fs.ReadFile(a.files, VendorManifest)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var list []vendorAsset
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("reading %s: %w", VendorManifest, err)
+	}
+	a.missing = make(map[string]string)
+	for _, v := range list {
+		file := path.Join(path.Dir(VendorManifest), v.File)
+		data, err := fs.ReadFile(a.files, file)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && requireVendored:
+			return fmt.Errorf("%s %s is not vendored: run `make vendor-assets`, or start without -require-vendored to load it from %s", v.File, v.Version, v.URL)
+		case errors.Is(err, fs.ErrNotExist):
+			a.missing[file] = v.URL
+			log.Printf("Warning: %s %s is not vendored; browsers will load it from %s. Run `make vendor-assets` to play without internet access.", v.File, v.Version, v.URL)
+			continue
+		case err != nil:
+			return err
+		case v.SHA256 == "" && requireVendored:
+			return fmt.Errorf("%s %s has no checksum pinned in %s: check the file and run `go run ./cmd/sequence-vendor -pin`", v.File, v.Version, VendorManifest)
+		case v.SHA256 == "":
+			log.Printf("Warning: %s %s has no checksum pinned in %s and is served unchecked. Check the file and run `go run ./cmd/sequence-vendor -pin`.", v.File, v.Version, VendorManifest)
+			continue
+		}
+		sum := sha256.Sum256(data)
+		if hash := hex.EncodeToString(sum[:]); hash != v.SHA256 {
+			return fmt.Errorf("%s %s has SHA-256 %s, but %s pins %s", file, v.Version, hash, VendorManifest, v.SHA256)
+		}
+	}
+	return nil
+}
+
+// servePage serves an HTML page of the client with the assets route filled in.
+// Pages are revalidated on every load, so a new version is picked up at once.
+func (a *clientAssets) servePage(w http.ResponseWriter, r *http.Request, name string) {
+	data, err := fs.ReadFile(a.files, name)
+	if err != nil {
+		log.Printf("Client page %s not found: %v", name, err)
+		http.Error(w, "Page not found", http.StatusNotFound)
+		return
+	}
+	data = bytes.ReplaceAll(data, []byte(AssetsPlaceholder), []byte("/assets/"+a.version+"/"))
+	sum := sha256.Sum256(data)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
+
+// serveAsset answers GET /assets/{version}/{file...}. The current version of an
+// embedded file never changes, so browsers may keep it for good; anything else
+// (an older version, or files on disk) is revalidated.
+func (a *clientAssets) serveAsset(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+	if strings.HasSuffix(file, ".html") {
+		http.NotFound(w, r) // Pages are served by their own routes
+		return
+	}
+	if url, ok := a.missing[file]; ok {
+		http.Redirect(w, r, url, http.StatusFound)
+		return
+	}
+	if a.embedded && r.PathValue("version") == a.version {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	http.ServeFileFS(w, r, a.files, file)
+}
+
+// serveClient serves the web client.
+func serveClient(w http.ResponseWriter, r *http.Request) {
+	assets.servePage(w, r, ClientHTMLFile)
+}
@@ -0,0 +1,416 @@
+package tournament
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// schedule draws up the matches known at the start: the whole bracket or round
+// robin, or the first Swiss round.
+func (t *Tournament) schedule() {
+	switch t.Format {
+	case SingleElimination:
+		t.bracket("R", "")
+	case DoubleElimination:
+		t.doubleBracket()
+	case RoundRobin:
+		t.roundRobin()
+	case Swiss:
+		if t.Rounds == 0 {
+			t.Rounds = int(math.Ceil(math.Log2(float64(len(t.Entrants)))))
+		}
+		if t.Rounds > len(t.Entrants)-1 {
+			t.Rounds = len(t.Entrants) - 1
+		}
+	}
+}
+
+// seedOrder lists the seeds of a bracket of size players in the order they are
+// drawn, so that the top seeds meet as late as possible: 1, 8, 4, 5, 2, 7, 3, 6.
+func seedOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		next := make([]int, 0, 2*len(order))
+		for _, seed := range order {
+			next = append(next, seed, 2*len(order)+1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+// bracket adds a knockout bracket of the entrants, its match IDs starting with
+// prefix, and returns its number of rounds. The empty places of a bracket whose
+// size is not a power of two are byes for the top seeds.
+func (t *Tournament) bracket(prefix, bracket string) int {
+	size := 1
+	for size < len(t.Entrants) {
+		size *= 2
+	}
+	order := seedOrder(size)
+	seat := func(seed int) Slot {
+		if seed > len(t.Entrants) {
+			return Slot{Bye: true}
+		}
+		return Slot{PlayerID: t.Entrants[seed-1].PlayerID}
+	}
+	rounds := 0
+	for matches, round := size/2, 1; matches >= 1; matches, round = matches/2, round+1 {
+		for i := 0; i < matches; i++ {
+			m := Match{ID: fmt.Sprintf("%s%d-%d", prefix, round, i+1), Bracket: bracket, Round: round, State: MatchPending}
+			if round == 1 {
+				m.A, m.B = seat(order[2*i]), seat(order[2*i+1])
+			} else {
+				m.A = Slot{From: fmt.Sprintf("%s%d-%d", prefix, round-1, 2*i+1)}
+				m.B = Slot{From: fmt.Sprintf("%s%d-%d", prefix, round-1, 2*i+2)}
+			}
+			t.Matches = append(t.Matches, m)
+		}
+		rounds = round
+	}
+	t.Rounds = rounds
+	return rounds
+}
+
+// doubleBracket adds a winners bracket, a losers bracket fed by its losers, and a
+// grand final between the two brackets' winners.
+func (t *Tournament) doubleBracket() {
+	k := t.bracket("W", "winners")
+	size := 1 << k
+	loserOf := func(round, i int) Slot { return Slot{From: fmt.Sprintf("W%d-%d", round, i), Loser: true} }
+	winnerOf := func(round, i int) Slot { return Slot{From: fmt.Sprintf("L%d-%d", round, i)} }
+	add := func(round, i int, a, b Slot) {
+		t.Matches = append(t.Matches, Match{ID: fmt.Sprintf("L%d-%d", round, i), Bracket: "losers", Round: round, A: a, B: b, State: MatchPending})
+	}
+
+	// Losers round 1 pairs the first round's losers; after that each winners round
+	// drops its losers in against the survivors, who then play each other
+	finalist := Slot{From: "W1-1", Loser: true}
+	if k >= 2 {
+		for i := 1; i <= size/4; i++ {
+			add(1, i, loserOf(1, 2*i-1), loserOf(1, 2*i))
+		}
+		for r := 2; r <= k; r++ {
+			count := size >> r
+			for i := 1; i <= count; i++ {
+				add(2*r-2, i, winnerOf(2*r-3, i), loserOf(r, count+1-i)) // Crossed over to put off rematches
+			}
+			if r < k {
+				for i := 1; i <= count/2; i++ {
+					add(2*r-1, i, winnerOf(2*r-2, 2*i-1), winnerOf(2*r-2, 2*i))
+				}
+			}
+		}
+		finalist = winnerOf(2*k-2, 1)
+	}
+	t.Matches = append(t.Matches, Match{
+		ID: "GF", Bracket: "final", Round: 2*k - 1, State: MatchPending,
+		A: Slot{From: fmt.Sprintf("W%d-1", k)}, B: finalist,
+	})
+	t.Rounds = 2*k - 1
+}
+
+// roundRobin schedules every pairing with the circle method: one player stays put
+// while the others rotate, and an odd field gives someone a rest each round.
+func (t *Tournament) roundRobin() {
+	ids := make([]string, 0, len(t.Entrants)+1)
+	for _, e := range t.Entrants {
+		ids = append(ids, e.PlayerID)
+	}
+	if len(ids)%2 == 1 {
+		ids = append(ids, "") // Resting
+	}
+	n := len(ids)
+	for round := 1; round < n; round++ {
+		number := 1
+		for i := 0; i < n/2; i++ {
+			a, b := ids[i], ids[n-1-i]
+			if a == "" || b == "" {
+				continue
+			}
+			t.Matches = append(t.Matches, Match{
+				ID: fmt.Sprintf("R%d-%d", round, number), Round: round, State: MatchPending,
+				A: Slot{PlayerID: a}, B: Slot{PlayerID: b},
+			})
+			number++
+		}
+		ids = append([]string{ids[0], ids[n-1]}, ids[1:n-1]...)
+	}
+	t.Rounds = n - 1
+}
+
+// pairSwiss adds the next Swiss round: players in standings order each meet the
+// next player they have not met yet, and with an odd field the lowest-ranked
+// player who has not had a bye gets one.
+func (t *Tournament) pairSwiss() {
+	round := t.Round
+	met := make(map[[2]string]bool)
+	byes := make(map[string]bool)
+	for _, m := range t.Matches {
+		if m.B.Bye {
+			byes[m.A.PlayerID] = true
+		}
+		met[[2]string{m.A.PlayerID, m.B.PlayerID}] = true
+		met[[2]string{m.B.PlayerID, m.A.PlayerID}] = true
+	}
+
+	var order []string
+	for _, st := range t.Standings() {
+		order = append(order, st.PlayerID)
+	}
+	number := 1
+	add := func(a, b Slot) {
+		t.Matches = append(t.Matches, Match{ID: fmt.Sprintf("R%d-%d", round, number), Round: round, State: MatchPending, A: a, B: b})
+		number++
+	}
+	if len(order)%2 == 1 {
+		bye := len(order) - 1
+		for i := len(order) - 1; i >= 0; i-- {
+			if !byes[order[i]] {
+				bye = i
+				break
+			}
+		}
+		add(Slot{PlayerID: order[bye]}, Slot{Bye: true})
+		order = append(order[:bye], order[bye+1:]...)
+	}
+	paired := make(map[string]bool)
+	for i, a := range order {
+		if paired[a] {
+			continue
+		}
+		partner := ""
+		for _, b := range order[i+1:] {
+			if paired[b] {
+				continue
+			}
+			if partner == "" {
+				partner = b // A rematch, if nobody new is left
+			}
+			if !met[[2]string{a, b}] {
+				partner = b
+				break
+			}
+		}
+		paired[a], paired[partner] = true, true
+		add(Slot{PlayerID: a}, Slot{PlayerID: partner})
+	}
+}
+
+// advance fills in every slot whose source match is done, settles matches with a
+// bye, moves Swiss and round robin events on to their next round, and returns the
+// matches that became ready to play.
+func (t *Tournament) advance() []Match {
+	if !t.elimination() && t.roundDone() {
+		if t.Round == t.Rounds {
+			t.Status = StatusFinished
+			t.Champion = t.Standings()[0].PlayerID
+			return nil
+		}
+		t.Round++
+		if t.Format == Swiss {
+			t.pairSwiss()
+		}
+	}
+
+	var ready []Match
+	for changed := true; changed; {
+		changed = false
+		for i := range t.Matches {
+			m := &t.Matches[i]
+			if m.State != MatchPending || (!t.elimination() && m.Round != t.Round) {
+				continue
+			}
+			t.fill(&m.A)
+			t.fill(&m.B)
+			if !m.A.resolved() || !m.B.resolved() {
+				continue
+			}
+			changed = true
+			if m.A.Bye || m.B.Bye {
+				m.State, m.Winner = MatchDone, m.A.PlayerID+m.B.PlayerID // The one who turned up, if anyone
+				continue
+			}
+			m.State = MatchReady
+			ready = append(ready, *m)
+		}
+	}
+	if !t.elimination() && t.roundDone() {
+		return append(ready, t.advance()...) // A round of nothing but byes
+	}
+	t.crown()
+	return ready
+}
+
+// fill resolves a slot whose source match is done.
+func (t *Tournament) fill(s *Slot) {
+	if s.resolved() || s.From == "" {
+		return
+	}
+	source := t.match(s.From)
+	if source == nil || source.State != MatchDone {
+		return
+	}
+	s.PlayerID = source.Winner
+	if s.Loser {
+		s.PlayerID = source.Loser
+	}
+	s.Bye = s.PlayerID == ""
+}
+
+// roundDone reports whether every match of the current Swiss or round robin round
+// is done; before the first round there is nothing to wait for.
+func (t *Tournament) roundDone() bool {
+	for _, m := range t.Matches {
+		if m.Round == t.Round && m.State != MatchDone {
+			return false
+		}
+	}
+	return true
+}
+
+// finish records a match's result and advances the tournament. An elimination
+// match that was drawn is replayed: it stays ready and is returned for a new game.
+func (t *Tournament) finish(m *Match, winner string, forfeit bool) []Match {
+	if winner == "" && t.elimination() {
+		m.GameID = ""
+		return []Match{*m}
+	}
+	m.State, m.Winner, m.Draw, m.Forfeit = MatchDone, winner, winner == "", forfeit
+	switch winner {
+	case m.A.PlayerID:
+		m.Loser = m.B.PlayerID
+	case m.B.PlayerID:
+		m.Loser = m.A.PlayerID
+	}
+	// In double elimination the grand final is played again if the losers bracket
+	// finalist wins it, as it is then their opponent's first loss
+	if m.ID == "GF" && winner == m.B.PlayerID {
+		t.Matches = append(t.Matches, Match{
+			ID: "GF2", Bracket: "final", Round: m.Round + 1, State: MatchPending,
+			A: Slot{From: "GF"}, B: Slot{From: "GF", Loser: true},
+		})
+		t.Rounds = m.Round + 1
+	}
+	return t.advance()
+}
+
+// crown finishes an elimination tournament once its last match is done.
+func (t *Tournament) crown() {
+	if !t.elimination() || len(t.Matches) == 0 {
+		return
+	}
+	last := t.Matches[len(t.Matches)-1]
+	if last.State == MatchDone && (t.Format == SingleElimination || last.ID == "GF2" || last.Winner == last.A.PlayerID) {
+		t.Status = StatusFinished
+		t.Champion = last.Winner
+	}
+}
+
+// Standing is an entrant's place in a tournament.
+type Standing struct {
+	Rank         int     `json:"rank"` // Players on equal terms share a rank
+	PlayerID     string  `json:"playerId"`
+	Name         string  `json:"name"`
+	Seed         int     `json:"seed"`
+	Points       float64 `json:"points"` // 1 per win or Swiss bye, 0.5 per draw
+	Wins         int     `json:"wins"`
+	Losses       int     `json:"losses"`
+	Draws        int     `json:"draws"`
+	Byes         int     `json:"byes"`
+	Tiebreak     float64 `json:"tiebreak"`               // Swiss and round robin: opponents' points (Buchholz)
+	Status       string  `json:"status"`                 // "champion", "active" or "eliminated"
+	EliminatedIn string  `json:"eliminatedIn,omitempty"` // Match that knocked the player out
+}
+
+// Standings ranks the entrants. Swiss and round robin events rank by points, then
+// by opponents' points, then by wins; elimination brackets rank by how far each
+// player got, those still in it first.
+func (t *Tournament) Standings() []Standing {
+	byPlayer := make(map[string]*Standing)
+	standings := make([]*Standing, len(t.Entrants))
+	for i, e := range t.Entrants {
+		standings[i] = &Standing{PlayerID: e.PlayerID, Name: e.Name, Seed: e.Seed, Status: "active"}
+		byPlayer[e.PlayerID] = standings[i]
+	}
+	opponents := make(map[string][]string)
+	depth := make(map[string]int) // Elimination: how late the player went out
+	maxLosses := 1
+	if t.Format == DoubleElimination {
+		maxLosses = 2
+	}
+
+	for _, m := range t.Matches {
+		if m.State != MatchDone {
+			continue
+		}
+		if m.A.Bye || m.B.Bye {
+			if st := byPlayer[m.Winner]; st != nil {
+				st.Byes++
+				if t.Format == Swiss {
+					st.Points++
+				}
+			}
+			continue
+		}
+		a, b := byPlayer[m.A.PlayerID], byPlayer[m.B.PlayerID]
+		opponents[a.PlayerID] = append(opponents[a.PlayerID], b.PlayerID)
+		opponents[b.PlayerID] = append(opponents[b.PlayerID], a.PlayerID)
+		if m.Draw {
+			a.Draws, b.Draws = a.Draws+1, b.Draws+1
+			a.Points, b.Points = a.Points+0.5, b.Points+0.5
+			continue
+		}
+		winner, loser := byPlayer[m.Winner], byPlayer[m.Loser]
+		winner.Wins++
+		winner.Points++
+		loser.Losses++
+		if t.elimination() && loser.Losses == maxLosses {
+			loser.Status, loser.EliminatedIn = "eliminated", m.ID
+			depth[loser.PlayerID] = m.Round
+		}
+	}
+	if t.Champion != "" {
+		byPlayer[t.Champion].Status = "champion"
+	}
+
+	rankKey := func(st *Standing) [3]float64 {
+		if t.elimination() {
+			switch st.Status {
+			case "champion":
+				return [3]float64{math.MaxInt32}
+			case "active":
+				return [3]float64{math.MaxInt32 - 1}
+			}
+			return [3]float64{float64(depth[st.PlayerID])}
+		}
+		for _, opponent := range opponents[st.PlayerID] {
+			st.Tiebreak += byPlayer[opponent].Points
+		}
+		return [3]float64{st.Points, st.Tiebreak, float64(st.Wins)}
+	}
+	keys := make(map[string][3]float64)
+	for _, st := range standings {
+		keys[st.PlayerID] = rankKey(st)
+	}
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := keys[standings[i].PlayerID], keys[standings[j].PlayerID]
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] > b[k]
+			}
+		}
+		return standings[i].Seed < standings[j].Seed
+	})
+
+	result := make([]Standing, len(standings))
+	for i, st := range standings {
+		st.Rank = i + 1
+		if i > 0 && keys[st.PlayerID] == keys[standings[i-1].PlayerID] {
+			st.Rank = result[i-1].Rank
+		}
+		result[i] = *st
+	}
+	return result
+}
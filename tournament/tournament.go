@@ -0,0 +1,488 @@
+// Package tournament runs tournaments in four formats: single elimination, double
+// elimination, Swiss and round robin. It keeps each event's entrants, schedule and
+// results; the server creates a game for every match this package reports ready,
+// and reports back when the game finishes, which advances the event.
+//
+// An entrant is a single player, or in a team tournament a team whose members
+// play the match's game together against the other team. Tournaments are stored
+// in a JSON file, saved after every change.
+package tournament
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sequence-game/game"
+)
+
+// Format is how a tournament pairs its players.
+type Format string
+
+const (
+	SingleElimination Format = "single_elimination" // One loss and you are out
+	DoubleElimination Format = "double_elimination" // Two losses; a losers bracket feeds a grand final
+	Swiss             Format = "swiss"              // A fixed number of rounds pairing players on equal points
+	RoundRobin        Format = "round_robin"        // Everyone plays everyone once
+)
+
+// Formats lists every format.
+var Formats = []Format{SingleElimination, DoubleElimination, Swiss, RoundRobin}
+
+// Status is where a tournament stands.
+type Status string
+
+const (
+	StatusRegistration Status = "registration" // Entrants can still be registered
+	StatusRunning      Status = "running"
+	StatusFinished     Status = "finished"
+)
+
+// MatchState is where a match stands.
+type MatchState string
+
+const (
+	MatchPending MatchState = "pending" // Waiting for its players to be known
+	MatchReady   MatchState = "ready"   // Both players known; its game is waiting for them or being played
+	MatchDone    MatchState = "done"
+)
+
+// Errors returned by the Store. ErrInvalid is wrapped with the reason.
+var (
+	ErrNotFound = errors.New("tournament not found")
+	ErrInvalid  = errors.New("invalid tournament request")
+	ErrState    = errors.New("not possible at this stage of the tournament")
+)
+
+// Entrant is a registered player or team. Slots, results and standings name an
+// entrant by its PlayerID, which for a team is the team's own ID.
+type Entrant struct {
+	PlayerID string   `json:"playerId"`
+	Name     string   `json:"name"`
+	Seed     int      `json:"seed"`              // From 1, in registration order
+	Members  []Member `json:"members,omitempty"` // A team's players; empty for a single player
+}
+
+// Member is a player of a team entrant.
+type Member struct {
+	PlayerID string `json:"playerId"`
+	Name     string `json:"name"`
+}
+
+// Players lists who plays for the entrant: its members, or the entrant itself.
+func (e Entrant) Players() []string {
+	if len(e.Members) == 0 {
+		return []string{e.PlayerID}
+	}
+	players := make([]string, len(e.Members))
+	for i, m := range e.Members {
+		players[i] = m.PlayerID
+	}
+	return players
+}
+
+// Slot is one side of a match: a player, the result of an earlier match that will
+// name one, or a bye.
+type Slot struct {
+	PlayerID string `json:"playerId,omitempty"`
+	From     string `json:"from,omitempty"`  // Match whose winner, or loser, fills the slot
+	Loser    bool   `json:"loser,omitempty"` // Takes the loser of From rather than its winner
+	Bye      bool   `json:"bye,omitempty"`   // Nobody will fill the slot
+}
+
+func (s Slot) resolved() bool {
+	return s.PlayerID != "" || s.Bye
+}
+
+// Match is one pairing of the schedule.
+type Match struct {
+	ID      string     `json:"id"`                // e.g. "R1-2"; "W2-1", "L3-1" and "GF" in double elimination
+	Bracket string     `json:"bracket,omitempty"` // Double elimination: "winners", "losers" or "final"
+	Round   int        `json:"round"`
+	A       Slot       `json:"a"`
+	B       Slot       `json:"b"`
+	State   MatchState `json:"state"`
+	GameID  string     `json:"gameId,omitempty"` // Game created for the match
+	Games   []string   `json:"games,omitempty"`  // Every game played for it, drawn ones included
+	Winner  string     `json:"winner,omitempty"` // Empty for a draw, or when nobody played
+	Loser   string     `json:"loser,omitempty"`
+	Draw    bool       `json:"draw,omitempty"`    // Swiss and round robin only; elimination matches replay draws
+	Forfeit bool       `json:"forfeit,omitempty"` // Result reported by an admin rather than played
+}
+
+// Players lists the entrants of a ready or finished match.
+func (m Match) Players() []string {
+	var players []string
+	for _, s := range []Slot{m.A, m.B} {
+		if s.PlayerID != "" {
+			players = append(players, s.PlayerID)
+		}
+	}
+	return players
+}
+
+// Tournament is an event and its schedule.
+type Tournament struct {
+	ID       string     `json:"id"`
+	Name     string     `json:"name"`
+	Format   Format     `json:"format"`
+	Rules    game.Rules `json:"rules"`              // Every match is played with these rules
+	Rounds   int        `json:"rounds"`             // Rounds in the schedule; chosen when creating a Swiss event
+	TeamSize int        `json:"teamSize,omitempty"` // Players per entrant in a team tournament; 0 for single players
+	Round    int        `json:"round"`              // Swiss and round robin: the round being played
+	Status   Status     `json:"status"`
+	Entrants []Entrant  `json:"entrants"`
+	Matches  []Match    `json:"matches"`
+	Champion string     `json:"champion,omitempty"` // PlayerID of the winner once finished
+	Created  time.Time  `json:"created"`
+}
+
+// Entrant returns the entrant with the player ID.
+func (t *Tournament) Entrant(playerID string) (Entrant, bool) {
+	for _, e := range t.Entrants {
+		if e.PlayerID == playerID {
+			return e, true
+		}
+	}
+	return Entrant{}, false
+}
+
+// EntrantOf returns the entrant a player plays for: the entrant with the player
+// ID, or the team they are a member of.
+func (t *Tournament) EntrantOf(playerID string) (Entrant, bool) {
+	for _, e := range t.Entrants {
+		if e.PlayerID == playerID || slices.Contains(e.Players(), playerID) {
+			return e, true
+		}
+	}
+	return Entrant{}, false
+}
+
+// Store holds the tournaments, saving every change to its file.
+type Store struct {
+	mu     sync.Mutex
+	path   string
+	events map[string]*Tournament
+	byGame map[string]string // Tournament ID by the game ID of a match
+}
+
+// Open loads the tournaments kept at path; a missing file is an empty store. An
+// empty path keeps the tournaments in memory only.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, events: make(map[string]*Tournament), byGame: make(map[string]string)}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*Tournament
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	for _, t := range list {
+		s.events[t.ID] = t
+		for _, m := range t.Matches {
+			for _, gameID := range m.Games {
+				s.byGame[gameID] = t.ID
+			}
+		}
+	}
+	return s, nil
+}
+
+// Create adds a tournament open for registration. Rounds only applies to Swiss,
+// where zero picks enough rounds to leave one unbeaten player. A teamSize of two
+// or more makes a team tournament, whose matches are games of two teams.
+func (s *Store) Create(name string, format Format, rules game.Rules, rounds, teamSize int) (Tournament, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 60 {
+		return Tournament{}, fmt.Errorf("%w: a name is 1 to 60 characters", ErrInvalid)
+	}
+	known := false
+	for _, f := range Formats {
+		known = known || f == format
+	}
+	if !known {
+		return Tournament{}, fmt.Errorf("%w: unknown format %q", ErrInvalid, format)
+	}
+	if rounds < 0 || (rounds > 0 && format != Swiss) {
+		return Tournament{}, fmt.Errorf("%w: rounds can only be chosen for a Swiss tournament", ErrInvalid)
+	}
+	if err := rules.Validate(); err != nil {
+		return Tournament{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if teamSize == 1 {
+		teamSize = 0
+	}
+	if teamSize < 0 || 2*teamSize > rules.MaxSupportedPlayers() {
+		return Tournament{}, fmt.Errorf("%w: teams of %d cannot play these rules, which seat up to %d players", ErrInvalid, teamSize, rules.MaxSupportedPlayers())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := &Tournament{
+		ID: game.GenerateID(), Name: name, Format: format, Rules: rules, Rounds: rounds, TeamSize: teamSize,
+		Status: StatusRegistration, Entrants: []Entrant{}, Matches: []Match{}, Created: time.Now().UTC(),
+	}
+	s.events[t.ID] = t
+	if err := s.save(); err != nil {
+		delete(s.events, t.ID)
+		return Tournament{}, err
+	}
+	return t.copy(), nil
+}
+
+// Register adds a player to a tournament that has not started, or in a team
+// tournament a team of members; a team without an ID is given one.
+func (s *Store) Register(id, playerID, name string, members []Member) (Tournament, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Tournament{}, fmt.Errorf("%w: an entrant needs a name", ErrInvalid)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.events[id]
+	if !ok {
+		return Tournament{}, ErrNotFound
+	}
+	if t.Status != StatusRegistration {
+		return Tournament{}, fmt.Errorf("%w: registration has closed", ErrState)
+	}
+	entrant := Entrant{PlayerID: playerID, Name: name, Seed: len(t.Entrants) + 1}
+	if t.TeamSize == 0 {
+		if playerID == "" || len(members) > 0 {
+			return Tournament{}, fmt.Errorf("%w: an entrant is one player, with a player ID", ErrInvalid)
+		}
+	} else {
+		if len(members) != t.TeamSize {
+			return Tournament{}, fmt.Errorf("%w: a team has %d members", ErrInvalid, t.TeamSize)
+		}
+		if entrant.PlayerID == "" {
+			entrant.PlayerID = "team-" + game.GenerateID()
+		}
+		for _, m := range members {
+			m.Name = strings.TrimSpace(m.Name)
+			if m.PlayerID == "" || m.Name == "" {
+				return Tournament{}, fmt.Errorf("%w: every member needs a player ID and a name", ErrInvalid)
+			}
+			if m.PlayerID == entrant.PlayerID || slices.Contains(entrant.Players(), m.PlayerID) {
+				return Tournament{}, fmt.Errorf("%w: player %s is on the team twice", ErrInvalid, m.PlayerID)
+			}
+			entrant.Members = append(entrant.Members, m)
+		}
+	}
+	for _, playerID := range append(entrant.Players(), entrant.PlayerID) {
+		if _, taken := t.EntrantOf(playerID); taken {
+			return Tournament{}, fmt.Errorf("%w: player %s is already registered", ErrInvalid, playerID)
+		}
+	}
+	t.Entrants = append(t.Entrants, entrant)
+	return t.copy(), s.save()
+}
+
+// Start closes registration, draws up the schedule and returns the matches now
+// ready to be played.
+func (s *Store) Start(id string) (Tournament, []Match, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.events[id]
+	if !ok {
+		return Tournament{}, nil, ErrNotFound
+	}
+	if t.Status != StatusRegistration {
+		return Tournament{}, nil, fmt.Errorf("%w: it has already started", ErrState)
+	}
+	if len(t.Entrants) < 2 {
+		return Tournament{}, nil, fmt.Errorf("%w: at least two entrants are needed", ErrState)
+	}
+	t.schedule()
+	t.Status = StatusRunning
+	ready := t.advance()
+	return t.copy(), ready, s.save()
+}
+
+// SetGame records the game created for a ready match.
+func (s *Store) SetGame(id, matchID, gameID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.events[id]
+	if !ok {
+		return ErrNotFound
+	}
+	m := t.match(matchID)
+	if m == nil || m.State != MatchReady {
+		return fmt.Errorf("%w: match %s is not waiting for a game", ErrState, matchID)
+	}
+	m.GameID = gameID
+	m.Games = append(m.Games, gameID)
+	s.byGame[gameID] = t.ID
+	return s.save()
+}
+
+// MatchPlayers returns the players of the match a game was created for, every
+// member of a team included, and false for a game that is not a tournament match.
+func (s *Store) MatchPlayers(gameID string) ([]string, bool) {
+	sides, ok := s.MatchSides(gameID)
+	return slices.Concat(sides...), ok
+}
+
+// MatchSides returns the players of each side of the match a game was created
+// for, A's first: one player each, or the members of each team.
+func (s *Store) MatchSides(gameID string) ([][]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, m := s.matchOf(gameID)
+	if m == nil {
+		return nil, false
+	}
+	var sides [][]string
+	for _, id := range m.Players() {
+		e, _ := t.Entrant(id)
+		sides = append(sides, e.Players())
+	}
+	return sides, true
+}
+
+// GameFinished records the result of a match's game, winner being the player
+// who won, or whose team did, and empty for a draw. It returns the tournament and
+// the matches that became ready, and reports false for a game that is not the
+// current game of a tournament match.
+func (s *Store) GameFinished(gameID, winner string) (Tournament, []Match, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, m := s.matchOf(gameID)
+	if m == nil || m.State != MatchReady || m.GameID != gameID {
+		return Tournament{}, nil, false, nil
+	}
+	if e, ok := t.EntrantOf(winner); ok {
+		winner = e.PlayerID
+	}
+	ready := t.finish(m, winner, false)
+	return t.copy(), ready, true, s.save()
+}
+
+// Report sets the result of a ready match without playing it, e.g. when a player
+// does not show up, and returns the matches that became ready.
+func (s *Store) Report(id, matchID, winner string) (Tournament, []Match, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.events[id]
+	if !ok {
+		return Tournament{}, nil, ErrNotFound
+	}
+	m := t.match(matchID)
+	if m == nil {
+		return Tournament{}, nil, fmt.Errorf("%w: no match %s", ErrInvalid, matchID)
+	}
+	if m.State != MatchReady {
+		return Tournament{}, nil, fmt.Errorf("%w: match %s is not being played", ErrState, matchID)
+	}
+	if winner != m.A.PlayerID && winner != m.B.PlayerID && (winner != "" || t.elimination()) {
+		return Tournament{}, nil, fmt.Errorf("%w: the winner must be one of the match's players", ErrInvalid)
+	}
+	ready := t.finish(m, winner, true)
+	return t.copy(), ready, s.save()
+}
+
+// Get returns a tournament.
+func (s *Store) Get(id string) (Tournament, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.events[id]
+	if !ok {
+		return Tournament{}, false
+	}
+	return t.copy(), true
+}
+
+// List returns every tournament, the newest first.
+func (s *Store) List() []Tournament {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Tournament, 0, len(s.events))
+	for _, t := range s.events {
+		list = append(list, t.copy())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.After(list[j].Created) })
+	return list
+}
+
+func (s *Store) matchOf(gameID string) (*Tournament, *Match) {
+	t, ok := s.events[s.byGame[gameID]]
+	if !ok {
+		return nil, nil
+	}
+	for i := range t.Matches {
+		for _, id := range t.Matches[i].Games {
+			if id == gameID {
+				return t, &t.Matches[i]
+			}
+		}
+	}
+	return nil, nil
+}
+
+// save writes every tournament to the store's file, replacing it only once the
+// new contents are safely written.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+	list := make([]*Tournament, 0, len(s.events))
+	for _, t := range s.events {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.Before(list[j].Created) })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// copy returns a copy of the tournament sharing nothing with it.
+func (t *Tournament) copy() Tournament {
+	c := *t
+	c.Entrants = append([]Entrant{}, t.Entrants...)
+	for i, e := range c.Entrants {
+		c.Entrants[i].Members = append([]Member(nil), e.Members...)
+	}
+	c.Matches = make([]Match, len(t.Matches))
+	for i, m := range t.Matches {
+		m.Games = append([]string(nil), m.Games...)
+		c.Matches[i] = m
+	}
+	return c
+}
+
+func (t *Tournament) match(id string) *Match {
+	for i := range t.Matches {
+		if t.Matches[i].ID == id {
+			return &t.Matches[i]
+		}
+	}
+	return nil
+}
+
+func (t *Tournament) elimination() bool {
+	return t.Format == SingleElimination || t.Format == DoubleElimination
+}
@@ -0,0 +1,399 @@
+package tournament
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"sequence-game/game"
+)
+
+// result picks the winner of a match's game; "" is a draw. The match's Games
+// include the one being decided.
+type result func(t Tournament, m Match) string
+
+// favourite has the better seed win every match.
+func favourite(t Tournament, m Match) string {
+	a, _ := t.Entrant(m.A.PlayerID)
+	b, _ := t.Entrant(m.B.PlayerID)
+	if a.Seed < b.Seed {
+		return a.PlayerID
+	}
+	return b.PlayerID
+}
+
+// underdogTakesFinal is favourite, except that the losers bracket finalist wins the
+// first grand final.
+func underdogTakesFinal(t Tournament, m Match) string {
+	if m.ID == "GF" {
+		return m.B.PlayerID
+	}
+	return favourite(t, m)
+}
+
+// drawFirst draws the first game of every match, then is favourite.
+func drawFirst(t Tournament, m Match) string {
+	if len(m.Games) == 1 {
+		return ""
+	}
+	return favourite(t, m)
+}
+
+// run registers entrants p1 to pn, starts the tournament and plays every match
+// that becomes ready until it finishes.
+func run(t *testing.T, format Format, entrants int, decide result) Tournament {
+	t.Helper()
+	s, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := game.RulesPreset("Tournament")
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := s.Create("Test", format, rules, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= entrants; i++ {
+		if _, err := s.Register(event.ID, fmt.Sprintf("p%d", i), fmt.Sprintf("Player %d", i), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	event, ready, err := s.Start(event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for games := 0; len(ready) > 0; games++ {
+		if games > 1000 {
+			t.Fatal("the tournament does not finish")
+		}
+		m := ready[0]
+		ready = ready[1:]
+		gameID := fmt.Sprintf("g%d", games)
+		if err := s.SetGame(event.ID, m.ID, gameID); err != nil {
+			t.Fatal(err)
+		}
+		m.Games = append(m.Games, gameID)
+		var more []Match
+		var ok bool
+		event, more, ok, err = s.GameFinished(gameID, decide(event, m))
+		if !ok || err != nil {
+			t.Fatalf("GameFinished(%s) for match %s: %t, %v", gameID, m.ID, ok, err)
+		}
+		ready = append(ready, more...)
+	}
+	if event.Status != StatusFinished {
+		t.Fatalf("no match is ready but the tournament is %s", event.Status)
+	}
+	return event
+}
+
+// checkStandings checks what every format's final standings share.
+func checkStandings(t *testing.T, event Tournament, entrants int) []Standing {
+	t.Helper()
+	standings := event.Standings()
+	if len(standings) != entrants {
+		t.Fatalf("%d standings for %d entrants", len(standings), entrants)
+	}
+	if event.Champion == "" || standings[0].PlayerID != event.Champion || standings[0].Rank != 1 {
+		t.Errorf("champion %q, but the standings start with %+v", event.Champion, standings[0])
+	}
+	for i, st := range standings {
+		if i > 0 && st.Rank < standings[i-1].Rank {
+			t.Errorf("rank %d of %s follows rank %d", st.Rank, st.PlayerID, standings[i-1].Rank)
+		}
+	}
+	for _, m := range event.Matches {
+		if m.State != MatchDone {
+			t.Errorf("match %s is %s in a finished tournament", m.ID, m.State)
+		}
+	}
+	return standings
+}
+
+func TestFormats(t *testing.T) {
+	for _, entrants := range []int{2, 3, 5, 8} {
+		rounds := int(math.Ceil(math.Log2(float64(entrants)))) // Of a knockout bracket, and of a Swiss event
+
+		t.Run(fmt.Sprintf("single elimination, %d entrants", entrants), func(t *testing.T) {
+			event := run(t, SingleElimination, entrants, favourite)
+			standings := checkStandings(t, event, entrants)
+			if event.Champion != "p1" {
+				t.Errorf("champion %s, want the top seed", event.Champion)
+			}
+			for _, st := range standings {
+				switch {
+				case st.PlayerID == "p1":
+					if st.Status != "champion" || st.Wins+st.Byes != rounds || st.Losses != 0 {
+						t.Errorf("champion's standing %+v, want %d wins and byes", st, rounds)
+					}
+				case st.Status != "eliminated" || st.Losses != 1:
+					t.Errorf("standing %+v, want eliminated after one loss", st)
+				}
+			}
+			// Each seed goes out no earlier than the seeds below it
+			for i := 1; i < len(standings); i++ {
+				if standings[i].Seed < standings[i-1].Seed && standings[i].Rank != standings[i-1].Rank {
+					t.Errorf("seed %d ranked below seed %d", standings[i].Seed, standings[i-1].Seed)
+				}
+			}
+		})
+
+		t.Run(fmt.Sprintf("double elimination, %d entrants", entrants), func(t *testing.T) {
+			event := run(t, DoubleElimination, entrants, favourite)
+			standings := checkStandings(t, event, entrants)
+			if event.Champion != "p1" {
+				t.Errorf("champion %s, want the top seed", event.Champion)
+			}
+			for _, st := range standings {
+				switch {
+				case st.PlayerID == "p1":
+					if st.Status != "champion" || st.Losses != 0 {
+						t.Errorf("champion's standing %+v, want unbeaten", st)
+					}
+				case st.Status != "eliminated" || st.Losses != 2:
+					t.Errorf("standing %+v, want eliminated after two losses", st)
+				}
+			}
+			if event.match("GF2") != nil {
+				t.Error("a second grand final after the winners bracket finalist won")
+			}
+		})
+
+		t.Run(fmt.Sprintf("swiss, %d entrants", entrants), func(t *testing.T) {
+			event := run(t, Swiss, entrants, favourite)
+			standings := checkStandings(t, event, entrants)
+			if want := min(rounds, entrants-1); event.Rounds != want {
+				t.Errorf("%d rounds, want %d", event.Rounds, want)
+			}
+			// Everyone plays, or has a bye, once a round
+			for round := 1; round <= event.Rounds; round++ {
+				seen := make(map[string]int)
+				for _, m := range event.Matches {
+					if m.Round == round {
+						for _, id := range m.Players() {
+							seen[id]++
+						}
+					}
+				}
+				for i := 1; i <= entrants; i++ {
+					if id := fmt.Sprintf("p%d", i); seen[id] != 1 {
+						t.Errorf("%s plays %d times in round %d", id, seen[id], round)
+					}
+				}
+			}
+			total := 0.0
+			for _, st := range standings {
+				total += st.Points
+				if st.Wins+st.Losses+st.Byes != event.Rounds {
+					t.Errorf("standing %+v does not add up to %d rounds", st, event.Rounds)
+				}
+				if st.Points > standings[0].Points {
+					t.Errorf("%s has more points than the champion", st.PlayerID)
+				}
+			}
+			if byes := (entrants % 2) * event.Rounds; total != float64(entrants/2*event.Rounds+byes) {
+				t.Errorf("%.1f points handed out, want one per game and bye", total)
+			}
+			if top := standings[0]; top.PlayerID != "p1" || top.Points != float64(event.Rounds) {
+				t.Errorf("standings start with %+v, want the top seed unbeaten", top)
+			}
+		})
+
+		t.Run(fmt.Sprintf("round robin, %d entrants", entrants), func(t *testing.T) {
+			event := run(t, RoundRobin, entrants, favourite)
+			standings := checkStandings(t, event, entrants)
+			if want := entrants * (entrants - 1) / 2; len(event.Matches) != want {
+				t.Errorf("%d matches, want %d", len(event.Matches), want)
+			}
+			met := make(map[[2]string]bool)
+			for _, m := range event.Matches {
+				pair := [2]string{min(m.A.PlayerID, m.B.PlayerID), max(m.A.PlayerID, m.B.PlayerID)}
+				if met[pair] {
+					t.Errorf("%s and %s meet twice", pair[0], pair[1])
+				}
+				met[pair] = true
+			}
+			if event.Champion != "p1" {
+				t.Errorf("champion %s, want the top seed", event.Champion)
+			}
+			for i, st := range standings {
+				if st.Seed != i+1 || st.Points != float64(entrants-st.Seed) || st.Rank != i+1 {
+					t.Errorf("standing %d is %+v, want seed %d with %d points", i+1, st, i+1, entrants-i-1)
+				}
+			}
+		})
+	}
+}
+
+func TestGrandFinalReset(t *testing.T) {
+	event := run(t, DoubleElimination, 4, underdogTakesFinal)
+	checkStandings(t, event, 4)
+	reset := event.match("GF2")
+	if reset == nil {
+		t.Fatal("no second grand final after the losers bracket finalist won the first")
+	}
+	if event.Champion != "p1" || reset.Winner != "p1" {
+		t.Errorf("champion %s, second grand final won by %s; want p1", event.Champion, reset.Winner)
+	}
+	if st := event.Standings()[0]; st.Losses != 1 || st.Status != "champion" {
+		t.Errorf("champion's standing %+v, want one loss", st)
+	}
+}
+
+func TestDrawsReplayed(t *testing.T) {
+	for _, format := range []Format{SingleElimination, DoubleElimination} {
+		t.Run(string(format), func(t *testing.T) {
+			event := run(t, format, 5, drawFirst)
+			checkStandings(t, event, 5)
+			for _, m := range event.Matches {
+				if m.A.Bye || m.B.Bye {
+					continue
+				}
+				if m.Draw || len(m.Games) != 2 || m.Winner == "" {
+					t.Errorf("match %s: games %v, winner %q, draw %t; want the draw replayed and won", m.ID, m.Games, m.Winner, m.Draw)
+				}
+			}
+			if event.Champion != "p1" {
+				t.Errorf("champion %s, want the top seed", event.Champion)
+			}
+		})
+	}
+
+	event := run(t, RoundRobin, 3, drawFirst)
+	for _, st := range checkStandings(t, event, 3) {
+		if st.Draws != 2 || st.Points != 1 || st.Rank != 1 {
+			t.Errorf("standing %+v, want two draws sharing first place", st)
+		}
+	}
+}
+
+func TestTeamRegistration(t *testing.T) {
+	members := func(ids ...string) []Member {
+		var m []Member
+		for _, id := range ids {
+			m = append(m, Member{PlayerID: id, Name: "Player " + id})
+		}
+		return m
+	}
+	tests := []struct {
+		name     string
+		teamSize int
+		playerID string
+		members  []Member
+		wantErr  bool
+	}{
+		{name: "team", teamSize: 2, playerID: "t2", members: members("c", "d")},
+		{name: "team given an ID", teamSize: 2, members: members("c", "d")},
+		{name: "too few members", teamSize: 2, playerID: "t2", members: members("c"), wantErr: true},
+		{name: "no members", teamSize: 2, playerID: "t2", wantErr: true},
+		{name: "member twice", teamSize: 2, playerID: "t2", members: members("c", "c"), wantErr: true},
+		{name: "member of another team", teamSize: 2, playerID: "t2", members: members("a", "c"), wantErr: true},
+		{name: "another team's ID", teamSize: 2, playerID: "t1", members: members("c", "d"), wantErr: true},
+		{name: "single player", playerID: "c"},
+		{name: "single player with members", playerID: "c", members: members("d"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Open("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			rules, err := game.RulesPreset("Tournament")
+			if err != nil {
+				t.Fatal(err)
+			}
+			event, err := s.Create("Test", RoundRobin, rules, 0, tt.teamSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			first, firstMembers := "a", []Member(nil)
+			if tt.teamSize > 0 {
+				first, firstMembers = "t1", members("a", "b")
+			}
+			if _, err := s.Register(event.ID, first, "First", firstMembers); err != nil {
+				t.Fatal(err)
+			}
+
+			event, err = s.Register(event.ID, tt.playerID, "Second", tt.members)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Register error = %v, want error %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			second := event.Entrants[1]
+			if second.PlayerID == "" || (tt.playerID != "" && second.PlayerID != tt.playerID) || len(second.Members) != len(tt.members) {
+				t.Errorf("registered %+v", second)
+			}
+		})
+	}
+
+	rules, err := game.RulesPreset("Tournament")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create("Test", RoundRobin, rules, 0, rules.MaxSupportedPlayers()); err == nil {
+		t.Error("created a tournament of teams too large to seat in one game")
+	}
+}
+
+func TestTeamMatch(t *testing.T) {
+	s, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := game.RulesPreset("Tournament")
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := s.Create("Test", SingleElimination, rules, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, team := range []struct {
+		id      string
+		members []Member
+	}{
+		{"t1", []Member{{PlayerID: "a", Name: "A"}, {PlayerID: "b", Name: "B"}}},
+		{"t2", []Member{{PlayerID: "c", Name: "C"}, {PlayerID: "d", Name: "D"}}},
+	} {
+		if _, err := s.Register(event.ID, team.id, "Team "+team.id, team.members); err != nil {
+			t.Fatal(err)
+		}
+	}
+	event, ready, err := s.Start(event.ID)
+	if err != nil || len(ready) != 1 {
+		t.Fatalf("Start: %d matches ready, error %v", len(ready), err)
+	}
+	if err := s.SetGame(event.ID, ready[0].ID, "g1"); err != nil {
+		t.Fatal(err)
+	}
+
+	sides, ok := s.MatchSides("g1")
+	if !ok || !reflect.DeepEqual(sides, [][]string{{"a", "b"}, {"c", "d"}}) {
+		t.Errorf("MatchSides = %v, %t; want each team's members", sides, ok)
+	}
+	if players, _ := s.MatchPlayers("g1"); !reflect.DeepEqual(players, []string{"a", "b", "c", "d"}) {
+		t.Errorf("MatchPlayers = %v, want every member", players)
+	}
+	if e, ok := event.EntrantOf("d"); !ok || e.PlayerID != "t2" {
+		t.Errorf("EntrantOf(d) = %+v, %t; want team t2", e, ok)
+	}
+
+	// The game's winner is the member who completed the last sequence
+	event, _, ok, err = s.GameFinished("g1", "d")
+	if !ok || err != nil {
+		t.Fatalf("GameFinished: %t, %v", ok, err)
+	}
+	if event.Champion != "t2" {
+		t.Errorf("champion %q, want team t2", event.Champion)
+	}
+	checkStandings(t, event, 2)
+}
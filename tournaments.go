@@ -0,0 +1,296 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"sequence-game/game"
+	"sequence-game/history"
+	"sequence-game/protocol"
+	"sequence-game/tournament"
+)
+
+// --- Tournaments ---
+
+// adminAuthorized checks the request's "Authorization: Bearer <token>" header
+// against the admin token, answering the request itself when it does not match.
+// The admin endpoints are disabled when no token is configured.
+func adminAuthorized(w http.ResponseWriter, r *http.Request) bool {
+	if cfg.AdminToken == "" {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "tournament admin is disabled; set SEQUENCE_ADMIN_TOKEN"})
+		return false
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.AdminToken)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "admin token required"})
+		return false
+	}
+	return true
+}
+
+// writeTournamentError answers with the HTTP status matching a tournament error.
+func writeTournamentError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, tournament.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, tournament.ErrInvalid):
+		status = http.StatusBadRequest
+	case errors.Is(err, tournament.ErrState):
+		status = http.StatusConflict
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// decodeBody reads a JSON request body into v, answering the request on failure.
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid request body: %v", err)})
+		return false
+	}
+	return true
+}
+
+// createTournamentRequest is the body of POST /api/tournaments.
+type createTournamentRequest struct {
+	Name        string            `json:"name"`
+	Format      tournament.Format `json:"format"`
+	RulesPreset string            `json:"rulesPreset,omitempty"` // Defaults to "Tournament"
+	Rules       *game.Rules       `json:"rules,omitempty"`       // Custom rules, takes precedence over RulesPreset
+	Rounds      int               `json:"rounds,omitempty"`      // Swiss only; zero picks a number to suit the field
+	TeamSize    int               `json:"teamSize,omitempty"`    // Players per team; zero for single players
+}
+
+// createTournament answers POST /api/tournaments (admin).
+func createTournament(w http.ResponseWriter, r *http.Request) {
+	if !adminAuthorized(w, r) {
+		return
+	}
+	var req createTournamentRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.RulesPreset == "" && req.Rules == nil {
+		req.RulesPreset = "Tournament"
+	}
+	rules, err := rulesForCreate(&protocol.CreateGame{RulesPreset: req.RulesPreset, Rules: req.Rules})
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid rules: %v", err)})
+		return
+	}
+	rules.Hints = false // Competitive play
+	t, err := tournamentStore.Create(req.Name, req.Format, rules, req.Rounds, req.TeamSize)
+	if err != nil {
+		writeTournamentError(w, err)
+		return
+	}
+	log.Printf("Tournament %s (%s, %s) created.", t.ID, t.Name, t.Format)
+	writeJSON(w, http.StatusCreated, t)
+}
+
+// registerEntrant answers POST /api/tournaments/{id}/entrants (admin) with a body
+// of {"playerId", "name"}, or for a team {"name", "members": [{"playerId", "name"}]}
+// with an optional team "playerId".
+func registerEntrant(w http.ResponseWriter, r *http.Request) {
+	if !adminAuthorized(w, r) {
+		return
+	}
+	var entrant tournament.Entrant
+	if !decodeBody(w, r, &entrant) {
+		return
+	}
+	t, err := tournamentStore.Register(r.PathValue("id"), entrant.PlayerID, entrant.Name, entrant.Members)
+	if err != nil {
+		writeTournamentError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+// startTournament answers POST /api/tournaments/{id}/start (admin): it draws up the
+// schedule and creates the games of the first matches.
+func startTournament(w http.ResponseWriter, r *http.Request) {
+	if !adminAuthorized(w, r) {
+		return
+	}
+	t, ready, err := tournamentStore.Start(r.PathValue("id"))
+	if err != nil {
+		writeTournamentError(w, err)
+		return
+	}
+	log.Printf("Tournament %s started with %d entrants.", t.ID, len(t.Entrants))
+	createMatchGames(t, ready)
+	t, _ = tournamentStore.Get(t.ID)
+	writeJSON(w, http.StatusOK, t)
+}
+
+// reportResult answers POST /api/tournaments/{id}/matches/{match}/result (admin)
+// with a body of {"winner": playerId}, settling a match without a game, e.g. a
+// forfeit. An empty winner is a draw, outside elimination brackets.
+func reportResult(w http.ResponseWriter, r *http.Request) {
+	if !adminAuthorized(w, r) {
+		return
+	}
+	var req struct {
+		Winner string `json:"winner"`
+	}
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	t, ready, err := tournamentStore.Report(r.PathValue("id"), r.PathValue("match"), req.Winner)
+	if err != nil {
+		writeTournamentError(w, err)
+		return
+	}
+	log.Printf("Tournament %s: result of match %s reported (winner %q).", t.ID, r.PathValue("match"), req.Winner)
+	createMatchGames(t, ready)
+	t, _ = tournamentStore.Get(t.ID)
+	writeJSON(w, http.StatusOK, t)
+}
+
+// serveTournaments answers GET /api/tournaments with every tournament, newest first.
+func serveTournaments(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, tournamentStore.List())
+}
+
+// serveTournament answers GET /api/tournaments/{id} with the tournament, its
+// matches and their games.
+func serveTournament(w http.ResponseWriter, r *http.Request) {
+	t, ok := tournamentStore.Get(r.PathValue("id"))
+	if !ok {
+		writeTournamentError(w, tournament.ErrNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+// serveStandings answers GET /api/tournaments/{id}/standings.
+func serveStandings(w http.ResponseWriter, r *http.Request) {
+	t, ok := tournamentStore.Get(r.PathValue("id"))
+	if !ok {
+		writeTournamentError(w, tournament.ErrNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, t.Standings())
+}
+
+// serveSpectator serves the tournament spectator page for GET /tournaments/{id}.
+func serveSpectator(w http.ResponseWriter, r *http.Request) {
+	assets.servePage(w, r, TournamentHTMLFile)
+}
+
+// createMatchGames creates a lobby with the tournament's rules for each ready
+// match. Only the match's players may join it, and it starts once all have. In a
+// team tournament it is a game of two teams, hosted by A's first member.
+func createMatchGames(t tournament.Tournament, ready []tournament.Match) {
+	for _, m := range ready {
+		host, _ := t.Entrant(m.A.PlayerID)
+		hostID, hostName := host.PlayerID, host.Name
+		if t.TeamSize > 0 {
+			hostID, hostName = host.Members[0].PlayerID, host.Members[0].Name
+		}
+		g := game.NewGame(hostID, hostName, 2*max(t.TeamSize, 1), 0, t.Rules)
+		if t.TeamSize > 0 {
+			if err := g.SetTeams(2); err != nil {
+				log.Printf("Failed to seat teams for match %s of tournament %s: %v", m.ID, t.ID, err)
+				continue
+			}
+		}
+		gamesMu.Lock()
+		games[g.ID] = g
+		gamesMu.Unlock()
+		if err := tournamentStore.SetGame(t.ID, m.ID, g.ID); err != nil {
+			log.Printf("Failed to record game %s for match %s of tournament %s: %v", g.ID, m.ID, t.ID, err)
+			continue
+		}
+		log.Printf("Tournament %s: game %s created for match %s.", t.ID, g.ID, m.ID)
+		_ = writeGameLog(g.ID, fmt.Sprintf("Game created for match %s of tournament %s (%s)", m.ID, t.Name, t.ID))
+	}
+}
+
+// resumeTournaments recreates the games of matches that were waiting or being
+// played when the server stopped, as games only live in memory. A game restored
+// from its snapshot is kept.
+func resumeTournaments() {
+	for _, t := range tournamentStore.List() {
+		var ready []tournament.Match
+		for _, m := range t.Matches {
+			gamesMu.Lock()
+			_, restored := games[m.GameID]
+			gamesMu.Unlock()
+			if m.State == tournament.MatchReady && !restored {
+				ready = append(ready, m)
+			}
+		}
+		createMatchGames(t, ready)
+	}
+}
+
+// checkMatchSeat rejects a player joining a tournament game that is not theirs,
+// and in a team match reserves them a seat on their team's side.
+func checkMatchSeat(g *game.Game, playerID string) error {
+	sides, ok := tournamentStore.MatchSides(g.ID)
+	if !ok {
+		return nil
+	}
+	for i, side := range sides {
+		if !slices.Contains(side, playerID) {
+			continue
+		}
+		g.Lock()
+		teams := g.Teams
+		g.Unlock()
+		if teams == 0 {
+			return nil
+		}
+		return g.ReserveTeam(playerID, game.TeamName(i+1))
+	}
+	return errors.New("this game is reserved for the players of a tournament match")
+}
+
+// startMatchWhenSeated starts a tournament game once all its players have joined.
+func startMatchWhenSeated(g *game.Game) {
+	players, ok := tournamentStore.MatchPlayers(g.ID)
+	if !ok {
+		return
+	}
+	g.Lock()
+	seated := g.GamePhase == "Lobby"
+	for _, id := range players {
+		_, in := g.Players[id]
+		seated = seated && in
+	}
+	g.Unlock()
+	if !seated {
+		return
+	}
+	if err := g.StartGame(g.HostID); err != nil {
+		log.Printf("Failed to start tournament game %s: %v", g.ID, err)
+		return
+	}
+	_ = writeGameLog(g.ID, "Game started: all players of the match are seated")
+	broadcastGameState(g, protocol.EventGameStarted, nil)
+}
+
+// tournamentGameFinished advances the tournament of a finished game, if it is a
+// match, and creates the games of the matches that became ready.
+func tournamentGameFinished(record history.Record) {
+	t, ready, ok, err := tournamentStore.GameFinished(record.GameID, record.Winner)
+	if err != nil {
+		log.Printf("Error recording the result of tournament game %s: %v", record.GameID, err)
+	}
+	if !ok {
+		return
+	}
+	log.Printf("Tournament %s: game %s finished; %d match(es) now ready.", t.ID, record.GameID, len(ready))
+	if t.Status == tournament.StatusFinished {
+		log.Printf("Tournament %s finished. Champion: %s", t.ID, t.Champion)
+	}
+	createMatchGames(t, ready)
+}